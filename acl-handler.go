@@ -0,0 +1,267 @@
+package apperix
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"net/http"
+)
+
+var userIdentifierPattern = regexp.MustCompile("^[0-9a-f]{32}$")
+
+/*
+	parseAclUser converts the user argument of an ACL request
+	into a target user accepted by verifyTargetUser.
+*/
+func parseAclUser(str string) (user interface{}, err error) {
+	switch str {
+	case "others":
+		return OTHERS, nil
+	case "guests":
+		return GUESTS, nil
+	}
+	if !userIdentifierPattern.MatchString(str) {
+		return nil, fmt.Errorf("Invalid user '%s'", str)
+	}
+	identifier := Identifier {}
+	identifier.FromString(str)
+	return identifier, nil
+}
+
+/*
+	aclUserName converts a serialized user identifier
+	into its name as used in ACL responses.
+*/
+func aclUserName(userId string) string {
+	switch userId {
+	case "o":
+		return "others"
+	case "g":
+		return "guests"
+	}
+	return userId
+}
+
+/*
+	aclParameter returns the first value of the given query parameter.
+*/
+func aclParameter(request *Request, key string) string {
+	if len(request.Parameters[key]) < 1 {
+		return ""
+	}
+	return request.Parameters[key][0]
+}
+
+/*
+	aclMethods holds the methods supported on access control lists
+	in ascending order, HEAD and OPTIONS are synthesized.
+*/
+var aclMethods = []Method {
+	READ,
+	UPDATE,
+	DELETE,
+	READ_HEADERS,
+	READ_OPTIONS,
+}
+
+/*
+	aclAllowed returns true if the client is allowed to perform
+	the given method on access control lists, given its permissions
+	on the resource. OPTIONS is always allowed, other methods
+	are never allowed to guests.
+*/
+func aclAllowed(
+	method Method,
+	client *Client,
+	isOwner bool,
+	permissions Permissions,
+) bool {
+	if method == READ_OPTIONS {
+		return true
+	}
+	if client.Identifier == nil {
+		return false
+	}
+	switch method {
+	case READ, READ_HEADERS:
+		return isOwner || permissions.ReadProperties
+	case UPDATE, DELETE:
+		return isOwner || permissions.UpdateProperties
+	}
+	return false
+}
+
+/*
+	aclHandler lists (READ and READ_HEADERS), grants (UPDATE)
+	and revokes (DELETE) permissions on the given resource
+	and lists the methods allowed to the client (READ_OPTIONS).
+	Only built-in permissions and the given custom permissions
+	declared by the resource or its ancestors may be granted.
+	Owners may manage all permissions, other users require
+	the read- or update-properties permission and may neither grant,
+	replace nor revoke permissions exceeding their own.
+*/
+func aclHandler(
+	method Method,
+	client *Client,
+	request *Request,
+	service *Service,
+	resourceObj resourceObject,
+	declared []string,
+	resourceId ResourceIdentifier,
+	isOwner bool,
+	permissions Permissions,
+) Response {
+	response := ResponseJson {}
+	if method == READ_OPTIONS {
+		allowed := make([]Method, 0, len(aclMethods))
+		verbs := make([]string, 0, len(aclMethods))
+		for _, aclMethod := range aclMethods {
+			if aclAllowed(aclMethod, client, isOwner, permissions) {
+				allowed = append(allowed, aclMethod)
+				verbs = append(verbs, service.methods.verb(aclMethod))
+			}
+		}
+		response.Header("Allow", allowHeader(&service.methods, allowed))
+		response.Data("methods", verbs)
+		return &response
+	}
+	if client.Identifier == nil {
+		response.ReplyForbidden("Guests may not access access control lists")
+		return &response
+	}
+
+	switch method {
+	case READ, READ_HEADERS:
+		if !aclAllowed(method, client, isOwner, permissions) {
+			response.ReplyForbidden("Insufficient permissions")
+			return &response
+		}
+		entries, err := service.permissionProvider.ListPermissionsOn(resourceId)
+		if err != nil {
+			panic(fmt.Errorf(
+				"Could not list permissions on '%s': %s",
+				resourceId.String(),
+				err,
+			))
+		}
+		list := make(map[string] []string)
+		for userId, entry := range entries {
+			list[aclUserName(userId)] = entry.Names()
+		}
+		owners := make([]string, 0)
+		ownerIds, err := service.ownerProvider.GetOwnersOf(resourceId)
+		if err == nil {
+			for _, ownerId := range ownerIds {
+				owners = append(owners, ownerId.String())
+			}
+		}
+		response.Data("owners", owners)
+		response.Data("permissions", list)
+		return &response
+
+	case UPDATE:
+		if !aclAllowed(method, client, isOwner, permissions) {
+			response.ReplyForbidden("Insufficient permissions")
+			return &response
+		}
+		user, err := parseAclUser(aclParameter(request, "user"))
+		if err != nil {
+			response.ReplyClientError("INVALID_USER", fmt.Sprintf("%s", err))
+			return &response
+		}
+		if user == GUESTS && resourceObj.DefaultPermissions().ForbidGuestGrants {
+			response.ReplyForbidden("Granting permissions to guests is forbidden")
+			return &response
+		}
+		granted := Permissions {}
+		names := aclParameter(request, "permissions")
+		if names != "" {
+			granted, err = service.customPermissions.parseNames(
+				strings.Split(names, ","),
+				declared,
+			)
+			if err != nil {
+				response.ReplyClientError("INVALID_PERMISSIONS", fmt.Sprintf("%s", err))
+				return &response
+			}
+		}
+		if !isOwner && !permissions.Covers(granted) {
+			response.ReplyForbidden("Granted permissions exceed own permissions")
+			return &response
+		}
+		if !isOwner {
+			//replacing an entry revokes the permissions it grants
+			existing, err := service.GetPermissionsFor(resourceId, user)
+			if err != nil {
+				if _, notFound := err.(NotFoundError); !notFound {
+					panic(fmt.Errorf(
+						"Could not get permissions on '%s': %s",
+						resourceId.String(),
+						err,
+					))
+				}
+			} else if !permissions.Covers(existing) {
+				response.ReplyForbidden("Replaced permissions exceed own permissions")
+				return &response
+			}
+		}
+		err = service.AssignPermissions(resourceId, user, granted)
+		if err != nil {
+			panic(fmt.Errorf(
+				"Could not assign permissions on '%s': %s",
+				resourceId.String(),
+				err,
+			))
+		}
+		return &response
+
+	case DELETE:
+		if !aclAllowed(method, client, isOwner, permissions) {
+			response.ReplyForbidden("Insufficient permissions")
+			return &response
+		}
+		user, err := parseAclUser(aclParameter(request, "user"))
+		if err != nil {
+			response.ReplyClientError("INVALID_USER", fmt.Sprintf("%s", err))
+			return &response
+		}
+		if !isOwner {
+			revoked, err := service.GetPermissionsFor(resourceId, user)
+			if err != nil {
+				switch err.(type) {
+				case NotFoundError:
+					response.ReplyNotFound("Permission entry not found")
+					return &response
+				default:
+					panic(fmt.Errorf(
+						"Could not get permissions on '%s': %s",
+						resourceId.String(),
+						err,
+					))
+				}
+			}
+			if !permissions.Covers(revoked) {
+				response.ReplyForbidden("Revoked permissions exceed own permissions")
+				return &response
+			}
+		}
+		err = service.RevokePermissions(resourceId, user)
+		if err != nil {
+			panic(fmt.Errorf(
+				"Could not revoke permissions on '%s': %s",
+				resourceId.String(),
+				err,
+			))
+		}
+		return &response
+	}
+
+	response.Header("Allow", allowHeader(&service.methods, aclMethods))
+	response.ReplyCustomError(
+		http.StatusMethodNotAllowed,
+		"METHOD_NOT_SUPPORTED",
+		"Method not supported on access control lists",
+	)
+	return &response
+}
@@ -0,0 +1,191 @@
+package apperix
+
+import (
+	"testing"
+	"net/http"
+)
+
+/*
+	aclTestService returns a service serving access control lists below "_acl"
+	of the resource "items", which forbids granting permissions to guests.
+*/
+func aclTestService(t *testing.T) (*Service, ResourceIdentifier) {
+	t.Helper()
+	service := newTestService(t, ServiceConfig {
+		Security: SecurityConfig {
+			AclPath: "_acl",
+		},
+		Resources: map[string] Resource {
+			"items": Resource {
+				Type: STATIC,
+				Name: "items",
+				Handlers: map[Method] Handler {
+					READ: okHandler,
+				},
+				Permissions: DefaultResourcePermissions {
+					ForbidGuestGrants: true,
+				},
+			},
+		},
+	})
+	resourceId, err := service.GetResourceIdentifier("items", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return service, resourceId
+}
+
+func TestAclOwnerGrantsListsAndRevokes(t *testing.T) {
+	service, resourceId := aclTestService(t)
+	ownerId, ownerToken := testUser(t, service, "owner")
+	userId, userToken := testUser(t, service, "user")
+	err := service.AssignOwner(resourceId, ownerId)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectStatus(t, testRequest(service, "GET", "/items", userToken), http.StatusForbidden)
+	recorder := testRequest(
+		service,
+		"PUT",
+		ConcatStrings("/items/_acl?user=", userId.String(), "&permissions=read"),
+		ownerToken,
+	)
+	expectStatus(t, recorder, http.StatusOK)
+	expectStatus(t, testRequest(service, "GET", "/items", userToken), http.StatusOK)
+
+	data := responseData(t, testRequest(service, "GET", "/items/_acl", ownerToken))
+	permissions, _ := data["permissions"].(map[string] interface{})
+	names, _ := permissions[userId.String()].([]interface{})
+	if len(names) != 1 || names[0] != "read" {
+		t.Errorf("Expected 'read' granted to user, got %v", data)
+	}
+	owners, _ := data["owners"].([]interface{})
+	if len(owners) != 1 || owners[0] != ownerId.String() {
+		t.Errorf("Expected owner listed, got %v", data)
+	}
+
+	recorder = testRequest(
+		service,
+		"DELETE",
+		ConcatStrings("/items/_acl?user=", userId.String()),
+		ownerToken,
+	)
+	expectStatus(t, recorder, http.StatusOK)
+	expectStatus(t, testRequest(service, "GET", "/items", userToken), http.StatusForbidden)
+}
+
+func TestAclGuardrails(t *testing.T) {
+	service, resourceId := aclTestService(t)
+	ownerId, ownerToken := testUser(t, service, "owner")
+	delegateId, delegateToken := testUser(t, service, "delegate")
+	otherId, _ := testUser(t, service, "other")
+	err := service.AssignOwner(resourceId, ownerId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = service.AssignPermissions(resourceId, delegateId, Permissions {
+		Read: true,
+		UpdateProperties: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = service.AssignPermissions(resourceId, otherId, Permissions {
+		Read: true,
+		Delete: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		description string
+		method string
+		target string
+		token string
+		status int
+	} {
+		{
+			"guests may not access access control lists",
+			"GET",
+			"/items/_acl",
+			"",
+			http.StatusForbidden,
+		},
+		{
+			"delegates lack read-properties",
+			"GET",
+			"/items/_acl",
+			delegateToken,
+			http.StatusForbidden,
+		},
+		{
+			"delegates may not grant permissions they lack",
+			"PUT",
+			ConcatStrings("/items/_acl?user=others&permissions=read,delete"),
+			delegateToken,
+			http.StatusForbidden,
+		},
+		{
+			"delegates may not replace entries exceeding their permissions",
+			"PUT",
+			ConcatStrings("/items/_acl?user=", otherId.String(), "&permissions=read"),
+			delegateToken,
+			http.StatusForbidden,
+		},
+		{
+			"delegates may not revoke entries exceeding their permissions",
+			"DELETE",
+			ConcatStrings("/items/_acl?user=", otherId.String()),
+			delegateToken,
+			http.StatusForbidden,
+		},
+		{
+			"delegates may not revoke missing entries",
+			"DELETE",
+			"/items/_acl?user=others",
+			delegateToken,
+			http.StatusNotFound,
+		},
+		{
+			"guest grants are forbidden by the resource",
+			"PUT",
+			"/items/_acl?user=guests&permissions=read",
+			ownerToken,
+			http.StatusForbidden,
+		},
+		{
+			"malformed users are rejected",
+			"PUT",
+			"/items/_acl?user=nobody&permissions=read",
+			ownerToken,
+			http.StatusBadRequest,
+		},
+		{
+			"unknown permissions are rejected",
+			"PUT",
+			"/items/_acl?user=others&permissions=fly",
+			ownerToken,
+			http.StatusBadRequest,
+		},
+		{
+			"delegates may grant permissions they have",
+			"PUT",
+			"/items/_acl?user=others&permissions=read",
+			delegateToken,
+			http.StatusOK,
+		},
+	} {
+		recorder := testRequest(service, test.method, test.target, test.token)
+		if recorder.Code != test.status {
+			t.Errorf(
+				"%s: expected status %d, got %d: %s",
+				test.description,
+				test.status,
+				recorder.Code,
+				recorder.Body.String(),
+			)
+		}
+	}
+}
@@ -0,0 +1,423 @@
+package apperix
+
+import (
+	"io"
+	"fmt"
+	"strings"
+	"encoding/json"
+)
+
+/*
+	The AclImportMode type represents an enumeration of the ways
+	imported ACL state is combined with the existing one.
+*/
+type AclImportMode int
+const (
+	//imported entries are added to existing ones, overwriting conflicting entries
+	ACL_MERGE AclImportMode = iota
+	//existing users, owners, ownership histories and permissions are removed before importing
+	ACL_REPLACE
+)
+
+type aclUserRecord struct {
+	Id string `json:"id"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+type aclTransferRecord struct {
+	From string `json:"from,omitempty"`
+	To string `json:"to"`
+	//seconds since the epoch
+	Time int64 `json:"time"`
+}
+
+type aclResourceRecord struct {
+	Resource string `json:"resource"`
+	Owner string `json:"owner,omitempty"`
+	CoOwners []string `json:"co-owners,omitempty"`
+	Permissions map[string] []string `json:"permissions,omitempty"`
+	History []aclTransferRecord `json:"history,omitempty"`
+}
+
+type aclDocument struct {
+	Users []aclUserRecord `json:"users"`
+	Resources []aclResourceRecord `json:"resources"`
+}
+
+/*
+	ParseResourceIdentifier returns the resource identifier
+	represented by the given serialized resource identifier
+	as returned by ResourceIdentifier.Serialize.
+	An error will be returned in case the serialized identifier doesn't
+	match the registered resources.
+*/
+func (service *Service) ParseResourceIdentifier(
+	serialized string,
+) (
+	resourceId ResourceIdentifier,
+	err error,
+) {
+	parts := strings.Split(serialized, "/")
+	identifier := parts[0]
+	values := parts[1:]
+	resources := service.resources()
+	resourceObj, exists := resources[identifier]
+	if !exists {
+		return resourceId, NotFoundError {
+			message: fmt.Sprintf(
+				"Resource identified by '%s' not found",
+				identifier,
+			),
+		}
+	}
+
+	//collect variable resources from root to the resource
+	variableIds := make([]string, 0)
+	for resourceObj != nil {
+		if _, isVariable := resourceObj.(*variableResource); isVariable {
+			variableIds = append([]string {resourceObj.Identifier()}, variableIds...)
+		}
+		resourceObj = resources[resourceObj.Parent()]
+	}
+	if len(variableIds) != len(values) {
+		return resourceId, fmt.Errorf(
+			"Wrong number of variable values (%d) for resource '%s', expected %d",
+			len(values),
+			identifier,
+			len(variableIds),
+		)
+	}
+	variables := make(map[string] string)
+	for index, variableId := range variableIds {
+		variables[variableId] = variableValueUnescaper.Replace(values[index])
+	}
+	return service.GetResourceIdentifier(identifier, variables)
+}
+
+/*
+	ExportAcl writes users, owners, ownership histories and permissions
+	of the service as JSON document to the given writer.
+	Entries of resources no longer registered are left out,
+	as they couldn't be imported.
+*/
+func (service *Service) ExportAcl(writer io.Writer) (err error) {
+	document := aclDocument {
+		Users: make([]aclUserRecord, 0),
+		Resources: make([]aclResourceRecord, 0),
+	}
+
+	//export users, shared users are exported by the service managing them
+	if service.users == nil {
+		userRows, err := service.database.Query(`
+			SELECT id, username, password FROM users ORDER BY username
+		`)
+		if err != nil {
+			return fmt.Errorf("Could not export users: %s", err)
+		}
+		for userRows.Next() {
+			var record aclUserRecord
+			var password []byte
+			err = userRows.Scan(&record.Id, &record.Username, &password)
+			if err != nil {
+				userRows.Close()
+				return fmt.Errorf("Could not export users: %s", err)
+			}
+			record.Password = string(password)
+			document.Users = append(document.Users, record)
+		}
+		userRows.Close()
+	}
+
+	//export resources
+	resourceRows, err := service.database.Query(`
+		SELECT id, str_id, owner_id FROM resources ORDER BY str_id
+	`)
+	if err != nil {
+		return fmt.Errorf("Could not export resources: %s", err)
+	}
+	ids := make([]int64, 0)
+	for resourceRows.Next() {
+		var id int64
+		var record aclResourceRecord
+		var owner *string
+		err = resourceRows.Scan(&id, &record.Resource, &owner)
+		if err != nil {
+			resourceRows.Close()
+			return fmt.Errorf("Could not export resources: %s", err)
+		}
+		//skip entries of resources no longer registered
+		if _, err := service.ParseResourceIdentifier(record.Resource); err != nil {
+			continue
+		}
+		if owner != nil {
+			record.Owner = *owner
+		}
+		record.Permissions = make(map[string] []string)
+		ids = append(ids, id)
+		document.Resources = append(document.Resources, record)
+	}
+	resourceRows.Close()
+
+	for index, id := range ids {
+		record := &document.Resources[index]
+		coOwnerRows, err := service.database.Query(`
+			SELECT owner_id FROM resource_owners
+			WHERE resource_id = ? ORDER BY owner_id
+		`, id)
+		if err != nil {
+			return fmt.Errorf("Could not export co-owners: %s", err)
+		}
+		for coOwnerRows.Next() {
+			var coOwner string
+			err = coOwnerRows.Scan(&coOwner)
+			if err != nil {
+				coOwnerRows.Close()
+				return fmt.Errorf("Could not export co-owners: %s", err)
+			}
+			record.CoOwners = append(record.CoOwners, coOwner)
+		}
+		coOwnerRows.Close()
+
+		historyRows, err := service.database.Query(`
+			SELECT from_id, to_id, transferred_at FROM ownership_history
+			WHERE resource_id = ? ORDER BY transferred_at, id
+		`, id)
+		if err != nil {
+			return fmt.Errorf("Could not export ownership history: %s", err)
+		}
+		for historyRows.Next() {
+			var transfer aclTransferRecord
+			var from *string
+			err = historyRows.Scan(&from, &transfer.To, &transfer.Time)
+			if err != nil {
+				historyRows.Close()
+				return fmt.Errorf("Could not export ownership history: %s", err)
+			}
+			if from != nil {
+				transfer.From = *from
+			}
+			record.History = append(record.History, transfer)
+		}
+		historyRows.Close()
+
+		permissionRows, err := service.database.Query(`
+			SELECT user_id, permissions FROM resource_permissions
+			WHERE resource_id = ?
+		`, id)
+		if err != nil {
+			return fmt.Errorf("Could not export permissions: %s", err)
+		}
+		for permissionRows.Next() {
+			var userId string
+			var encodedPermissions uint32
+			err = permissionRows.Scan(&userId, &encodedPermissions)
+			if err != nil {
+				permissionRows.Close()
+				return fmt.Errorf("Could not export permissions: %s", err)
+			}
+			permissions := service.customPermissions.deserialize(encodedPermissions)
+			record.Permissions[aclUserName(userId)] = permissions.Names()
+		}
+		permissionRows.Close()
+	}
+
+	encoder := json.NewEncoder(writer)
+	encoder.SetIndent("", "\t")
+	err = encoder.Encode(document)
+	if err != nil {
+		return fmt.Errorf("Could not write ACL document: %s", err)
+	}
+	return nil
+}
+
+/*
+	ImportAcl reads a JSON document as written by ExportAcl
+	and applies it in a single transaction using the given mode.
+	The whole document is validated against the registered resources
+	before any change is made, an error will be returned
+	in case it's invalid or an imported username is taken by another user.
+	The ownership history of a resource is replaced by the imported one
+	in case the document contains any.
+*/
+func (service *Service) ImportAcl(
+	reader io.Reader,
+	mode AclImportMode,
+) (
+	err error,
+) {
+	err = service.rejectDuringBatch()
+	if err != nil {
+		return err
+	}
+	var document aclDocument
+	err = json.NewDecoder(reader).Decode(&document)
+	if err != nil {
+		return fmt.Errorf("Could not parse ACL document: %s", err)
+	}
+
+	//validate document
+	if service.users != nil && len(document.Users) > 0 {
+		return fmt.Errorf("Users are managed by the shared user service")
+	}
+	for _, user := range document.Users {
+		if !userIdentifierPattern.MatchString(user.Id) {
+			return fmt.Errorf("Invalid user identifier '%s'", user.Id)
+		}
+		if len(user.Username) < 2 {
+			return fmt.Errorf("Username ('%s') is too short", user.Username)
+		}
+	}
+	resources := service.resources()
+	resourceIds := make([]ResourceIdentifier, len(document.Resources))
+	permissions := make([]map[string] Permissions, len(document.Resources))
+	for index, record := range document.Resources {
+		resourceIds[index], err = service.ParseResourceIdentifier(record.Resource)
+		if err != nil {
+			return fmt.Errorf("Invalid resource '%s': %s", record.Resource, err)
+		}
+		if record.Owner != "" && !userIdentifierPattern.MatchString(record.Owner) {
+			return fmt.Errorf("Invalid owner '%s' of '%s'", record.Owner, record.Resource)
+		}
+		for _, coOwner := range record.CoOwners {
+			if !userIdentifierPattern.MatchString(coOwner) {
+				return fmt.Errorf("Invalid co-owner '%s' of '%s'", coOwner, record.Resource)
+			}
+		}
+		for _, transfer := range record.History {
+			if (transfer.From != "" && !userIdentifierPattern.MatchString(transfer.From)) ||
+				!userIdentifierPattern.MatchString(transfer.To) {
+				return fmt.Errorf("Invalid ownership transfer of '%s'", record.Resource)
+			}
+		}
+		permissions[index] = make(map[string] Permissions)
+		for user, names := range record.Permissions {
+			target, err := parseAclUser(user)
+			if err != nil {
+				return fmt.Errorf("Invalid permissions of '%s': %s", record.Resource, err)
+			}
+			parsed, err := service.customPermissions.parseNames(
+				names,
+				customPermissionsOf(resources, resourceIds[index].Identifier()),
+			)
+			if err != nil {
+				return fmt.Errorf("Invalid permissions of '%s': %s", record.Resource, err)
+			}
+			permissions[index][verifyTargetUser(target)] = parsed
+		}
+	}
+
+	//apply document
+	txn, err := service.database.Begin()
+	if err != nil {
+		return fmt.Errorf("Could not begin import transaction: %s", err)
+	}
+	defer func() {
+		if err != nil {
+			txn.Rollback()
+		}
+	}()
+	if mode == ACL_REPLACE {
+		for _, table := range []string {
+			"resource_permissions",
+			"resource_owners",
+			"ownership_history",
+			"resources",
+			"users",
+		} {
+			_, err = txn.Exec(ConcatStrings("DELETE FROM ", table))
+			if err != nil {
+				return fmt.Errorf("Could not clear table '%s': %s", table, err)
+			}
+		}
+	}
+	for _, user := range document.Users {
+		//usernames have to stay unique across existing and imported users
+		var conflicting int
+		err = txn.QueryRow(`
+			SELECT COUNT(*) FROM users WHERE username = ? AND id != ?
+		`, user.Username, user.Id).Scan(&conflicting)
+		if err != nil {
+			return fmt.Errorf("Could not import user '%s': %s", user.Username, err)
+		}
+		if conflicting > 0 {
+			return fmt.Errorf("Username '%s' already taken by another user", user.Username)
+		}
+		_, err = txn.Exec(`
+			INSERT OR REPLACE INTO users
+			(id, username, password) VALUES (?,?,?)
+		`, user.Id, user.Username, []byte(user.Password))
+		if err != nil {
+			return fmt.Errorf("Could not import user '%s': %s", user.Username, err)
+		}
+	}
+	for index, record := range document.Resources {
+		resourceIdStr := resourceIds[index].Serialize()
+		_, err = txn.Exec(`
+			INSERT OR IGNORE INTO resources (str_id) VALUES (?)
+		`, resourceIdStr)
+		if err != nil {
+			return fmt.Errorf("Could not import resource '%s': %s", record.Resource, err)
+		}
+		if record.Owner != "" {
+			_, err = txn.Exec(`
+				UPDATE resources SET owner_id = ? WHERE str_id = ?
+			`, record.Owner, resourceIdStr)
+			if err != nil {
+				return fmt.Errorf("Could not import owner of '%s': %s", record.Resource, err)
+			}
+		}
+		for _, coOwner := range record.CoOwners {
+			_, err = txn.Exec(`
+				INSERT OR IGNORE INTO resource_owners (resource_id, owner_id)
+				VALUES ((SELECT id FROM resources WHERE str_id = ?), ?)
+			`, resourceIdStr, coOwner)
+			if err != nil {
+				return fmt.Errorf("Could not import co-owner of '%s': %s", record.Resource, err)
+			}
+		}
+		if len(record.History) > 0 {
+			_, err = txn.Exec(`
+				DELETE FROM ownership_history
+				WHERE resource_id = (SELECT id FROM resources WHERE str_id = ?)
+			`, resourceIdStr)
+			if err != nil {
+				return fmt.Errorf("Could not import ownership history of '%s': %s", record.Resource, err)
+			}
+		}
+		for _, transfer := range record.History {
+			var from *string
+			if transfer.From != "" {
+				from = &transfer.From
+			}
+			_, err = txn.Exec(`
+				INSERT INTO ownership_history
+				(resource_id, from_id, to_id, transferred_at)
+				VALUES ((SELECT id FROM resources WHERE str_id = ?), ?, ?, ?)
+			`, resourceIdStr, from, transfer.To, transfer.Time)
+			if err != nil {
+				return fmt.Errorf("Could not import ownership history of '%s': %s", record.Resource, err)
+			}
+		}
+		for userId, granted := range permissions[index] {
+			_, err = txn.Exec(`
+				INSERT OR REPLACE INTO resource_permissions
+				(resource_id, user_id, permissions)
+				VALUES ((SELECT id FROM resources WHERE str_id = ?), ?, ?)
+			`, resourceIdStr, userId, service.customPermissions.serialize(granted))
+			if err != nil {
+				return fmt.Errorf("Could not import permissions of '%s': %s", record.Resource, err)
+			}
+		}
+	}
+	err = txn.Commit()
+	if err != nil {
+		return fmt.Errorf("Could not commit import transaction: %s", err)
+	}
+
+	//drop all cached entries
+	service.userProvider.cache().Purge()
+	service.permissionProvider.cache().Purge()
+	service.ownerProvider.cache().Purge()
+	return nil
+}
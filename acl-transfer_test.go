@@ -0,0 +1,190 @@
+package apperix
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"net/http"
+)
+
+/*
+	transferTestConfig returns a configuration with the resources
+	"/items/{item}" where items declare the custom permission "approve".
+*/
+func transferTestConfig() ServiceConfig {
+	return ServiceConfig {
+		Resources: map[string] Resource {
+			"items": Resource {
+				Type: STATIC,
+				Name: "items",
+			},
+			"item": Resource {
+				Type: VARIABLE,
+				Parent: "items",
+				Pattern: "^[a-z/%]+$",
+				CustomPermissions: []string {"approve"},
+			},
+		},
+	}
+}
+
+/*
+	exportAcl returns the ACL document exported by the given service.
+*/
+func exportAcl(t *testing.T, service *Service) string {
+	t.Helper()
+	var buffer bytes.Buffer
+	err := service.ExportAcl(&buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buffer.String()
+}
+
+func TestAclExportImportRoundTrip(t *testing.T) {
+	source := newTestService(t, transferTestConfig())
+	itemId, err := source.GetResourceIdentifier("item", map[string] string {
+		"item": "a/b",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	aliceId, _ := testUser(t, source, "alice")
+	bobId, _ := testUser(t, source, "bob")
+	err = source.AssignOwner(itemId, aliceId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = source.TransferOwnership(itemId, aliceId, bobId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = source.AddCoOwner(itemId, aliceId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = source.AssignPermissions(itemId, OTHERS, Permissions {
+		Read: true,
+		Custom: map[string] bool {
+			"approve": true,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	document := exportAcl(t, source)
+
+	target := newTestService(t, transferTestConfig())
+	testUser(t, target, "carol")
+	err = target.ImportAcl(strings.NewReader(document), ACL_REPLACE)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exported := exportAcl(t, target); exported != document {
+		t.Errorf("Expected identical export after import, got %s, expected %s", exported, document)
+	}
+
+	//imported users authenticate with their passwords, replaced ones are gone
+	recorder := testRequest(target, "GET", "/auth?username=alice&password=password", "")
+	expectStatus(t, recorder, http.StatusOK)
+	recorder = testRequest(target, "GET", "/auth?username=carol&password=password", "")
+	expectStatus(t, recorder, http.StatusForbidden)
+
+	owners, err := target.GetOwnersOf(itemId)
+	if err != nil || len(owners) != 2 || owners[0].String() != bobId.String() {
+		t.Errorf("Expected bob as owner and alice as co-owner, got %v (%v)", owners, err)
+	}
+	history, err := target.GetOwnershipHistory(itemId)
+	if err != nil || len(history) != 2 || history[1].To.String() != bobId.String() {
+		t.Errorf("Expected imported ownership history, got %v (%v)", history, err)
+	}
+	permissions, err := target.GetPermissionsFor(itemId, OTHERS)
+	if err != nil || !permissions.Read || !permissions.Custom["approve"] {
+		t.Errorf("Expected imported permissions, got %v (%v)", permissions, err)
+	}
+
+	//merging the same document keeps the ownership history as is
+	err = target.ImportAcl(strings.NewReader(document), ACL_MERGE)
+	if err != nil {
+		t.Fatal(err)
+	}
+	history, err = target.GetOwnershipHistory(itemId)
+	if err != nil || len(history) != 2 {
+		t.Errorf("Expected merged ownership history to stay, got %v (%v)", history, err)
+	}
+}
+
+func TestAclExportSkipsUnregisteredResources(t *testing.T) {
+	service := newTestService(t, transferTestConfig())
+	_, err := service.database.Exec(`
+		INSERT INTO resources (str_id) VALUES ('removed'), ('items')
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	document := exportAcl(t, service)
+	if strings.Contains(document, "removed") || !strings.Contains(document, "items") {
+		t.Errorf("Expected only registered resources to be exported, got %s", document)
+	}
+	err = service.ImportAcl(strings.NewReader(document), ACL_REPLACE)
+	if err != nil {
+		t.Errorf("Expected exported document to be importable: %s", err)
+	}
+}
+
+func TestAclImportRejectsInvalidDocuments(t *testing.T) {
+	service := newTestService(t, transferTestConfig())
+	testUser(t, service, "user")
+	for _, test := range []struct {
+		description string
+		document string
+		mode AclImportMode
+	} {
+		{
+			"malformed JSON",
+			`{"users": [`,
+			ACL_REPLACE,
+		},
+		{
+			"unregistered resource",
+			`{"users": [], "resources": [{"resource": "removed"}]}`,
+			ACL_REPLACE,
+		},
+		{
+			"missing variable value",
+			`{"users": [], "resources": [{"resource": "item"}]}`,
+			ACL_REPLACE,
+		},
+		{
+			"invalid owner",
+			`{"users": [], "resources": [{"resource": "items", "owner": "nobody"}]}`,
+			ACL_REPLACE,
+		},
+		{
+			"invalid ownership transfer",
+			`{"users": [], "resources": [{"resource": "items", "history": [{"to": "nobody"}]}]}`,
+			ACL_REPLACE,
+		},
+		{
+			"custom permission not declared by the resource",
+			`{"users": [], "resources": [{"resource": "items", "permissions": {"others": ["approve"]}}]}`,
+			ACL_REPLACE,
+		},
+		{
+			"username taken by another user",
+			`{"users": [{"id": "00000000000000000000000000000001", "username": "user", "password": "x"}],
+			"resources": []}`,
+			ACL_MERGE,
+		},
+	} {
+		err := service.ImportAcl(strings.NewReader(test.document), test.mode)
+		if err == nil {
+			t.Errorf("%s: expected import to fail", test.description)
+		}
+	}
+
+	//failed imports don't change anything
+	if _, err := service.FindUserByUsername("user"); err != nil {
+		t.Errorf("Expected existing user to be kept: %s", err)
+	}
+}
@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"time"
 	"strconv"
-	"regexp"
 	"net"
 	"net/http"
 	"database/sql"
@@ -34,14 +33,41 @@ const (
 	CREATE_COLLECTION
 )
 
+/*
+	methodVerbs holds the HTTP method of each method.
+*/
+var methodVerbs = []string {
+	"POST",
+	"GET",
+	"PUT",
+	"DELETE",
+	"PATCH",
+	"HEAD",
+	"OPTIONS",
+	"PURGE",
+	"COPY",
+	"MOVE",
+	"LINK",
+	"UNLINK",
+	"LOCK",
+	"UNLOCK",
+	"PROPFIND",
+	"PROPPATCH",
+	"MKCOL",
+}
+
 type ResourceType int
 const (
 	STATIC ResourceType = iota
 	VARIABLE
+	//variable resource consuming the rest of the path
+	WILDCARD
 )
 
 type Client struct {
 	Identifier *Identifier
+	//request being served, nil outside of request handling
+	request *Request
 }
 
 type Handler func(*Client, *Request, *Service) Response
@@ -57,6 +83,8 @@ type DefaultResourcePermissions struct {
 	UserPermissions Permissions
 	GuestPermissions Permissions
 	Inheritance PermissionInheritance
+	//forbids granting permissions to GUESTS through the ACL resource
+	ForbidGuestGrants bool
 }
 
 type Resource struct {
@@ -67,6 +95,28 @@ type Resource struct {
 	MaxUploadSize int64
 	Type ResourceType
 	Pattern string
+	//kind of the values of a variable resource, verified in addition to the pattern
+	Kind VariableKind
+	//variable siblings with higher priority are matched first
+	Priority int
+	//names of application defined permissions checkable
+	//on this resource and its descendants
+	CustomPermissions []string
+	//optional attribute based policy evaluated on each request
+	Policy Policy
+	//forget ownership and permissions of the resource and its descendants
+	//after DELETE handlers succeeded
+	ForgetOnDelete bool
+	//ownership and permissions assigned to new instances of the resource,
+	//atomically by Service.CreateResource or after handlers replying
+	//ResponseJson.ReplyCreatedResource
+	OnCreate *CreationTemplate
+	//optional request and response schemas per method for API documentation
+	Schemas map[Method] OperationSchema
+	//handlers added or replaced by API versions mapped by version name,
+	//each version inherits the handlers of the previous one
+	//and nil handlers remove inherited ones
+	VersionHandlers map[string] map[Method] Handler
 }
 
 type HashAlgorithm int
@@ -82,11 +132,50 @@ const (
 	ES512
 )
 
+/*
+	DocumentationConfig bundles API documentation related configurations.
+*/
+type DocumentationConfig struct {
+	//name of the root level resource serving the OpenAPI document,
+	//the document is not served if empty
+	OpenApiPath string
+	Title string
+	Version string
+}
+
+/*
+	NormalizationConfig bundles request path normalization related configurations.
+*/
+type NormalizationConfig struct {
+	//treatment of paths differing from their canonical path
+	Policy PathPolicy
+	//match static resource names ignoring case
+	CaseInsensitive bool
+}
+
+/*
+	VersioningConfig bundles API versioning related configurations.
+*/
+type VersioningConfig struct {
+	//API versions in ascending order, like "v1" and "v2"
+	Versions []string
+	//version serving requests not selecting one, the first version if empty
+	Default string
+	//select versions by the first path segment, like "/v2/users"
+	Prefix bool
+	//name of a request header selecting the version, like "Api-Version"
+	Header string
+	//name of an Accept header media type parameter selecting the version,
+	//like "version" in "application/json; version=v2"
+	AcceptParameter string
+}
+
 /*
 	AuthenticationConfig bundles database related configurations.
 */
 type DatabaseConfig struct {
 	Location string
+	//number of entries in each cache, defaults are used if zero
 	Cache int
 }
 
@@ -105,6 +194,8 @@ type AuthenticationConfig struct {
 type NetworkConfig struct {
 	HttpPort uint16
 	HttpsPort uint16
+	//external base URL used to build absolute URLs, like "https://example.com/api"
+	BaseUrl string
 }
 
 /*
@@ -115,6 +206,9 @@ type SecurityConfig struct {
 	Certificate string
 	PrivateKey string
 	HashAlgorithm HashAlgorithm
+	//name of the ACL sub-resource available on every resource,
+	//the ACL resource is disabled if empty
+	AclPath string
 }
 
 /*
@@ -137,6 +231,16 @@ type ServiceConfig struct {
 	Security SecurityConfig
 	Defaults DefaultsConfig
 	Resources map[string] Resource
+	//optional function receiving all authorization decisions
+	Audit func(AuditEntry)
+	Documentation DocumentationConfig
+	//application defined HTTP methods
+	CustomMethods []CustomMethod
+	Versioning VersioningConfig
+	Normalization NormalizationConfig
+	//optional service which user accounts and access tokens are shared,
+	//the own database and signature secret are used if nil
+	SharedUsers *Service
 }
 
 
@@ -144,7 +248,7 @@ type ServiceConfig struct {
 	setupDatabase sets up the given database
 	creating required tables if necessary.
 */
-func setupDatabase(database *sql.DB) {
+func setupDatabase(database *sql.DB) error {
 	_, err := database.Exec(`
 		CREATE TABLE IF NOT EXISTS resources (
 			id INTEGER PRIMARY KEY,
@@ -153,7 +257,7 @@ func setupDatabase(database *sql.DB) {
 		);
 	`)
 	if err != nil {
-		panic(fmt.Errorf("Could not setup table: 'resources': %s", err))
+		return fmt.Errorf("Could not setup table: 'resources': %s", err)
 	}
 	_, err = database.Exec(`
 		CREATE TABLE IF NOT EXISTS resource_permissions (
@@ -164,7 +268,29 @@ func setupDatabase(database *sql.DB) {
 		);
 	`)
 	if err != nil {
-		panic(fmt.Errorf("Could not setup table: 'resource_permissions': %s", err))
+		return fmt.Errorf("Could not setup table: 'resource_permissions': %s", err)
+	}
+	_, err = database.Exec(`
+		CREATE TABLE IF NOT EXISTS resource_owners (
+			resource_id INTEGER,
+			owner_id BLOB,
+			PRIMARY KEY(resource_id, owner_id)
+		);
+	`)
+	if err != nil {
+		return fmt.Errorf("Could not setup table: 'resource_owners': %s", err)
+	}
+	_, err = database.Exec(`
+		CREATE TABLE IF NOT EXISTS ownership_history (
+			id INTEGER PRIMARY KEY,
+			resource_id INTEGER,
+			from_id BLOB,
+			to_id BLOB NOT NULL,
+			transferred_at INTEGER NOT NULL
+		);
+	`)
+	if err != nil {
+		return fmt.Errorf("Could not setup table: 'ownership_history': %s", err)
 	}
 	_, err = database.Exec(`
 		CREATE TABLE IF NOT EXISTS users (
@@ -175,7 +301,17 @@ func setupDatabase(database *sql.DB) {
 		);
 	`)
 	if err != nil {
-		panic(fmt.Errorf("Could not setup table: 'users': %s", err))
+		return fmt.Errorf("Could not setup table: 'users': %s", err)
+	}
+
+	_, err = database.Exec(`
+		CREATE TABLE IF NOT EXISTS custom_permissions (
+			name TEXT PRIMARY KEY,
+			bit INTEGER UNIQUE NOT NULL
+		);
+	`)
+	if err != nil {
+		return fmt.Errorf("Could not setup table: 'custom_permissions': %s", err)
 	}
 
 	_, err = database.Exec(`
@@ -183,7 +319,7 @@ func setupDatabase(database *sql.DB) {
 		ON resources (str_id);
 	`)
 	if err != nil {
-		panic(fmt.Errorf("Could not create index: 'resources.resource_strid': %s", err))
+		return fmt.Errorf("Could not create index: 'resources.resource_strid': %s", err)
 	}
 
 	_, err = database.Exec(`
@@ -191,8 +327,9 @@ func setupDatabase(database *sql.DB) {
 		ON users (username);
 	`)
 	if err != nil {
-		panic(fmt.Errorf("Could not create index: 'users.username': %s", err))
+		return fmt.Errorf("Could not create index: 'users.username': %s", err)
 	}
+	return nil
 }
 
 /*
@@ -213,180 +350,141 @@ func prepareDatabase(
 	)
 	database, err = sql.Open("sqlite3", databasePath)
 	if err != nil {
-		panic(fmt.Errorf("Could not open database %s, reason: %s", databasePath, err))
+		return nil, fmt.Errorf("Could not open database %s, reason: %s", databasePath, err)
 	}
-	setupDatabase(database)
-	return database, err
+	err = setupDatabase(database)
+	if err != nil {
+		database.Close()
+		return nil, err
+	}
+	return database, nil
 }
 
 /*
-	CreateService constructs and returns an initialized apperix service.
-
-	CAUTION: Faulty configuration will cause panic!
+	loadCertificate reads and parses the certificate and private key
+	referenced by the given security configuration.
 */
-func CreateService(conf ServiceConfig) (
-	service *Service,
+func loadCertificate(conf SecurityConfig) (
+	cert []byte,
+	pkey []byte,
+	certificate tls.Certificate,
+	problems []error,
 ) {
-	service = &Service {
-		resources: make(map[string] resourceObject),
-		Config: configuration {
-			name: conf.Name,
-			https: conf.Security.Https,
-			authConfig: conf.Authentication,
-			networkConfig: conf.Network,
-			defaultsConfig: conf.Defaults,
-		},
-	}
-	service.shutdownRequested = false
-	service.shutdownSignal = make(chan int)
-	service.resources["root"] = &staticResource {
-		identifier: "root",
-		name: "",
-		defaultPermissions: DefaultResourcePermissions {},
-		handlers: make(map[Method] Handler),
-		staticChildren: make(map[string] string),
-		variableChildren: make([]string, 0),
+	//read certificate
+	cert, err := ioutil.ReadFile(conf.Certificate)
+	if err != nil {
+		problems = append(problems, fmt.Errorf(
+			"Could not load certificate from '%s': %s",
+			conf.Certificate,
+			err,
+		))
 	}
 
-	//prepare certificate and key
-	if conf.Security.Https {
-		//read certificate
-		cert, err := ioutil.ReadFile(conf.Security.Certificate)
-		if err != nil {
-			panic(fmt.Errorf(
-				"Could not load certificate from '%s': %s",
-				conf.Security.Certificate,
-				err,
-			))
-		}
+	//read private key
+	pkey, err = ioutil.ReadFile(conf.PrivateKey)
+	if err != nil {
+		problems = append(problems, fmt.Errorf(
+			"Could not load private key from '%s': %s",
+			conf.PrivateKey,
+			err,
+		))
+	}
+	if len(problems) > 0 {
+		return cert, pkey, certificate, problems
+	}
 
-		//read private key
-		pkey, err := ioutil.ReadFile(conf.Security.PrivateKey)
-		if err != nil {
-			panic(fmt.Errorf(
-				"Could not load private key from '%s': %s",
-				conf.Security.PrivateKey,
-				err,
-			))
-		}
+	certificate, err = tls.X509KeyPair(cert, pkey)
+	if err != nil {
+		problems = append(problems, fmt.Errorf(
+			"Could not parse TLS key-pair: %s",
+			err,
+		))
+	}
+	return cert, pkey, certificate, problems
+}
 
-		service.Config.certificate = cert
-		service.Config.privateKey = pkey
+/*
+	cacheSizes returns the sizes of the user, permission and owner caches.
+	The configured cache size is used for all caches if given.
+*/
+func cacheSizes(conf DatabaseConfig) (
+	users int,
+	permissions int,
+	owners int,
+) {
+	if conf.Cache > 0 {
+		return conf.Cache, conf.Cache, conf.Cache
 	}
+	return 100, 1000, 1000
+}
 
-	//register identifiers
-	tmpIdRegistry := map[string] bool {}
-	tmpIdRegistry["root"] = true
-	for identifier, _ := range conf.Resources {
-		tmpIdRegistry[identifier] = true
+/*
+	buildResourceTree constructs the resource tree
+	of the given, already validated, configuration.
+*/
+func buildResourceTree(conf ServiceConfig) (
+	resources map[string] resourceObject,
+	err error,
+) {
+	resources = make(map[string] resourceObject)
+	root := Resource {
+		Type: STATIC,
+	}
+	if configured, exists := conf.Resources["root"]; exists {
+		root = configured
+		root.Name = ""
+		root.Parent = ""
+	}
+	resources["root"], err = newResourceObject("root", root, conf.Versioning.Versions)
+	if err != nil {
+		return nil, err
 	}
 
-	//verify resources
-	var has bool
-	for identifier, resource := range conf.Resources {
+	//create resources
+	identifiers := sortedResourceIdentifiers(conf.Resources)
+	for _, identifier := range identifiers {
+		if identifier == "root" {
+			continue
+		}
+		resource := conf.Resources[identifier]
 		if resource.Parent == "" {
 			resource.Parent = "root"
 		}
-		//verify parent resource
-		if _, parentRegistered := tmpIdRegistry[resource.Parent]; !parentRegistered {
-			panic(fmt.Errorf("Resource '%s' referenced unregistered parent resource ('%s')", identifier, resource.Parent))
-		}
-		//verify reserved identifier: "auth"
-		switch identifier {
-		case "auth":
-			panic(fmt.Errorf("Resource identifier 'auth' reserved"))
-		case "root":
-			service.resources["root"] = &staticResource {
-				identifier: "root",
-				name: "",
-				parent: "",
-				handlers: resource.Handlers,
-				defaultPermissions: resource.Permissions,
-				staticChildren: make(map[string] string),
-				variableChildren: make([]string, 0),
-			}
-		}
-
-		switch resource.Type {
-		case STATIC:
-			//verify name not registered
-			has = false
-			if service.resources[resource.Parent] != nil {
-				has = service.resources[resource.Parent].HasStaticChild(resource.Name)
-			}
-			if has {
-				if resource.Parent == "root" {
-					panic(fmt.Errorf("Duplicate static resource name ('%s') in parent root", resource.Name))
-				} else {
-					panic(fmt.Errorf("Duplicate static resource name ('%s') in parent '%s'", resource.Name, resource.Parent))
-				}
-			}
-			//verify no overlap with auth
-			if resource.Parent == "root" && resource.Name == conf.Authentication.Path {
-				panic(fmt.Errorf("Resource ('%s') overlaps with authentication path", identifier))
-			}
-			service.resources[identifier] = &staticResource {
-				identifier: identifier,
-				name: resource.Name,
-				parent: resource.Parent,
-				handlers: resource.Handlers,
-				defaultPermissions: resource.Permissions,
-				staticChildren: make(map[string] string),
-				variableChildren: make([]string, 0),
-			}
-		case VARIABLE:
-			//verify name not registered
-			has = false
-			if service.resources[resource.Parent] != nil {
-				has = service.resources[resource.Parent].HasVariableChild(resource.Name)
-			}
-			if has {
-				if resource.Parent == "root" {
-					panic(fmt.Errorf("Duplicate variable resource name ('%s') in parent root", resource.Name))
-				} else {
-					panic(fmt.Errorf("Duplicate variable resource name ('%s') in parent '%s'", resource.Name, resource.Parent))
-				}
-			}
-			//compile regexp
-			regex, err := regexp.Compile(resource.Pattern)
-			if err != nil {
-				panic(fmt.Errorf("Pattern regex ('%s') compilation for resource ('%s') failed", resource.Pattern, identifier))
-			}
-			service.resources[identifier] = &variableResource {
-				identifier: identifier,
-				name: resource.Name,
-				parent: resource.Parent,
-				handlers: resource.Handlers,
-				defaultPermissions: resource.Permissions,
-				staticChildren: make(map[string] string),
-				variableChildren: make([]string, 0),
-				pattern: *regex,
-			}
-		default:
-			panic(fmt.Errorf("Wrong resource type (%d)", resource.Type))
+		resources[identifier], err = newResourceObject(identifier, resource, conf.Versioning.Versions)
+		if err != nil {
+			return nil, err
 		}
 	}
 
 	//set children
-	for identifier, resource := range conf.Resources {
+	for _, identifier := range identifiers {
+		if identifier == "root" {
+			continue
+		}
+		resource := conf.Resources[identifier]
 		if resource.Parent == "" {
 			resource.Parent = "root"
 		}
 		switch resource.Type {
 		case STATIC:
-			service.resources[resource.Parent].DefineStaticChild(identifier, resource.Name)
-		case VARIABLE:
-			service.resources[resource.Parent].DefineVariableChild(identifier)
+			resources[resource.Parent].DefineStaticChild(identifier, resource.Name)
+		case VARIABLE, WILDCARD:
+			resources[resource.Parent].DefineVariableChild(identifier)
 		}
 	}
+	for _, resourceObj := range resources {
+		orderVariableChildren(resourceObj, resources)
+	}
 
 	//prepare authentication resource
-	service.resources["auth"] = &staticResource {
+	resources["auth"] = &staticResource {
 		identifier: "auth",
-		name: conf.Authentication.Path,
+		name: "auth",
 		parent: "root",
-		handlers: map[Method] Handler {
-			READ: authReadHandler,
+		handlers: []map[Method] Handler {
+			{
+				READ: authReadHandler,
+			},
 		},
 		defaultPermissions: DefaultResourcePermissions {
 			UserPermissions: Permissions {
@@ -400,19 +498,163 @@ func CreateService(conf ServiceConfig) (
 		staticChildren: make(map[string] string),
 		variableChildren: make([]string, 0),
 	}
-	service.resources["root"].DefineStaticChild("auth", "auth")
+	resources["root"].DefineStaticChild("auth", "auth")
+
+	//prepare documentation resource
+	if conf.Documentation.OpenApiPath != "" {
+		resources["openapi"] = &staticResource {
+			identifier: "openapi",
+			name: conf.Documentation.OpenApiPath,
+			parent: "root",
+			handlers: []map[Method] Handler {
+				{
+					READ: openApiReadHandler,
+				},
+			},
+			defaultPermissions: DefaultResourcePermissions {
+				UserPermissions: Permissions {
+					Read: true,
+				},
+				GuestPermissions: Permissions {
+					Read: true,
+				},
+				Inheritance: PermissionInheritance {},
+			},
+			staticChildren: make(map[string] string),
+			variableChildren: make([]string, 0),
+		}
+		resources["root"].DefineStaticChild("openapi", conf.Documentation.OpenApiPath)
+	}
+	return resources, nil
+}
+
+/*
+	NewService constructs and returns an initialized apperix service.
+	In case of faulty configuration a ConfigError listing
+	all problems found will be returned.
+*/
+func NewService(conf ServiceConfig) (
+	service *Service,
+	err error,
+) {
+	problems := ValidateConfig(conf)
+	if len(problems) > 0 {
+		return nil, ConfigError {
+			Errors: problems,
+		}
+	}
+
+	service = &Service {
+		Config: configuration {
+			name: conf.Name,
+			https: conf.Security.Https,
+			networkConfig: conf.Network,
+			normalizationConfig: conf.Normalization,
+		},
+		loadedConfig: conf,
+	}
+	service.auditor = conf.Audit
+	if conf.SharedUsers != nil {
+		service.users = conf.SharedUsers.accounts()
+	}
+	service.shutdownRequested = false
+	service.shutdownSignal = make(chan int)
+
+	//prepare certificate and key
+	var certificate tls.Certificate
+	if conf.Security.Https {
+		cert, pkey, loaded, certificateProblems := loadCertificate(conf.Security)
+		service.Config.certificate = cert
+		service.Config.privateKey = pkey
+		certificate = loaded
+		problems = append(problems, certificateProblems...)
+	}
+	service.Config.reloadable.Store(&reloadableConfiguration {
+		aclPath: conf.Security.AclPath,
+		authConfig: conf.Authentication,
+		defaultsConfig: conf.Defaults,
+		documentationConfig: conf.Documentation,
+		certificate: &certificate,
+	})
+
+	//build resource tree
+	customPermissions, err := newCustomPermissionSet(conf.Resources)
+	if err != nil {
+		problems = append(problems, err)
+	}
+	service.customPermissions = customPermissions
+	service.methods, err = newMethodRegistry(conf.CustomMethods, customPermissions)
+	if err != nil {
+		problems = append(problems, err)
+	}
+	service.versions, err = newVersioning(conf.Versioning)
+	if err != nil {
+		problems = append(problems, err)
+	}
+	resources, err := buildResourceTree(conf)
+	if err != nil {
+		problems = append(problems, err)
+	}
+	err = service.storeTree(resources)
+	if err != nil {
+		problems = append(problems, err)
+	}
+	if len(problems) > 0 {
+		return nil, ConfigError {
+			Errors: problems,
+		}
+	}
 
 	//prepare database
 	database, err := prepareDatabase(conf.Database.Location, conf.Name)
 	if err != nil {
-		panic(fmt.Errorf("Could not prepare database: %s", err))
+		return nil, ConfigError {
+			Errors: []error {
+				fmt.Errorf("Could not prepare database: %s", err),
+			},
+		}
 	}
 	service.database = database
 
+	//assign persistent bits to custom permissions
+	err = service.customPermissions.assignBits(database)
+	if err != nil {
+		database.Close()
+		return nil, ConfigError {
+			Errors: []error {
+				fmt.Errorf("Could not assign custom permission bits: %s", err),
+			},
+		}
+	}
+
+	//escape variable values of legacy resource identifiers
+	err = migrateResourceIdentifiers(database, resources)
+	if err != nil {
+		database.Close()
+		return nil, ConfigError {
+			Errors: []error {
+				fmt.Errorf("Could not migrate resource identifiers: %s", err),
+			},
+		}
+	}
+
 	//initialize caches
-	service.userProvider.initialize(database, 100)
-	service.permissionProvider.initialize(database, 1000)
-	service.ownerProvider.initialize(database, 1000)
+	userCacheSize, permissionCacheSize, ownerCacheSize := cacheSizes(conf.Database)
+	for _, err = range []error {
+		service.userProvider.initialize(database, userCacheSize),
+		service.permissionProvider.initialize(database, permissionCacheSize, service.customPermissions),
+		service.ownerProvider.initialize(database, ownerCacheSize),
+	} {
+		if err != nil {
+			problems = append(problems, err)
+		}
+	}
+	if len(problems) > 0 {
+		database.Close()
+		return nil, ConfigError {
+			Errors: problems,
+		}
+	}
 
 	//initialize server
 	port := conf.Network.HttpPort
@@ -429,13 +671,6 @@ func CreateService(conf ServiceConfig) (
 		MaxHeaderBytes: 1 << 20,
 	}
 	if conf.Security.Https {
-		certificate, err := tls.X509KeyPair(
-			service.Config.certificate,
-			service.Config.privateKey,
-		)
-		if err != nil {
-			panic(fmt.Errorf("Could not parse TLS key-pair: %s", err))
-		}
 		service.server.TLSConfig = &tls.Config {
 			CipherSuites: []uint16 {
 				tls.TLS_RSA_WITH_RC4_128_SHA,
@@ -446,11 +681,28 @@ func CreateService(conf ServiceConfig) (
 				tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA,
 			},
 			ServerName: "qube-AAABBBCCC",
-			Certificates: [] tls.Certificate {
-				certificate,
+			//certificates can be replaced on reload
+			GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+				return service.Config.current().certificate, nil
 			},
 		}
 	}
 
+	return service, nil
+}
+
+/*
+	CreateService constructs and returns an initialized apperix service.
+
+	CAUTION: Faulty configuration will cause panic!
+	Use NewService to handle configuration errors.
+*/
+func CreateService(conf ServiceConfig) (
+	service *Service,
+) {
+	service, err := NewService(conf)
+	if err != nil {
+		panic(err)
+	}
 	return service
-}
\ No newline at end of file
+}
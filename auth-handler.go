@@ -38,16 +38,17 @@ func authReadHandler(client *Client, request *Request, service *Service) Respons
 		return &response
 	}
 	//generate token
+	accounts := service.accounts()
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
 		"iss": account.Identifier.String(),
 		"iat": time.Now().UTC().Format("2006-01-02T15:04:05-0700"),
-		"lft": service.Config.AccessTokenLiveTime().Seconds(),
+		"lft": accounts.Config.AccessTokenLiveTime().Seconds(),
 	})
-	tokenString, err := token.SignedString(service.Config.JwtSignatureSecret())
+	tokenString, err := token.SignedString(accounts.Config.JwtSignatureSecret())
 	if err != nil {
 		panic(fmt.Errorf("Could not sign token: %s", err))
 	}
 	response.Data("access-token", tokenString)
-	response.Data("life-time", service.Config.AccessTokenLiveTime().Seconds())
+	response.Data("life-time", accounts.Config.AccessTokenLiveTime().Seconds())
 	return &response
 }
\ No newline at end of file
@@ -0,0 +1,319 @@
+package apperix
+
+import (
+	"fmt"
+	"sync/atomic"
+	"database/sql"
+)
+
+type batchPermissionEntry struct {
+	resourceId ResourceIdentifier
+	userId string
+}
+
+/*
+	The Batch type collects permission and ownership changes
+	applied within a single database transaction.
+	A batch is only valid within the function passed to Service.Batch.
+*/
+type Batch struct {
+	service *Service
+	txn *sql.Tx
+	touchedPermissions []batchPermissionEntry
+	touchedOwners []ResourceIdentifier
+}
+
+/*
+	ensureResource inserts the resource entry in case it's missing.
+*/
+func (batch *Batch) ensureResource(resourceIdStr string) error {
+	_, err := batch.txn.Exec(`
+		INSERT OR IGNORE INTO resources (str_id) VALUES (?)
+	`, resourceIdStr)
+	return err
+}
+
+/*
+	AssignPermissions assigns provided permissions
+	for the given user on the given resource within the batch.
+
+	CAUTION: passing user identifier of unsupported type will cause panic!
+*/
+func (batch *Batch) AssignPermissions(
+	resourceId ResourceIdentifier,
+	user interface{},
+	permissions Permissions,
+) (
+	err error,
+) {
+	userId := verifyTargetUser(user)
+	resourceIdStr := resourceId.Serialize()
+	err = batch.ensureResource(resourceIdStr)
+	if err != nil {
+		return fmt.Errorf(
+			"Failed registering permissions in database: %s",
+			err,
+		)
+	}
+	_, err = batch.txn.Exec(`
+		INSERT OR REPLACE INTO resource_permissions
+		(resource_id, user_id, permissions)
+		VALUES (
+			(SELECT id FROM resources WHERE str_id = ?),
+			?,?
+		)
+	`,
+		resourceIdStr,
+		userId,
+		batch.service.customPermissions.serialize(permissions),
+	)
+	if err != nil {
+		return fmt.Errorf(
+			"Failed registering permissions in database: %s",
+			err,
+		)
+	}
+	batch.touchedPermissions = append(
+		batch.touchedPermissions,
+		batchPermissionEntry {resourceId, userId},
+	)
+	return nil
+}
+
+/*
+	RevokePermissions revokes permission entries
+	for the given user on the given resource within the batch.
+
+	CAUTION: passing user identifier of unsupported type will cause panic!
+*/
+func (batch *Batch) RevokePermissions(
+	resourceId ResourceIdentifier,
+	user interface{},
+) (
+	err error,
+) {
+	userId := verifyTargetUser(user)
+	_, err = batch.txn.Exec(`
+		DELETE FROM resource_permissions
+		WHERE resource_id = (SELECT id FROM resources WHERE str_id = ?)
+		AND user_id = ?
+	`,
+		resourceId.Serialize(),
+		userId,
+	)
+	if err != nil {
+		return fmt.Errorf(
+			"Failed to delete permission entry in database: %s",
+			err,
+		)
+	}
+	batch.touchedPermissions = append(
+		batch.touchedPermissions,
+		batchPermissionEntry {resourceId, userId},
+	)
+	return nil
+}
+
+/*
+	AssignOwner transfers ownership of the given resource
+	to the given user within the batch.
+*/
+func (batch *Batch) AssignOwner(
+	resourceId ResourceIdentifier,
+	userId Identifier,
+) (
+	err error,
+) {
+	userIdStr := userId.String()
+	resourceIdStr := resourceId.Serialize()
+	err = batch.ensureResource(resourceIdStr)
+	if err != nil {
+		return fmt.Errorf(
+			"Failed registering owner in database: %s",
+			err,
+		)
+	}
+	var previousOwner *string
+	err = batch.txn.QueryRow(`
+		SELECT owner_id FROM resources WHERE str_id = ?
+	`, resourceIdStr).Scan(&previousOwner)
+	if err != nil {
+		return fmt.Errorf(
+			"Failed registering owner in database: %s",
+			err,
+		)
+	}
+	_, err = batch.txn.Exec(`
+		UPDATE resources SET owner_id = ?
+		WHERE str_id = ?;
+	`, userIdStr, resourceIdStr)
+	if err != nil {
+		return fmt.Errorf(
+			"Failed registering owner in database: %s",
+			err,
+		)
+	}
+	if previousOwner == nil || *previousOwner != userIdStr {
+		err = recordOwnershipTransfer(
+			batch.txn,
+			resourceIdStr,
+			previousOwner,
+			userIdStr,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	batch.touchedOwners = append(batch.touchedOwners, resourceId)
+	return nil
+}
+
+/*
+	AddCoOwner registers the given user as co-owner
+	of the given resource within the batch.
+*/
+func (batch *Batch) AddCoOwner(
+	resourceId ResourceIdentifier,
+	userId Identifier,
+) (
+	err error,
+) {
+	resourceIdStr := resourceId.Serialize()
+	err = batch.ensureResource(resourceIdStr)
+	if err != nil {
+		return fmt.Errorf(
+			"Failed registering co-owner in database: %s",
+			err,
+		)
+	}
+	_, err = batch.txn.Exec(`
+		INSERT OR IGNORE INTO resource_owners (resource_id, owner_id)
+		VALUES (
+			(SELECT id FROM resources WHERE str_id = ?),
+			?
+		)
+	`, resourceIdStr, userId.String())
+	if err != nil {
+		return fmt.Errorf(
+			"Failed registering co-owner in database: %s",
+			err,
+		)
+	}
+	batch.touchedOwners = append(batch.touchedOwners, resourceId)
+	return nil
+}
+
+/*
+	RemoveCoOwner removes the given user from the co-owners
+	of the given resource within the batch.
+*/
+func (batch *Batch) RemoveCoOwner(
+	resourceId ResourceIdentifier,
+	userId Identifier,
+) (
+	err error,
+) {
+	_, err = batch.txn.Exec(`
+		DELETE FROM resource_owners
+		WHERE resource_id = (SELECT id FROM resources WHERE str_id = ?)
+		AND owner_id = ?
+	`, resourceId.Serialize(), userId.String())
+	if err != nil {
+		return fmt.Errorf(
+			"Failed to delete co-owner entry in database: %s",
+			err,
+		)
+	}
+	batch.touchedOwners = append(batch.touchedOwners, resourceId)
+	return nil
+}
+
+/*
+	invalidate removes all cache entries touched by the batch.
+*/
+func (batch *Batch) invalidate() {
+	for _, entry := range batch.touchedPermissions {
+		batch.service.permissionProvider.Invalidate(entry.resourceId, entry.userId)
+	}
+	for _, resourceId := range batch.touchedOwners {
+		batch.service.ownerProvider.Invalidate(resourceId)
+	}
+}
+
+/*
+	rejectDuringBatch returns an error in case a batch transaction is open.
+	Changes made outside of the batch would wait for its transaction
+	to end, which never happens when they are made by the batch function.
+*/
+func (service *Service) rejectDuringBatch() error {
+	if atomic.LoadInt32(&service.batching) != 0 {
+		return fmt.Errorf("Could not change the database while a batch is applied, use the batch instead")
+	}
+	return nil
+}
+
+/*
+	Batch applies all permission and ownership changes
+	made by the given function in a single database transaction.
+	All changes are rolled back in case the function returns an error
+	or panics, caches are invalidated once after the transaction committed.
+
+	CAUTION: the function must only change the database through the given batch.
+	While it runs, Service methods changing users, permissions or ownership
+	as well as nested batches return an error instead of blocking
+	on the open transaction, which applies to other goroutines too.
+	Creation templates and forgetting deleted resources
+	while serving requests wait for the batch instead.
+*/
+func (service *Service) Batch(
+	apply func(*Batch) error,
+) (
+	err error,
+) {
+	if atomic.LoadInt32(&service.batching) != 0 {
+		return fmt.Errorf("Could not begin batch transaction: another batch is applied")
+	}
+	return service.batch(apply)
+}
+
+/*
+	batch works like Batch, but waits for other batches to end.
+	Must not be called while applying a batch.
+*/
+func (service *Service) batch(
+	apply func(*Batch) error,
+) (
+	err error,
+) {
+	service.batchLock.Lock()
+	atomic.StoreInt32(&service.batching, 1)
+	defer func() {
+		atomic.StoreInt32(&service.batching, 0)
+		service.batchLock.Unlock()
+	}()
+	txn, err := service.database.Begin()
+	if err != nil {
+		return fmt.Errorf("Could not begin batch transaction: %s", err)
+	}
+	batch := &Batch {
+		service: service,
+		txn: txn,
+	}
+	committed := false
+	defer func() {
+		if !committed {
+			txn.Rollback()
+		}
+	}()
+	err = apply(batch)
+	if err != nil {
+		return err
+	}
+	err = txn.Commit()
+	if err != nil {
+		return fmt.Errorf("Could not commit batch transaction: %s", err)
+	}
+	committed = true
+	batch.invalidate()
+	return nil
+}
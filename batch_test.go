@@ -0,0 +1,127 @@
+package apperix
+
+import (
+	"fmt"
+	"testing"
+)
+
+/*
+	batchTestService returns a service with the resource "items"
+	along with a user to change permissions and ownership for.
+*/
+func batchTestService(t *testing.T) (*Service, ResourceIdentifier, Identifier) {
+	t.Helper()
+	service := newTestService(t, ServiceConfig {
+		Resources: map[string] Resource {
+			"items": Resource {
+				Type: STATIC,
+				Name: "items",
+			},
+		},
+	})
+	resourceId, err := service.GetResourceIdentifier("items", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	userId, _ := testUser(t, service, "user")
+	return service, resourceId, userId
+}
+
+func TestBatchCommitsAndInvalidatesCaches(t *testing.T) {
+	service, resourceId, userId := batchTestService(t)
+	//warm up caches
+	isOwner, permissions, err := service.ResolvePermissionsFor(resourceId, &userId)
+	if err != nil || isOwner || permissions.Update {
+		t.Fatalf("Unexpected initial permissions %v (%v)", permissions, err)
+	}
+
+	err = service.Batch(func(batch *Batch) error {
+		err := batch.AssignPermissions(resourceId, userId, Permissions {
+			Read: true,
+			Update: true,
+		})
+		if err != nil {
+			return err
+		}
+		return batch.AssignOwner(resourceId, userId)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	isOwner, permissions, err = service.ResolvePermissionsFor(resourceId, &userId)
+	if err != nil || !isOwner || !permissions.Update {
+		t.Errorf("Expected batch changes to be visible, got %v (%v)", permissions, err)
+	}
+}
+
+func TestBatchRollsBack(t *testing.T) {
+	service, resourceId, userId := batchTestService(t)
+	assign := func(batch *Batch) {
+		err := batch.AssignPermissions(resourceId, userId, Permissions {
+			Update: true,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err := service.Batch(func(batch *Batch) error {
+		assign(batch)
+		return fmt.Errorf("Failure")
+	})
+	if err == nil || err.Error() != "Failure" {
+		t.Errorf("Expected error of the batch function, got %v", err)
+	}
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("Expected panic to be passed on")
+			}
+		}()
+		service.Batch(func(batch *Batch) error {
+			assign(batch)
+			panic("failure")
+		})
+	}()
+
+	_, err = service.GetPermissionsFor(resourceId, userId)
+	if _, isNotFound := err.(NotFoundError); !isNotFound {
+		t.Errorf("Expected rolled back permissions to be missing, got %v", err)
+	}
+}
+
+func TestBatchRejectsReentrantCalls(t *testing.T) {
+	service, resourceId, userId := batchTestService(t)
+	var serviceErr, nestedErr, userErr error
+	err := service.Batch(func(batch *Batch) error {
+		serviceErr = service.AssignPermissions(resourceId, userId, Permissions {
+			Read: true,
+		})
+		nestedErr = service.Batch(func(*Batch) error {
+			return nil
+		})
+		_, userErr = service.CreateUser("other", "password")
+		return batch.AssignPermissions(resourceId, userId, Permissions {
+			Update: true,
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if serviceErr == nil || nestedErr == nil || userErr == nil {
+		t.Errorf(
+			"Expected calls during the batch to be rejected, got %v, %v and %v",
+			serviceErr,
+			nestedErr,
+			userErr,
+		)
+	}
+
+	//the service accepts changes once the batch ended
+	err = service.AssignPermissions(resourceId, userId, Permissions {
+		Read: true,
+	})
+	if err != nil {
+		t.Errorf("Expected changes after the batch to succeed: %s", err)
+	}
+}
@@ -0,0 +1,341 @@
+package apperix
+
+import (
+	"os"
+	"fmt"
+	"time"
+	"strings"
+	"strconv"
+	"io/ioutil"
+	"path/filepath"
+	"encoding/json"
+	"gopkg.in/yaml.v2"
+	"github.com/BurntSushi/toml"
+)
+
+type databaseFileConfig struct {
+	Location string `json:"location" yaml:"location" toml:"location"`
+	Cache int `json:"cache" yaml:"cache" toml:"cache"`
+}
+
+type authenticationFileConfig struct {
+	Path string `json:"path" yaml:"path" toml:"path"`
+	TokenExpiry string `json:"token-expiry" yaml:"token-expiry" toml:"token-expiry"`
+	SignatureSecret string `json:"signature-secret" yaml:"signature-secret" toml:"signature-secret"`
+	SignatureSecretFile string `json:"signature-secret-file" yaml:"signature-secret-file" toml:"signature-secret-file"`
+}
+
+type networkFileConfig struct {
+	HttpPort uint16 `json:"http-port" yaml:"http-port" toml:"http-port"`
+	HttpsPort uint16 `json:"https-port" yaml:"https-port" toml:"https-port"`
+	BaseUrl string `json:"base-url" yaml:"base-url" toml:"base-url"`
+}
+
+type securityFileConfig struct {
+	Https bool `json:"https" yaml:"https" toml:"https"`
+	Certificate string `json:"certificate" yaml:"certificate" toml:"certificate"`
+	PrivateKey string `json:"private-key" yaml:"private-key" toml:"private-key"`
+	HashAlgorithm string `json:"hash-algorithm" yaml:"hash-algorithm" toml:"hash-algorithm"`
+	AclPath string `json:"acl-path" yaml:"acl-path" toml:"acl-path"`
+}
+
+type documentationFileConfig struct {
+	OpenApiPath string `json:"openapi-path" yaml:"openapi-path" toml:"openapi-path"`
+	Title string `json:"title" yaml:"title" toml:"title"`
+	Version string `json:"version" yaml:"version" toml:"version"`
+}
+
+type versioningFileConfig struct {
+	Versions []string `json:"versions" yaml:"versions" toml:"versions"`
+	Default string `json:"default" yaml:"default" toml:"default"`
+	Prefix bool `json:"prefix" yaml:"prefix" toml:"prefix"`
+	Header string `json:"header" yaml:"header" toml:"header"`
+	AcceptParameter string `json:"accept-parameter" yaml:"accept-parameter" toml:"accept-parameter"`
+}
+
+type normalizationFileConfig struct {
+	Policy string `json:"policy" yaml:"policy" toml:"policy"`
+	CaseInsensitive bool `json:"case-insensitive" yaml:"case-insensitive" toml:"case-insensitive"`
+}
+
+type defaultsFileConfig struct {
+	MaxUploadSize int64 `json:"max-upload-size" yaml:"max-upload-size" toml:"max-upload-size"`
+	UploadDirectory string `json:"upload-directory" yaml:"upload-directory" toml:"upload-directory"`
+	AutoCleanUploads bool `json:"auto-clean-uploads" yaml:"auto-clean-uploads" toml:"auto-clean-uploads"`
+}
+
+/*
+	fileConfig represents the configuration file layout,
+	durations and enumerations are represented by strings.
+*/
+type fileConfig struct {
+	Name string `json:"name" yaml:"name" toml:"name"`
+	Database databaseFileConfig `json:"database" yaml:"database" toml:"database"`
+	Authentication authenticationFileConfig `json:"authentication" yaml:"authentication" toml:"authentication"`
+	Network networkFileConfig `json:"network" yaml:"network" toml:"network"`
+	Security securityFileConfig `json:"security" yaml:"security" toml:"security"`
+	Defaults defaultsFileConfig `json:"defaults" yaml:"defaults" toml:"defaults"`
+	Documentation documentationFileConfig `json:"documentation" yaml:"documentation" toml:"documentation"`
+	Versioning versioningFileConfig `json:"versioning" yaml:"versioning" toml:"versioning"`
+	Normalization normalizationFileConfig `json:"normalization" yaml:"normalization" toml:"normalization"`
+}
+
+var hashAlgorithmNames = map[string] HashAlgorithm {
+	"HS256": HS256,
+	"HS384": HS384,
+	"HS512": HS512,
+	"RS256": RS256,
+	"RS384": RS384,
+	"RS512": RS512,
+	"ES256": ES256,
+	"ES384": ES384,
+	"ES512": ES512,
+}
+
+/*
+	envOverride describes a configuration value
+	overridable by an APPERIX_* environment variable.
+*/
+type envOverride struct {
+	name string
+	apply func(*fileConfig, string) error
+}
+
+func parseUint16(str string) (uint16, error) {
+	value, err := strconv.ParseUint(str, 10, 16)
+	return uint16(value), err
+}
+
+var envOverrides = []envOverride {
+	{"NAME", func(conf *fileConfig, value string) error {
+		conf.Name = value
+		return nil
+	}},
+	{"DATABASE_LOCATION", func(conf *fileConfig, value string) error {
+		conf.Database.Location = value
+		return nil
+	}},
+	{"DATABASE_CACHE", func(conf *fileConfig, value string) (err error) {
+		conf.Database.Cache, err = strconv.Atoi(value)
+		return err
+	}},
+	{"AUTHENTICATION_PATH", func(conf *fileConfig, value string) error {
+		conf.Authentication.Path = value
+		return nil
+	}},
+	{"AUTHENTICATION_TOKEN_EXPIRY", func(conf *fileConfig, value string) error {
+		conf.Authentication.TokenExpiry = value
+		return nil
+	}},
+	{"AUTHENTICATION_SIGNATURE_SECRET", func(conf *fileConfig, value string) error {
+		conf.Authentication.SignatureSecret = value
+		return nil
+	}},
+	{"AUTHENTICATION_SIGNATURE_SECRET_FILE", func(conf *fileConfig, value string) error {
+		conf.Authentication.SignatureSecretFile = value
+		return nil
+	}},
+	{"NETWORK_HTTP_PORT", func(conf *fileConfig, value string) (err error) {
+		conf.Network.HttpPort, err = parseUint16(value)
+		return err
+	}},
+	{"NETWORK_HTTPS_PORT", func(conf *fileConfig, value string) (err error) {
+		conf.Network.HttpsPort, err = parseUint16(value)
+		return err
+	}},
+	{"NETWORK_BASE_URL", func(conf *fileConfig, value string) error {
+		conf.Network.BaseUrl = value
+		return nil
+	}},
+	{"SECURITY_HTTPS", func(conf *fileConfig, value string) (err error) {
+		conf.Security.Https, err = strconv.ParseBool(value)
+		return err
+	}},
+	{"SECURITY_CERTIFICATE", func(conf *fileConfig, value string) error {
+		conf.Security.Certificate = value
+		return nil
+	}},
+	{"SECURITY_PRIVATE_KEY", func(conf *fileConfig, value string) error {
+		conf.Security.PrivateKey = value
+		return nil
+	}},
+	{"SECURITY_HASH_ALGORITHM", func(conf *fileConfig, value string) error {
+		conf.Security.HashAlgorithm = value
+		return nil
+	}},
+	{"SECURITY_ACL_PATH", func(conf *fileConfig, value string) error {
+		conf.Security.AclPath = value
+		return nil
+	}},
+	{"DOCUMENTATION_OPENAPI_PATH", func(conf *fileConfig, value string) error {
+		conf.Documentation.OpenApiPath = value
+		return nil
+	}},
+	{"DEFAULTS_MAX_UPLOAD_SIZE", func(conf *fileConfig, value string) (err error) {
+		conf.Defaults.MaxUploadSize, err = strconv.ParseInt(value, 10, 64)
+		return err
+	}},
+	{"DEFAULTS_UPLOAD_DIRECTORY", func(conf *fileConfig, value string) error {
+		conf.Defaults.UploadDirectory = value
+		return nil
+	}},
+	{"DEFAULTS_AUTO_CLEAN_UPLOADS", func(conf *fileConfig, value string) (err error) {
+		conf.Defaults.AutoCleanUploads, err = strconv.ParseBool(value)
+		return err
+	}},
+}
+
+/*
+	decodeConfigFile decodes the given JSON (.json),
+	YAML (.yaml, .yml) or TOML (.toml) file into the given value.
+*/
+func decodeConfigFile(path string, value interface{}) (err error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("Could not read configuration file '%s': %s", path, err)
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		err = json.Unmarshal(data, value)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, value)
+	case ".toml":
+		_, err = toml.Decode(string(data), value)
+	default:
+		return fmt.Errorf("Unsupported configuration file format '%s'", filepath.Ext(path))
+	}
+	if err != nil {
+		return fmt.Errorf("Could not parse configuration file '%s': %s", path, err)
+	}
+	return nil
+}
+
+/*
+	LoadConfig reads the service configuration from the given
+	JSON (.json), YAML (.yaml, .yml) or TOML (.toml) file.
+	Values are overridden by APPERIX_* environment variables,
+	like APPERIX_AUTHENTICATION_TOKEN_EXPIRY for authentication.token-expiry.
+	The signature secret is read from the file referenced by
+	signature-secret-file if given.
+	Durations are parsed from strings like "15m".
+	Resources are not part of the file and must be set by the caller.
+*/
+func LoadConfig(path string) (
+	conf ServiceConfig,
+	err error,
+) {
+	var parsed fileConfig
+	err = decodeConfigFile(path, &parsed)
+	if err != nil {
+		return conf, err
+	}
+
+	//apply environment overrides
+	for _, override := range envOverrides {
+		value, exists := os.LookupEnv(ConcatStrings("APPERIX_", override.name))
+		if !exists {
+			continue
+		}
+		err = override.apply(&parsed, value)
+		if err != nil {
+			return conf, fmt.Errorf(
+				"Invalid value of environment variable 'APPERIX_%s': %s",
+				override.name,
+				err,
+			)
+		}
+	}
+	return parsed.serviceConfig()
+}
+
+/*
+	serviceConfig converts the file configuration
+	into a service configuration.
+*/
+func (parsed *fileConfig) serviceConfig() (
+	conf ServiceConfig,
+	err error,
+) {
+	conf.Name = parsed.Name
+	conf.Database = DatabaseConfig {
+		Location: parsed.Database.Location,
+		Cache: parsed.Database.Cache,
+	}
+	conf.Authentication = AuthenticationConfig {
+		Path: parsed.Authentication.Path,
+		SignatureSecret: parsed.Authentication.SignatureSecret,
+	}
+	if parsed.Authentication.TokenExpiry != "" {
+		conf.Authentication.TokenExpiry, err = time.ParseDuration(
+			parsed.Authentication.TokenExpiry,
+		)
+		if err != nil {
+			return conf, fmt.Errorf(
+				"Invalid token expiry '%s': %s",
+				parsed.Authentication.TokenExpiry,
+				err,
+			)
+		}
+	}
+	if parsed.Authentication.SignatureSecretFile != "" {
+		secret, err := ioutil.ReadFile(parsed.Authentication.SignatureSecretFile)
+		if err != nil {
+			return conf, fmt.Errorf(
+				"Could not read signature secret from '%s': %s",
+				parsed.Authentication.SignatureSecretFile,
+				err,
+			)
+		}
+		conf.Authentication.SignatureSecret = strings.TrimSpace(string(secret))
+	}
+	conf.Network = NetworkConfig {
+		HttpPort: parsed.Network.HttpPort,
+		HttpsPort: parsed.Network.HttpsPort,
+		BaseUrl: parsed.Network.BaseUrl,
+	}
+	conf.Security = SecurityConfig {
+		Https: parsed.Security.Https,
+		Certificate: parsed.Security.Certificate,
+		PrivateKey: parsed.Security.PrivateKey,
+		AclPath: parsed.Security.AclPath,
+	}
+	if parsed.Security.HashAlgorithm != "" {
+		algorithm, exists := hashAlgorithmNames[strings.ToUpper(parsed.Security.HashAlgorithm)]
+		if !exists {
+			return conf, fmt.Errorf(
+				"Unknown hash algorithm '%s'",
+				parsed.Security.HashAlgorithm,
+			)
+		}
+		conf.Security.HashAlgorithm = algorithm
+	}
+	conf.Defaults = DefaultsConfig {
+		MaxUploadSize: parsed.Defaults.MaxUploadSize,
+		UploadDirectory: parsed.Defaults.UploadDirectory,
+		AutoCleanUploads: parsed.Defaults.AutoCleanUploads,
+	}
+	conf.Documentation = DocumentationConfig {
+		OpenApiPath: parsed.Documentation.OpenApiPath,
+		Title: parsed.Documentation.Title,
+		Version: parsed.Documentation.Version,
+	}
+	conf.Versioning = VersioningConfig {
+		Versions: parsed.Versioning.Versions,
+		Default: parsed.Versioning.Default,
+		Prefix: parsed.Versioning.Prefix,
+		Header: parsed.Versioning.Header,
+		AcceptParameter: parsed.Versioning.AcceptParameter,
+	}
+	conf.Normalization.CaseInsensitive = parsed.Normalization.CaseInsensitive
+	if parsed.Normalization.Policy != "" {
+		policy, exists := pathPolicyNames[strings.ToLower(parsed.Normalization.Policy)]
+		if !exists {
+			return conf, fmt.Errorf(
+				"Unknown path policy '%s'",
+				parsed.Normalization.Policy,
+			)
+		}
+		conf.Normalization.Policy = policy
+	}
+	return conf, nil
+}
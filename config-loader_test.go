@@ -0,0 +1,182 @@
+package apperix
+
+import (
+	"time"
+	"testing"
+	"io/ioutil"
+	"path/filepath"
+)
+
+/*
+	writeConfigFile writes the given content to a file
+	of the given name in a temporary directory and returns its path.
+*/
+func writeConfigFile(t *testing.T, name string, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	err := ioutil.WriteFile(path, []byte(content), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadConfigFormats(t *testing.T) {
+	for name, content := range map[string] string {
+		"service.json": `{
+			"name": "files",
+			"database": {"location": "/var/lib/files", "cache": 64},
+			"authentication": {"path": "login", "token-expiry": "15m"},
+			"security": {"hash-algorithm": "hs512", "acl-path": "_acl"},
+			"versioning": {"versions": ["v1", "v2"], "prefix": true},
+			"normalization": {"policy": "strict", "case-insensitive": true}
+		}`,
+		"service.yaml": `
+name: files
+database:
+  location: /var/lib/files
+  cache: 64
+authentication:
+  path: login
+  token-expiry: 15m
+security:
+  hash-algorithm: hs512
+  acl-path: _acl
+versioning:
+  versions: [v1, v2]
+  prefix: true
+normalization:
+  policy: strict
+  case-insensitive: true
+`,
+		"service.toml": `
+name = "files"
+[database]
+location = "/var/lib/files"
+cache = 64
+[authentication]
+path = "login"
+token-expiry = "15m"
+[security]
+hash-algorithm = "hs512"
+acl-path = "_acl"
+[versioning]
+versions = ["v1", "v2"]
+prefix = true
+[normalization]
+policy = "strict"
+case-insensitive = true
+`,
+	} {
+		conf, err := LoadConfig(writeConfigFile(t, name, content))
+		if err != nil {
+			t.Errorf("%s: %s", name, err)
+			continue
+		}
+		if conf.Name != "files" ||
+			conf.Database.Location != "/var/lib/files" ||
+			conf.Database.Cache != 64 ||
+			conf.Authentication.Path != "login" ||
+			conf.Authentication.TokenExpiry != 15 * time.Minute ||
+			conf.Security.HashAlgorithm != HS512 ||
+			conf.Security.AclPath != "_acl" ||
+			len(conf.Versioning.Versions) != 2 ||
+			!conf.Versioning.Prefix ||
+			conf.Normalization.Policy != PATH_STRICT ||
+			!conf.Normalization.CaseInsensitive {
+			t.Errorf("%s: unexpected configuration %+v", name, conf)
+		}
+	}
+}
+
+func TestLoadConfigOverrides(t *testing.T) {
+	secretPath := writeConfigFile(t, "secret", "from file\n")
+	path := writeConfigFile(t, "service.json", `{
+		"name": "files",
+		"authentication": {"signature-secret": "inline", "token-expiry": "15m"},
+		"network": {"http-port": 8080}
+	}`)
+	t.Setenv("APPERIX_NAME", "overridden")
+	t.Setenv("APPERIX_AUTHENTICATION_TOKEN_EXPIRY", "1h")
+	t.Setenv("APPERIX_AUTHENTICATION_SIGNATURE_SECRET_FILE", secretPath)
+	t.Setenv("APPERIX_NETWORK_HTTP_PORT", "9090")
+	t.Setenv("APPERIX_SECURITY_HTTPS", "true")
+	conf, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conf.Name != "overridden" ||
+		conf.Authentication.TokenExpiry != time.Hour ||
+		conf.Authentication.SignatureSecret != "from file" ||
+		conf.Network.HttpPort != 9090 ||
+		!conf.Security.Https {
+		t.Errorf("Unexpected configuration %+v", conf)
+	}
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	for _, test := range []struct {
+		description string
+		name string
+		content string
+		env map[string] string
+	} {
+		{
+			"unsupported format",
+			"service.ini",
+			"name = files",
+			nil,
+		},
+		{
+			"malformed file",
+			"service.json",
+			`{"name": `,
+			nil,
+		},
+		{
+			"invalid token expiry",
+			"service.json",
+			`{"authentication": {"token-expiry": "soon"}}`,
+			nil,
+		},
+		{
+			"unknown hash algorithm",
+			"service.json",
+			`{"security": {"hash-algorithm": "MD5"}}`,
+			nil,
+		},
+		{
+			"unknown path policy",
+			"service.json",
+			`{"normalization": {"policy": "lenient"}}`,
+			nil,
+		},
+		{
+			"missing signature secret file",
+			"service.json",
+			`{"authentication": {"signature-secret-file": "/nonexistent/secret"}}`,
+			nil,
+		},
+		{
+			"invalid environment value",
+			"service.json",
+			`{}`,
+			map[string] string {
+				"APPERIX_NETWORK_HTTP_PORT": "70000",
+			},
+		},
+	} {
+		t.Run(test.description, func(t *testing.T) {
+			for key, value := range test.env {
+				t.Setenv(key, value)
+			}
+			_, err := LoadConfig(writeConfigFile(t, test.name, test.content))
+			if err == nil {
+				t.Error("Expected loading to fail")
+			}
+		})
+	}
+	if _, err := LoadConfig(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("Expected missing file to be reported")
+	}
+}
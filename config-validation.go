@@ -0,0 +1,284 @@
+package apperix
+
+import (
+	"fmt"
+	"sort"
+	"regexp"
+)
+
+/*
+	sortedResourceIdentifiers returns the identifiers
+	of the given resources in lexical order.
+*/
+func sortedResourceIdentifiers(resources map[string] Resource) []string {
+	identifiers := make([]string, 0, len(resources))
+	for identifier := range resources {
+		identifiers = append(identifiers, identifier)
+	}
+	sort.Strings(identifiers)
+	return identifiers
+}
+
+/*
+	ValidateConfig verifies the given configuration without accessing
+	either the file system or the network and returns all problems found.
+	An empty list is returned for valid configurations.
+*/
+func ValidateConfig(conf ServiceConfig) (problems []error) {
+	problems = make([]error, 0)
+	if conf.Security.Https {
+		if conf.Security.Certificate == "" {
+			problems = append(problems, fmt.Errorf("Missing certificate for HTTPS"))
+		}
+		if conf.Security.PrivateKey == "" {
+			problems = append(problems, fmt.Errorf("Missing private key for HTTPS"))
+		}
+	}
+	methods := methodRegistry {}
+	if customPermissions, err := newCustomPermissionSet(conf.Resources); err != nil {
+		problems = append(problems, err)
+	} else if methods, err = newMethodRegistry(conf.CustomMethods, customPermissions); err != nil {
+		problems = append(problems, err)
+	}
+	versions, err := newVersioning(conf.Versioning)
+	if err != nil {
+		problems = append(problems, err)
+	}
+	if conf.Normalization.Policy < PATH_TOLERANT || conf.Normalization.Policy > PATH_STRICT {
+		problems = append(problems, fmt.Errorf(
+			"Unknown path policy (%d)",
+			int(conf.Normalization.Policy),
+		))
+	}
+	if conf.Normalization.CaseInsensitive {
+		problems = append(problems, foldedStaticConflicts(conf)...)
+	}
+	if versions.prefixed("auth") {
+		problems = append(problems, fmt.Errorf("Authentication path overlaps with API version prefix"))
+	}
+	if versions.prefixed(conf.Documentation.OpenApiPath) {
+		problems = append(problems, fmt.Errorf("OpenAPI document path overlaps with API version prefix"))
+	}
+
+	staticNames := make(map[string] string)
+	variableNames := make(map[string] string)
+	for _, identifier := range sortedResourceIdentifiers(conf.Resources) {
+		resource := conf.Resources[identifier]
+		if identifier == "auth" || identifier == "openapi" {
+			problems = append(problems, fmt.Errorf("Resource identifier '%s' reserved", identifier))
+			continue
+		}
+		if identifier == "root" {
+			if resource.Type != STATIC {
+				problems = append(problems, fmt.Errorf("Resource 'root' must be static"))
+			}
+			if resource.Parent != "" {
+				problems = append(problems, fmt.Errorf("Resource 'root' can't have a parent"))
+			}
+			continue
+		}
+		if resource.Parent == "" {
+			resource.Parent = "root"
+		}
+
+		//verify parent resource
+		if _, exists := conf.Resources[resource.Parent]; !exists && resource.Parent != "root" {
+			problems = append(problems, fmt.Errorf(
+				"Resource '%s' referenced unregistered parent resource ('%s')",
+				identifier,
+				resource.Parent,
+			))
+		} else if !reachesRoot(conf.Resources, identifier) {
+			problems = append(problems, fmt.Errorf(
+				"Resource '%s' is part of a parent cycle",
+				identifier,
+			))
+		}
+
+		//verify handled methods and versions
+		versionNames := make([]string, 0, len(resource.VersionHandlers))
+		for version := range resource.VersionHandlers {
+			versionNames = append(versionNames, version)
+		}
+		sort.Strings(versionNames)
+		for _, version := range versionNames {
+			if _, exists := versions.indices[version]; !exists {
+				problems = append(problems, fmt.Errorf(
+					"Resource '%s' handles unknown API version '%s'",
+					identifier,
+					version,
+				))
+			}
+		}
+		if methods.verbs != nil {
+			handled := make([]int, 0, len(resource.Handlers))
+			for method := range resource.Handlers {
+				handled = append(handled, int(method))
+			}
+			for _, version := range versionNames {
+				for method := range resource.VersionHandlers[version] {
+					handled = append(handled, int(method))
+				}
+			}
+			sort.Ints(handled)
+			for index, method := range handled {
+				if index > 0 && handled[index - 1] == method {
+					continue
+				}
+				if _, exists := methods.verbs[Method(method)]; !exists {
+					problems = append(problems, fmt.Errorf(
+						"Resource '%s' handles unregistered method (%d)",
+						identifier,
+						method,
+					))
+				}
+			}
+		}
+
+		//verify creation template users
+		if resource.OnCreate != nil {
+			for _, grant := range resource.OnCreate.Grants {
+				switch grant.User.(type) {
+				case Identifier, *Identifier, TargetUser:
+				default:
+					problems = append(problems, fmt.Errorf(
+						"Invalid target user type in creation template of '%s'",
+						identifier,
+					))
+				}
+			}
+		}
+
+		//wildcards consume the rest of the path
+		if parent, exists := conf.Resources[resource.Parent]; exists && parent.Type == WILDCARD {
+			problems = append(problems, fmt.Errorf(
+				"Resource '%s' can't be a child of wildcard resource '%s'",
+				identifier,
+				resource.Parent,
+			))
+		}
+
+		switch resource.Type {
+		case STATIC:
+			key := ConcatStrings(resource.Parent, "/", resource.Name)
+			if other, exists := staticNames[key]; exists {
+				problems = append(problems, fmt.Errorf(
+					"Duplicate static resource name ('%s') in parent '%s' ('%s' and '%s')",
+					resource.Name,
+					resource.Parent,
+					other,
+					identifier,
+				))
+			}
+			staticNames[key] = identifier
+			if resource.Parent == "root" && resource.Name == conf.Authentication.Path {
+				problems = append(problems, fmt.Errorf(
+					"Resource ('%s') overlaps with authentication path",
+					identifier,
+				))
+			}
+			if resource.Parent == "root" &&
+				conf.Documentation.OpenApiPath != "" &&
+				resource.Name == conf.Documentation.OpenApiPath {
+				problems = append(problems, fmt.Errorf(
+					"Resource ('%s') overlaps with OpenAPI document path",
+					identifier,
+				))
+			}
+			if resource.Parent == "root" && versions.prefixed(resource.Name) {
+				problems = append(problems, fmt.Errorf(
+					"Resource ('%s') overlaps with API version prefix",
+					identifier,
+				))
+			}
+			if conf.Security.AclPath != "" && resource.Name == conf.Security.AclPath {
+				problems = append(problems, fmt.Errorf(
+					"Resource ('%s') overlaps with ACL path",
+					identifier,
+				))
+			}
+		case VARIABLE, WILDCARD:
+			key := ConcatStrings(resource.Parent, "/", resource.Name)
+			if other, exists := variableNames[key]; exists {
+				problems = append(problems, fmt.Errorf(
+					"Duplicate variable resource name ('%s') in parent '%s' ('%s' and '%s')",
+					resource.Name,
+					resource.Parent,
+					other,
+					identifier,
+				))
+			}
+			variableNames[key] = identifier
+			if resource.Type == WILDCARD && resource.Kind != ANY {
+				problems = append(problems, fmt.Errorf(
+					"Wildcard resource '%s' can't have a variable kind",
+					identifier,
+				))
+			}
+			if resource.Kind < ANY || resource.Kind > DATE {
+				problems = append(problems, fmt.Errorf(
+					"Unknown variable kind (%d) of resource '%s'",
+					int(resource.Kind),
+					identifier,
+				))
+			}
+			if _, err := regexp.Compile(resource.Pattern); err != nil {
+				problems = append(problems, fmt.Errorf(
+					"Pattern regex ('%s') compilation for resource ('%s') failed: %s",
+					resource.Pattern,
+					identifier,
+					err,
+				))
+			}
+		default:
+			problems = append(problems, fmt.Errorf(
+				"Wrong resource type (%d) of resource '%s'",
+				resource.Type,
+				identifier,
+			))
+		}
+	}
+
+	//verify variable siblings are matched unambiguously
+	siblings := make(map[string] []string)
+	for _, identifier := range sortedResourceIdentifiers(conf.Resources) {
+		resource := conf.Resources[identifier]
+		if identifier == "root" || (resource.Type != VARIABLE && resource.Type != WILDCARD) {
+			continue
+		}
+		if resource.Parent == "" {
+			resource.Parent = "root"
+		}
+		for _, other := range siblings[resource.Parent] {
+			err := variableConflict(other, conf.Resources[other], identifier, resource)
+			if err != nil {
+				problems = append(problems, err)
+			}
+		}
+		siblings[resource.Parent] = append(siblings[resource.Parent], identifier)
+	}
+	return problems
+}
+
+/*
+	reachesRoot returns true if following the parents
+	of the given resource leads to the root resource.
+*/
+func reachesRoot(resources map[string] Resource, identifier string) bool {
+	visited := make(map[string] bool)
+	for identifier != "root" {
+		if visited[identifier] {
+			return false
+		}
+		visited[identifier] = true
+		resource, exists := resources[identifier]
+		if !exists {
+			return false
+		}
+		identifier = resource.Parent
+		if identifier == "" {
+			identifier = "root"
+		}
+	}
+	return true
+}
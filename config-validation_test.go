@@ -0,0 +1,125 @@
+package apperix
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateConfigReportsAllProblems(t *testing.T) {
+	problems := ValidateConfig(ServiceConfig {
+		Authentication: AuthenticationConfig {
+			Path: "auth",
+		},
+		Security: SecurityConfig {
+			Https: true,
+			AclPath: "_acl",
+		},
+		Resources: map[string] Resource {
+			"auth": Resource {
+				Type: STATIC,
+				Name: "login",
+			},
+			"orphan": Resource {
+				Type: STATIC,
+				Name: "orphan",
+				Parent: "missing",
+			},
+			"first": Resource {
+				Type: STATIC,
+				Name: "items",
+			},
+			"second": Resource {
+				Type: STATIC,
+				Name: "items",
+			},
+			"login": Resource {
+				Type: STATIC,
+				Name: "auth",
+			},
+			"acl": Resource {
+				Type: STATIC,
+				Name: "_acl",
+				Parent: "first",
+			},
+			"broken": Resource {
+				Type: VARIABLE,
+				Parent: "first",
+				Pattern: "(",
+			},
+		},
+	})
+	for _, expected := range []string {
+		"Missing certificate for HTTPS",
+		"Missing private key for HTTPS",
+		"Resource identifier 'auth' reserved",
+		"Resource 'orphan' referenced unregistered parent resource ('missing')",
+		"Duplicate static resource name ('items') in parent 'root' ('first' and 'second')",
+		"Resource ('login') overlaps with authentication path",
+		"Resource ('acl') overlaps with ACL path",
+		"Pattern regex ('(') compilation for resource ('broken') failed",
+	} {
+		found := false
+		for _, problem := range problems {
+			if strings.HasPrefix(problem.Error(), expected) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Errorf("Expected problem '%s', got %v", expected, problems)
+		}
+	}
+}
+
+func TestNewServiceReturnsConfigError(t *testing.T) {
+	conf := ServiceConfig {
+		Resources: map[string] Resource {
+			"orphan": Resource {
+				Type: STATIC,
+				Name: "orphan",
+				Parent: "missing",
+			},
+		},
+	}
+	_, err := NewService(conf)
+	configErr, isConfigErr := err.(ConfigError)
+	if !isConfigErr || len(configErr.Errors) != 1 {
+		t.Fatalf("Expected configuration error listing one problem, got %v", err)
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected CreateService to panic")
+		}
+	}()
+	CreateService(conf)
+}
+
+func TestMinimalConfigurationsStayValid(t *testing.T) {
+	//configurations accepted before validation was introduced
+	for _, conf := range []ServiceConfig {
+		ServiceConfig {},
+		ServiceConfig {
+			Authentication: AuthenticationConfig {
+				Path: "auth",
+			},
+			Resources: map[string] Resource {
+				"unnamed": Resource {
+					Type: STATIC,
+				},
+			},
+		},
+	} {
+		if problems := ValidateConfig(conf); len(problems) != 0 {
+			t.Errorf("Expected configuration to be valid, got %v", problems)
+		}
+	}
+	service, err := NewService(ServiceConfig {
+		Database: DatabaseConfig {
+			Location: t.TempDir(),
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	service.database.Close()
+}
@@ -0,0 +1,167 @@
+package apperix
+
+import (
+	"fmt"
+)
+
+/*
+	The TemplateGrant type represents permissions granted
+	to a user when a resource is created.
+	The user can either be a certain user identifier or
+	an abstract user group like other users (OTHERS) and guests (GUESTS).
+*/
+type TemplateGrant struct {
+	User interface{}
+	Permissions Permissions
+}
+
+/*
+	The CreationTemplate type describes ownership and permissions
+	assigned to new instances of a resource.
+*/
+type CreationTemplate struct {
+	//the creating client becomes owner of the resource
+	CreatorIsOwner bool
+	//permissions granted to the creating client, if any
+	CreatorPermissions *Permissions
+	Grants []TemplateGrant
+}
+
+/*
+	requiresCreator returns true if the template assigns
+	ownership or permissions to the creating client.
+*/
+func (template *CreationTemplate) requiresCreator() bool {
+	return template.CreatorIsOwner || template.CreatorPermissions != nil
+}
+
+/*
+	creationTemplateOf returns the creation template of the given resource.
+	An error will be returned in case the resource has no creation template,
+	or in case the template requires a creator and the creator is a guest (nil).
+*/
+func (service *Service) creationTemplateOf(
+	resourceId ResourceIdentifier,
+	creator *Identifier,
+) (
+	template *CreationTemplate,
+	err error,
+) {
+	resourceObj, exists := service.resources()[resourceId.Identifier()]
+	if !exists {
+		return nil, NotFoundError {
+			message: fmt.Sprintf(
+				"Resource identified by '%s' not found",
+				resourceId.Identifier(),
+			),
+		}
+	}
+	template = resourceObj.CreationTemplate()
+	if template == nil {
+		return nil, NotFoundError {
+			message: fmt.Sprintf(
+				"Resource '%s' has no creation template",
+				resourceId.Identifier(),
+			),
+		}
+	}
+	if creator == nil && template.requiresCreator() {
+		return nil, GuestCreatorError {
+			message: fmt.Sprintf(
+				"Creation template of '%s' can't be applied to guests",
+				resourceId.Identifier(),
+			),
+		}
+	}
+	return template, nil
+}
+
+/*
+	ApplyCreationTemplate assigns ownership and permissions declared
+	by the creation template of the given resource within the batch.
+	An error will be returned in case the resource has no creation template
+	or the template assigns ownership or permissions to the creator
+	and the creator is nil (guest).
+*/
+func (batch *Batch) ApplyCreationTemplate(
+	resourceId ResourceIdentifier,
+	creator *Identifier,
+) (
+	err error,
+) {
+	template, err := batch.service.creationTemplateOf(resourceId, creator)
+	if err != nil {
+		return err
+	}
+	if template.CreatorIsOwner {
+		err = batch.AssignOwner(resourceId, *creator)
+		if err != nil {
+			return err
+		}
+	}
+	if template.CreatorPermissions != nil {
+		err = batch.AssignPermissions(
+			resourceId,
+			creator,
+			*template.CreatorPermissions,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	for _, grant := range template.Grants {
+		err = batch.AssignPermissions(
+			resourceId,
+			grant.User,
+			grant.Permissions,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/*
+	ApplyCreationTemplate assigns ownership and permissions declared
+	by the creation template of the given resource in a single transaction.
+	See Batch.ApplyCreationTemplate for the errors returned.
+*/
+func (service *Service) ApplyCreationTemplate(
+	resourceId ResourceIdentifier,
+	creator *Identifier,
+) (
+	err error,
+) {
+	return service.Batch(func(batch *Batch) error {
+		return batch.ApplyCreationTemplate(resourceId, creator)
+	})
+}
+
+/*
+	CreateResource calls the given function to create the given resource
+	and applies its creation template in the same transaction,
+	so neither takes effect in case the other fails.
+	The function is not called in case the template can't be applied,
+	like when the creator is nil (guest) and the template assigns
+	ownership or permissions to the creator.
+*/
+func (service *Service) CreateResource(
+	resourceId ResourceIdentifier,
+	creator *Identifier,
+	create func(*Batch) error,
+) (
+	err error,
+) {
+	_, err = service.creationTemplateOf(resourceId, creator)
+	if err != nil {
+		return err
+	}
+	return service.Batch(func(batch *Batch) error {
+		err := create(batch)
+		if err != nil {
+			return err
+		}
+		return batch.ApplyCreationTemplate(resourceId, creator)
+	})
+}
@@ -0,0 +1,158 @@
+package apperix
+
+import (
+	"fmt"
+	"testing"
+	"net/http"
+)
+
+/*
+	templateTestService returns a service with the resources "/documents/{document}",
+	where documents are owned by their creator, readable by other users
+	and created by POST requests on "/documents".
+	Guests may create documents too.
+*/
+func templateTestService(t *testing.T) *Service {
+	t.Helper()
+	var service *Service
+	service = newTestService(t, ServiceConfig {
+		Resources: map[string] Resource {
+			"documents": Resource {
+				Type: STATIC,
+				Name: "documents",
+				Permissions: DefaultResourcePermissions {
+					GuestPermissions: Permissions {
+						Create: true,
+					},
+				},
+				Handlers: map[Method] Handler {
+					CREATE: func(client *Client, request *Request, _ *Service) Response {
+						documentId, err := service.GetResourceIdentifier("document", map[string] string {
+							"document": "1",
+						})
+						if err != nil {
+							panic(err)
+						}
+						response := ResponseJson {}
+						response.ReplyCreatedResource(documentId)
+						return &response
+					},
+				},
+			},
+			"document": Resource {
+				Type: VARIABLE,
+				Parent: "documents",
+				Pattern: "^[0-9]+$",
+				OnCreate: &CreationTemplate {
+					CreatorIsOwner: true,
+					Grants: []TemplateGrant {
+						TemplateGrant {
+							User: OTHERS,
+							Permissions: Permissions {
+								Read: true,
+							},
+						},
+					},
+				},
+			},
+		},
+	})
+	return service
+}
+
+func TestCreateResourceAppliesTemplateAtomically(t *testing.T) {
+	service := templateTestService(t)
+	creatorId, _ := testUser(t, service, "creator")
+	documentId, _ := service.GetResourceIdentifier("document", map[string] string {
+		"document": "1",
+	})
+	documentsId, _ := service.GetResourceIdentifier("documents", nil)
+
+	//a failing creation leaves neither its own changes nor the template behind
+	err := service.CreateResource(documentId, &creatorId, func(batch *Batch) error {
+		err := batch.AddCoOwner(documentsId, creatorId)
+		if err != nil {
+			return err
+		}
+		return fmt.Errorf("Failure")
+	})
+	if err == nil {
+		t.Fatal("Expected error of the creation")
+	}
+	if owners, _ := service.GetOwnersOf(documentId); len(owners) != 0 {
+		t.Errorf("Expected no owner after failed creation, got %v", owners)
+	}
+	if owners, _ := service.GetOwnersOf(documentsId); len(owners) != 0 {
+		t.Errorf("Expected creation changes to be rolled back, got %v", owners)
+	}
+
+	err = service.CreateResource(documentId, &creatorId, func(*Batch) error {
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	isOwner, _, err := service.ResolvePermissionsFor(documentId, &creatorId)
+	if err != nil || !isOwner {
+		t.Errorf("Expected creator to own the document (%v)", err)
+	}
+	permissions, err := service.GetPermissionsFor(documentId, OTHERS)
+	if err != nil || !permissions.Read || permissions.Update {
+		t.Errorf("Expected other users to be granted read, got %v (%v)", permissions, err)
+	}
+}
+
+func TestCreationTemplateRejectsGuestCreators(t *testing.T) {
+	service := templateTestService(t)
+	documentId, _ := service.GetResourceIdentifier("document", map[string] string {
+		"document": "1",
+	})
+	called := false
+	err := service.CreateResource(documentId, nil, func(*Batch) error {
+		called = true
+		return nil
+	})
+	if _, isGuestCreator := err.(GuestCreatorError); !isGuestCreator {
+		t.Errorf("Expected guest creator error, got %v", err)
+	}
+	if called {
+		t.Error("Expected creation not to be attempted")
+	}
+	err = service.ApplyCreationTemplate(documentId, nil)
+	if _, isGuestCreator := err.(GuestCreatorError); !isGuestCreator {
+		t.Errorf("Expected guest creator error, got %v", err)
+	}
+
+	//requests of guests fail instead of silently leaving the resource unowned
+	expectStatus(t, testRequest(service, "POST", "/documents", ""), http.StatusInternalServerError)
+}
+
+func TestCreationTemplateErrors(t *testing.T) {
+	service := templateTestService(t)
+	creatorId, _ := testUser(t, service, "creator")
+	documentsId, _ := service.GetResourceIdentifier("documents", nil)
+	err := service.ApplyCreationTemplate(documentsId, &creatorId)
+	if _, isNotFound := err.(NotFoundError); !isNotFound {
+		t.Errorf("Expected missing template to be reported, got %v", err)
+	}
+}
+
+func TestCreatedResourceResponseAppliesTemplate(t *testing.T) {
+	service := templateTestService(t)
+	creatorId, token := testUser(t, service, "creator")
+	documentsId, _ := service.GetResourceIdentifier("documents", nil)
+	err := service.AssignPermissions(documentsId, creatorId, Permissions {
+		Create: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectStatus(t, testRequest(service, "POST", "/documents", token), http.StatusCreated)
+	documentId, _ := service.GetResourceIdentifier("document", map[string] string {
+		"document": "1",
+	})
+	owners, err := service.GetOwnersOf(documentId)
+	if err != nil || len(owners) != 1 || owners[0].String() != creatorId.String() {
+		t.Errorf("Expected creator to own the document, got %v (%v)", owners, err)
+	}
+}
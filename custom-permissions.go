@@ -0,0 +1,356 @@
+package apperix
+
+import (
+	"fmt"
+	"sort"
+	"database/sql"
+)
+
+/*
+	customPermissionOffset is the first bit of the serialized permissions mask
+	available to custom permissions, lower bits are used by
+	the built-in permissions.
+*/
+const customPermissionOffset = 17
+
+/*
+	maxCustomPermissions is the maximum number of distinct
+	custom permissions per service.
+*/
+const maxCustomPermissions = 32 - customPermissionOffset
+
+/*
+	customPermissionSet is the set of all custom permissions
+	declared by the resources of a service.
+	Each name is assigned a fixed bit in the serialized permissions mask,
+	which is persisted so stored permissions keep their meaning
+	when names are added or removed.
+*/
+type customPermissionSet struct {
+	//declared names in ascending order
+	names []string
+	//bits by name, assigned by assignBits
+	bits map[string] uint
+}
+
+/*
+	newCustomPermissionSet collects all custom permissions
+	declared by the given resources.
+	Bits are not assigned until assignBits is called.
+	An error will be returned in case a name collides with
+	a built-in permission or too many custom permissions are declared.
+*/
+func newCustomPermissionSet(
+	resources map[string] Resource,
+) (
+	set customPermissionSet,
+	err error,
+) {
+	names := make(map[string] bool)
+	for identifier, resource := range resources {
+		for _, name := range resource.CustomPermissions {
+			for _, builtIn := range permissionNames {
+				if builtIn == name {
+					return set, fmt.Errorf(
+						"Custom permission '%s' of resource '%s' collides with built-in permission",
+						name,
+						identifier,
+					)
+				}
+			}
+			if name == "" {
+				return set, fmt.Errorf(
+					"Empty custom permission name in resource '%s'",
+					identifier,
+				)
+			}
+			names[name] = true
+		}
+	}
+	if len(names) > maxCustomPermissions {
+		return set, fmt.Errorf(
+			"Too many custom permissions (%d), at most %d supported",
+			len(names),
+			maxCustomPermissions,
+		)
+	}
+	set.names = make([]string, 0, len(names))
+	for name := range names {
+		set.names = append(set.names, name)
+	}
+	sort.Strings(set.names)
+	return set, nil
+}
+
+/*
+	assignBits assigns the bits persisted in the given database
+	to the declared names. Names without a persisted bit
+	are assigned the lowest free bits in ascending order of their names
+	and persisted. Bits of names no longer declared stay reserved,
+	so they are never reassigned to another name.
+	An error will be returned in case the persisted bits are corrupt,
+	no free bit is left or the database could not be accessed.
+*/
+func (set *customPermissionSet) assignBits(database *sql.DB) error {
+	transaction, err := database.Begin()
+	if err != nil {
+		return fmt.Errorf("Could not begin transaction: %s", err)
+	}
+	defer transaction.Rollback()
+
+	rows, err := transaction.Query(`
+		SELECT name, bit
+		FROM custom_permissions
+	`)
+	if err != nil {
+		return fmt.Errorf("Could not query custom permission bits: %s", err)
+	}
+	persisted := make(map[string] uint)
+	used := make(map[uint] string)
+	for rows.Next() {
+		var name string
+		var bit uint
+		if err := rows.Scan(&name, &bit); err != nil {
+			rows.Close()
+			return fmt.Errorf("Could not scan custom permission bit: %s", err)
+		}
+		if bit < customPermissionOffset || bit >= 32 {
+			rows.Close()
+			return fmt.Errorf("Invalid bit %d of custom permission '%s'", bit, name)
+		}
+		if other, exists := used[bit]; exists {
+			rows.Close()
+			return fmt.Errorf(
+				"Custom permissions '%s' and '%s' share bit %d",
+				other,
+				name,
+				bit,
+			)
+		}
+		persisted[name] = bit
+		used[bit] = name
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("Could not query custom permission bits: %s", err)
+	}
+
+	bits := make(map[string] uint, len(set.names))
+	free := uint(customPermissionOffset)
+	for _, name := range set.names {
+		if bit, exists := persisted[name]; exists {
+			bits[name] = bit
+			continue
+		}
+		for ; free < 32; free++ {
+			if _, exists := used[free]; !exists {
+				break
+			}
+		}
+		if free >= 32 {
+			return fmt.Errorf("No free bit left for custom permission '%s'", name)
+		}
+		_, err := transaction.Exec(`
+			INSERT INTO custom_permissions (name, bit)
+			VALUES (?, ?)
+		`, name, free)
+		if err != nil {
+			return fmt.Errorf("Could not persist bit of custom permission '%s': %s", name, err)
+		}
+		bits[name] = free
+		used[free] = name
+	}
+	if err := transaction.Commit(); err != nil {
+		return fmt.Errorf("Could not commit custom permission bits: %s", err)
+	}
+	set.bits = bits
+	return nil
+}
+
+/*
+	declares returns true if the given custom permission is declared.
+*/
+func (set customPermissionSet) declares(name string) bool {
+	index := sort.SearchStrings(set.names, name)
+	return index < len(set.names) && set.names[index] == name
+}
+
+/*
+	bit returns the bit of the given custom permission
+	in the serialized permissions mask.
+*/
+func (set customPermissionSet) bit(name string) (uint, bool) {
+	bit, exists := set.bits[name]
+	return bit, exists
+}
+
+/*
+	serialize encodes both built-in and custom permissions.
+*/
+func (set customPermissionSet) serialize(perm Permissions) (mask uint32) {
+	mask = perm.Serialize()
+	for name, allowed := range perm.Custom {
+		if !allowed {
+			continue
+		}
+		if bit, exists := set.bit(name); exists {
+			mask |= (1 << bit)
+		}
+	}
+	return mask
+}
+
+/*
+	deserialize decodes both built-in and custom permissions.
+*/
+func (set customPermissionSet) deserialize(mask uint32) (perm Permissions) {
+	perm.Deserialize(mask)
+	for name, bit := range set.bits {
+		if mask & (1 << bit) > 0 {
+			if perm.Custom == nil {
+				perm.Custom = make(map[string] bool)
+			}
+			perm.Custom[name] = true
+		}
+	}
+	return perm
+}
+
+/*
+	allowAll allows all built-in and custom permissions.
+*/
+func (set customPermissionSet) allowAll(perm *Permissions) {
+	perm.AllowAll()
+	perm.Custom = make(map[string] bool)
+	for _, name := range set.names {
+		perm.Custom[name] = true
+	}
+}
+
+/*
+	parseNames returns permissions allowing all built-in permissions
+	and all of the given declared custom permissions identified by the given names.
+	An error will be returned in case a name is unknown or not declared.
+*/
+func (set customPermissionSet) parseNames(
+	names []string,
+	declared []string,
+) (
+	perm Permissions,
+	err error,
+) {
+	builtIn := make([]string, 0, len(names))
+	for _, name := range names {
+		isDeclared := false
+		for _, declaredName := range declared {
+			if declaredName == name {
+				isDeclared = true
+				break
+			}
+		}
+		if isDeclared && set.declares(name) {
+			if perm.Custom == nil {
+				perm.Custom = make(map[string] bool)
+			}
+			perm.Custom[name] = true
+			continue
+		}
+		builtIn = append(builtIn, name)
+	}
+	custom := perm.Custom
+	err = perm.FromNames(builtIn)
+	perm.Custom = custom
+	return perm, err
+}
+
+/*
+	customPermissionsOf returns the custom permissions declared
+	by the given resource and its ancestors within the given resource tree.
+*/
+func customPermissionsOf(
+	resources map[string] resourceObject,
+	identifier string,
+) []string {
+	declared := make([]string, 0)
+	for identifier != "" {
+		resourceObj, exists := resources[identifier]
+		if !exists {
+			break
+		}
+		declared = append(declared, resourceObj.CustomPermissions()...)
+		identifier = resourceObj.Parent()
+	}
+	return declared
+}
+
+/*
+	declaresCustomPermission returns true if the given resource
+	or one of its ancestors declares the given custom permission.
+*/
+func (service *Service) declaresCustomPermission(
+	identifier string,
+	name string,
+) bool {
+	for _, declared := range customPermissionsOf(service.resources(), identifier) {
+		if declared == name {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+	Can returns true if the client is allowed the given permission
+	on the given resource. The permission is identified by its name
+	and can either be a built-in permission like "read" or a custom permission
+	declared by the resource or one of its ancestors.
+	The policy of the resource is applied to the resolved permissions.
+	An error will be returned in case the permission is unknown
+	or permissions could not be resolved.
+*/
+func (client *Client) Can(
+	service *Service,
+	resourceId ResourceIdentifier,
+	name string,
+) (
+	allowed bool,
+	err error,
+) {
+	isBuiltIn := false
+	for _, builtIn := range permissionNames {
+		if builtIn == name {
+			isBuiltIn = true
+			break
+		}
+	}
+	if !isBuiltIn && !service.declaresCustomPermission(resourceId.Identifier(), name) {
+		return false, NotFoundError {
+			message: fmt.Sprintf(
+				"Permission '%s' not declared for resource '%s'",
+				name,
+				resourceId.Identifier(),
+			),
+		}
+	}
+	var user interface{} = GUESTS
+	if client.Identifier != nil {
+		user = client.Identifier
+	}
+	resources := service.resources()
+	_, permissions, err := service.resolvePermissionsIn(resources, resourceId, user)
+	if err != nil {
+		return false, err
+	}
+	//permissions are restricted by the policy like for requests
+	resourceObj, exists := resources[resourceId.Identifier()]
+	if exists && resourceObj.Policy() != nil {
+		permissions, _ = applyPolicy(
+			resourceObj.Policy(),
+			client,
+			client.request,
+			resourceId,
+			permissions,
+		)
+	}
+	return permissions.Allows(name), nil
+}
@@ -0,0 +1,201 @@
+package apperix
+
+import (
+	"strings"
+	"testing"
+	"net/http"
+	"io/ioutil"
+	"path/filepath"
+)
+
+/*
+	customPermissionResources returns resources where "reports" declares
+	the custom permission "approve" inherited by "drafts"
+	and "notes" declares none.
+*/
+func customPermissionResources(extra ...string) map[string] Resource {
+	return map[string] Resource {
+		"reports": Resource {
+			Type: STATIC,
+			Name: "reports",
+			CustomPermissions: append([]string {"approve"}, extra...),
+			Handlers: map[Method] Handler {
+				READ: okHandler,
+			},
+		},
+		"drafts": Resource {
+			Type: STATIC,
+			Name: "drafts",
+			Parent: "reports",
+			Handlers: map[Method] Handler {
+				READ: okHandler,
+			},
+		},
+		"notes": Resource {
+			Type: STATIC,
+			Name: "notes",
+			Handlers: map[Method] Handler {
+				READ: okHandler,
+			},
+		},
+	}
+}
+
+func TestCustomPermissionsAreResolvedPerResource(t *testing.T) {
+	service := newTestService(t, ServiceConfig {
+		Resources: customPermissionResources(),
+	})
+	reportsId, _ := service.GetResourceIdentifier("reports", nil)
+	draftsId, _ := service.GetResourceIdentifier("drafts", nil)
+	notesId, _ := service.GetResourceIdentifier("notes", nil)
+	userId, _ := testUser(t, service, "user")
+	client := &Client {
+		Identifier: &userId,
+	}
+
+	err := service.AssignPermissions(reportsId, userId, Permissions {
+		Read: true,
+		Custom: map[string] bool {
+			"approve": true,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	allowed, err := client.Can(service, reportsId, "approve")
+	if err != nil || !allowed {
+		t.Errorf("Expected 'approve' to be allowed on reports (%v)", err)
+	}
+	allowed, err = client.Can(service, draftsId, "approve")
+	if err != nil || allowed {
+		t.Errorf("Expected inherited 'approve' to be declared but not granted on drafts (%v)", err)
+	}
+	_, err = client.Can(service, notesId, "approve")
+	if _, isNotFound := err.(NotFoundError); !isNotFound {
+		t.Errorf("Expected 'approve' to be undeclared on notes, got %v", err)
+	}
+	_, err = client.Can(service, reportsId, "fly")
+	if err == nil {
+		t.Error("Expected unknown permission to be rejected")
+	}
+}
+
+func TestCustomPermissionsRejectedOnUndeclaringResources(t *testing.T) {
+	conf := ServiceConfig {
+		Security: SecurityConfig {
+			AclPath: "_acl",
+		},
+		Resources: customPermissionResources(),
+	}
+	service := newTestService(t, conf)
+	notesId, _ := service.GetResourceIdentifier("notes", nil)
+	draftsId, _ := service.GetResourceIdentifier("drafts", nil)
+	ownerId, ownerToken := testUser(t, service, "owner")
+	for _, resourceId := range []ResourceIdentifier {notesId, draftsId} {
+		err := service.AssignOwner(resourceId, ownerId)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	//granting through the access control list
+	recorder := testRequest(service, "PUT", "/notes/_acl?user=others&permissions=approve", ownerToken)
+	expectStatus(t, recorder, http.StatusBadRequest)
+	recorder = testRequest(service, "PUT", "/reports/drafts/_acl?user=others&permissions=approve", ownerToken)
+	expectStatus(t, recorder, http.StatusOK)
+
+	//granting through an imported document
+	err := service.ImportAcl(strings.NewReader(`{
+		"users": [],
+		"resources": [{"resource": "notes", "permissions": {"others": ["approve"]}}]
+	}`), ACL_MERGE)
+	if err == nil {
+		t.Error("Expected import granting an undeclared permission to fail")
+	}
+	err = service.ImportAcl(strings.NewReader(`{
+		"users": [],
+		"resources": [{"resource": "drafts", "permissions": {"others": ["read", "approve"]}}]
+	}`), ACL_MERGE)
+	if err != nil {
+		t.Errorf("Expected import granting an inherited permission to succeed: %s", err)
+	}
+}
+
+func TestLoadResourcesRejectsUndeclaredCustomPermissions(t *testing.T) {
+	directory := t.TempDir()
+	for _, test := range []struct {
+		description string
+		definition string
+		valid bool
+	} {
+		{
+			"permission declared by the resource",
+			`{"resources": {"reports": {"name": "reports", "custom-permissions": ["approve"],
+				"permissions": {"user": ["read", "approve"]}}}}`,
+			true,
+		},
+		{
+			"permission declared by an ancestor",
+			`{"resources": {
+				"reports": {"name": "reports", "custom-permissions": ["approve"]},
+				"drafts": {"name": "drafts", "parent": "reports", "permissions": {"guest": ["approve"]}}}}`,
+			true,
+		},
+		{
+			"permission declared by another resource",
+			`{"resources": {
+				"reports": {"name": "reports", "custom-permissions": ["approve"]},
+				"notes": {"name": "notes", "permissions": {"user": ["approve"]}}}}`,
+			false,
+		},
+	} {
+		path := filepath.Join(directory, "resources.json")
+		err := ioutil.WriteFile(path, []byte(test.definition), 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _, err = LoadResources(path, HandlerRegistry {})
+		if test.valid && err != nil {
+			t.Errorf("%s: unexpected error %s", test.description, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("%s: expected an error", test.description)
+		}
+	}
+}
+
+func TestCustomPermissionBitsPersist(t *testing.T) {
+	location := t.TempDir()
+	service := newTestService(t, ServiceConfig {
+		Database: DatabaseConfig {
+			Location: location,
+		},
+		Resources: customPermissionResources(),
+	})
+	reportsId, _ := service.GetResourceIdentifier("reports", nil)
+	userId, _ := testUser(t, service, "user")
+	err := service.AssignPermissions(reportsId, userId, Permissions {
+		Custom: map[string] bool {
+			"approve": true,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	service.database.Close()
+
+	//a permission sorting before "approve" must not take over its bit
+	restarted := newTestService(t, ServiceConfig {
+		Database: DatabaseConfig {
+			Location: location,
+		},
+		Resources: customPermissionResources("annotate"),
+	})
+	_, permissions, err := restarted.ResolvePermissionsFor(reportsId, &userId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !permissions.Custom["approve"] || permissions.Custom["annotate"] {
+		t.Errorf("Expected only 'approve' to stay granted, got %v", permissions.Custom)
+	}
+}
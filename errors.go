@@ -1,5 +1,10 @@
 package apperix
 
+import (
+	"fmt"
+	"bytes"
+)
+
 /*
 	NotFoundError represents error cases where the requested
 	object was not found.
@@ -22,4 +27,34 @@ type DatabaseFailureError struct {
 
 func (err DatabaseFailureError) Error() string {
 	return err.message
-}
\ No newline at end of file
+}
+
+/*
+	GuestCreatorError represents error cases where a creation template
+	assigning ownership or permissions to the creator is applied for a guest.
+*/
+type GuestCreatorError struct {
+	message string
+}
+
+func (err GuestCreatorError) Error() string {
+	return err.message
+}
+
+/*
+	ConfigError represents a faulty service configuration
+	listing all problems found.
+*/
+type ConfigError struct {
+	Errors []error
+}
+
+func (err ConfigError) Error() string {
+	var buffer bytes.Buffer
+	buffer.WriteString(fmt.Sprintf("Invalid configuration (%d problems)", len(err.Errors)))
+	for _, problem := range err.Errors {
+		buffer.WriteString("\n\t")
+		buffer.WriteString(problem.Error())
+	}
+	return buffer.String()
+}
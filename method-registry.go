@@ -0,0 +1,145 @@
+package apperix
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+/*
+	FIRST_CUSTOM_METHOD is the first method value
+	available to application defined methods.
+*/
+const FIRST_CUSTOM_METHOD Method = CREATE_COLLECTION + 1
+
+/*
+	The CustomMethod type defines an application defined HTTP method,
+	like SEARCH or REPORT. Requests using the verb are dispatched
+	to the handlers registered for the method and require the permission,
+	which is either a built-in permission like "read" or a custom
+	permission declared by a resource.
+*/
+type CustomMethod struct {
+	Verb string
+	Method Method
+	Permission string
+}
+
+var verbPattern = regexp.MustCompile(`^[A-Z][A-Z0-9_-]*$`)
+
+/*
+	methodRegistry maps HTTP methods to methods
+	and methods to the permissions they require.
+*/
+type methodRegistry struct {
+	methods map[string] Method
+	verbs map[Method] string
+	permissions map[Method] string
+}
+
+/*
+	newMethodRegistry returns a registry of the built-in
+	and the given custom methods.
+	An error will be returned in case a custom method is malformed,
+	collides with another method or requires an unknown permission.
+*/
+func newMethodRegistry(
+	customMethods []CustomMethod,
+	customPermissions customPermissionSet,
+) (
+	registry methodRegistry,
+	err error,
+) {
+	registry = methodRegistry {
+		methods: make(map[string] Method),
+		verbs: make(map[Method] string),
+		permissions: make(map[Method] string),
+	}
+	for index, verb := range methodVerbs {
+		registry.methods[verb] = Method(index)
+		registry.verbs[Method(index)] = verb
+		registry.permissions[Method(index)] = permissionNames[index]
+	}
+	for _, custom := range customMethods {
+		if !verbPattern.MatchString(custom.Verb) {
+			return registry, fmt.Errorf("Malformed custom method verb '%s'", custom.Verb)
+		}
+		if _, exists := registry.methods[custom.Verb]; exists {
+			return registry, fmt.Errorf("Custom method verb '%s' already registered", custom.Verb)
+		}
+		if custom.Method < FIRST_CUSTOM_METHOD {
+			return registry, fmt.Errorf(
+				"Custom method '%s' must not use built-in method values (%d)",
+				custom.Verb,
+				int(custom.Method),
+			)
+		}
+		if other, exists := registry.verbs[custom.Method]; exists {
+			return registry, fmt.Errorf(
+				"Custom method '%s' uses the method value of '%s' (%d)",
+				custom.Verb,
+				other,
+				int(custom.Method),
+			)
+		}
+		isBuiltIn := false
+		for _, name := range permissionNames {
+			if name == custom.Permission {
+				isBuiltIn = true
+			}
+		}
+		if !isBuiltIn && !customPermissions.declares(custom.Permission) {
+			return registry, fmt.Errorf(
+				"Custom method '%s' requires undeclared permission '%s'",
+				custom.Verb,
+				custom.Permission,
+			)
+		}
+		registry.methods[custom.Verb] = custom.Method
+		registry.verbs[custom.Method] = custom.Verb
+		registry.permissions[custom.Method] = custom.Permission
+	}
+	return registry, nil
+}
+
+/*
+	method returns the method of the given HTTP method.
+*/
+func (registry *methodRegistry) method(verb string) (Method, bool) {
+	method, exists := registry.methods[verb]
+	return method, exists
+}
+
+/*
+	verb returns the HTTP method of the given method.
+*/
+func (registry *methodRegistry) verb(method Method) string {
+	if verb, exists := registry.verbs[method]; exists {
+		return verb
+	}
+	return fmt.Sprintf("METHOD-%d", int(method))
+}
+
+/*
+	name returns the name of the given method, which equals
+	the name of the required permission for built-in methods
+	and the lower case verb for custom methods.
+*/
+func (registry *methodRegistry) name(method Method) string {
+	if method >= CREATE && method < FIRST_CUSTOM_METHOD {
+		return permissionNames[method]
+	}
+	return strings.ToLower(registry.verb(method))
+}
+
+/*
+	allows returns true if the given permissions
+	allow the permission required by the given method.
+*/
+func (registry *methodRegistry) allows(permissions Permissions, method Method) bool {
+	if method < FIRST_CUSTOM_METHOD {
+		return permissions.AllowsMethod(method)
+	}
+	permission, exists := registry.permissions[method]
+	return exists && permissions.Allows(permission)
+}
@@ -0,0 +1,126 @@
+package apperix
+
+import (
+	"testing"
+	"net/http"
+)
+
+const searchMethod = FIRST_CUSTOM_METHOD
+const approveMethod = FIRST_CUSTOM_METHOD + 1
+
+func TestUnknownMethodsAreNotImplemented(t *testing.T) {
+	created := false
+	service := newTestService(t, ServiceConfig {
+		Resources: map[string] Resource {
+			"items": Resource {
+				Type: STATIC,
+				Name: "items",
+				Permissions: DefaultResourcePermissions {
+					GuestPermissions: Permissions {
+						Create: true,
+					},
+				},
+				Handlers: map[Method] Handler {
+					CREATE: func(client *Client, request *Request, service *Service) Response {
+						created = true
+						return &ResponseJson {}
+					},
+				},
+			},
+		},
+	})
+	for _, verb := range []string {"TRACE", "BREW", "SEARCH"} {
+		expectStatus(t, testRequest(service, verb, "/items", ""), http.StatusNotImplemented)
+	}
+	if created {
+		t.Error("Expected unknown methods not to be dispatched as CREATE")
+	}
+}
+
+func TestCustomMethods(t *testing.T) {
+	service := newTestService(t, ServiceConfig {
+		CustomMethods: []CustomMethod {
+			{"SEARCH", searchMethod, "read"},
+			{"APPROVE", approveMethod, "approve"},
+		},
+		Resources: map[string] Resource {
+			"items": Resource {
+				Type: STATIC,
+				Name: "items",
+				CustomPermissions: []string {"approve"},
+				Permissions: DefaultResourcePermissions {
+					UserPermissions: Permissions {
+						Read: true,
+						Custom: map[string] bool {
+							"approve": true,
+						},
+					},
+					GuestPermissions: Permissions {
+						Read: true,
+					},
+				},
+				Handlers: map[Method] Handler {
+					searchMethod: okHandler,
+					approveMethod: okHandler,
+				},
+			},
+		},
+	})
+	_, token := testUser(t, service, "user")
+	expectStatus(t, testRequest(service, "SEARCH", "/items", ""), http.StatusOK)
+	expectStatus(t, testRequest(service, "APPROVE", "/items", ""), http.StatusForbidden)
+	expectStatus(t, testRequest(service, "APPROVE", "/items", token), http.StatusOK)
+
+	recorder := testRequest(service, "GET", "/items", "")
+	expectStatus(t, recorder, http.StatusMethodNotAllowed)
+	if allow := recorder.Header().Get("Allow"); allow != "OPTIONS, SEARCH, APPROVE" {
+		t.Errorf("Expected Allow 'OPTIONS, SEARCH, APPROVE', got '%s'", allow)
+	}
+	routes := service.Routes()
+	if methods := routes[2].Methods; len(methods) != 2 || methods[0] != "search" || methods[1] != "approve" {
+		t.Errorf("Expected methods named by their verbs, got %v", methods)
+	}
+}
+
+func TestMethodRegistryErrors(t *testing.T) {
+	customPermissions, err := newCustomPermissionSet(map[string] Resource {
+		"items": Resource {
+			CustomPermissions: []string {"approve"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		description string
+		methods []CustomMethod
+	} {
+		{"malformed verb", []CustomMethod {{"search", searchMethod, "read"}}},
+		{"built-in verb", []CustomMethod {{"GET", searchMethod, "read"}}},
+		{"duplicate verb", []CustomMethod {{"SEARCH", searchMethod, "read"}, {"SEARCH", approveMethod, "read"}}},
+		{"built-in method value", []CustomMethod {{"SEARCH", READ, "read"}}},
+		{"duplicate method value", []CustomMethod {{"SEARCH", searchMethod, "read"}, {"REPORT", searchMethod, "read"}}},
+		{"undeclared permission", []CustomMethod {{"REVIEW", approveMethod, "review"}}},
+	} {
+		if _, err := newMethodRegistry(test.methods, customPermissions); err == nil {
+			t.Errorf("%s: expected registration to fail", test.description)
+		}
+	}
+	problems := ValidateConfig(ServiceConfig {
+		Authentication: AuthenticationConfig {
+			Path: "auth",
+		},
+		Resources: map[string] Resource {
+			"items": Resource {
+				Type: STATIC,
+				Name: "items",
+				Handlers: map[Method] Handler {
+					searchMethod: okHandler,
+				},
+			},
+		},
+	})
+	if len(problems) != 1 {
+		t.Errorf("Expected handler of unregistered method to be reported, got %v", problems)
+	}
+}
@@ -0,0 +1,213 @@
+package apperix
+
+import (
+	"fmt"
+	"sync"
+	"strings"
+	"net/url"
+	"net/http"
+)
+
+/*
+	mountLock serializes changes of the mount points of all services,
+	so a service can't be mounted by multiple hosts concurrently.
+*/
+var mountLock sync.Mutex
+
+/*
+	mountPoint locates a mounted service within its host.
+*/
+type mountPoint struct {
+	host *Service
+	name string
+}
+
+/*
+	Handler returns the HTTP handler of the service,
+	which can be embedded into an existing mux.
+	When served below a path prefix, the prefix has to be stripped
+	(e.g. using http.StripPrefix) and the external base URL
+	should include it so UrlFor returns reachable URLs.
+*/
+func (service *Service) Handler() http.Handler {
+	return service.server.Handler
+}
+
+/*
+	accounts returns the service managing the user accounts
+	and access tokens of the service.
+*/
+func (service *Service) accounts() *Service {
+	if service.users != nil {
+		return service.users
+	}
+	return service
+}
+
+/*
+	mounted returns the currently mounted services by name.
+	The returned map must not be modified.
+*/
+func (service *Service) mounted() map[string] *Service {
+	mounts, _ := service.mounts.Load().(map[string] *Service)
+	return mounts
+}
+
+/*
+	mountedService returns the mounted service addressed
+	by the first segment of the given percent-encoded URL path
+	and the rest of the path to be routed by it.
+*/
+func (service *Service) mountedService(escapedPath string) (
+	mounted *Service,
+	rest string,
+	exists bool,
+) {
+	mounts := service.mounted()
+	if len(mounts) < 1 {
+		return nil, "", false
+	}
+	trimmed := strings.TrimLeft(escapedPath, "/")
+	name, rest := trimmed, "/"
+	if index := strings.IndexByte(trimmed, '/'); index >= 0 {
+		name, rest = trimmed[:index], trimmed[index:]
+	}
+	mounted, exists = mounts[name]
+	return mounted, rest, exists
+}
+
+/*
+	mountedRequest returns a shallow copy of the given request
+	addressing the given percent-encoded path of a mounted service.
+*/
+func mountedRequest(request *http.Request, rest string) *http.Request {
+	forwarded := new(http.Request)
+	*forwarded = *request
+	forwarded.URL = new(url.URL)
+	*forwarded.URL = *request.URL
+	forwarded.URL.Path = rest
+	forwarded.URL.RawPath = rest
+	if unescaped, err := url.PathUnescape(rest); err == nil {
+		forwarded.URL.Path = unescaped
+	}
+	return forwarded
+}
+
+/*
+	urlPrefix returns the prefix of the URLs of the service's resources,
+	which is the URL of the mount point in case the service is mounted
+	and the external base URL otherwise.
+*/
+func (service *Service) urlPrefix() string {
+	point, _ := service.mountPoint.Load().(*mountPoint)
+	if point == nil || point.host == nil {
+		return strings.TrimRight(service.Config.BaseUrl(), "/")
+	}
+	return ConcatStrings(point.host.urlPrefix(), "/", point.name)
+}
+
+/*
+	verifyMountName returns an error in case the given name
+	can't address a mounted service, because it's malformed
+	or overlaps with a root level resource or the given ACL path.
+*/
+func verifyMountName(
+	name string,
+	resources map[string] resourceObject,
+	aclPath string,
+) error {
+	if name == "" || strings.Contains(name, "/") {
+		return fmt.Errorf("Malformed mount name '%s'", name)
+	}
+	if rootObj, exists := resources["root"]; exists && rootObj.HasStaticChild(name) {
+		return fmt.Errorf("Mount name '%s' overlaps with a root level resource", name)
+	}
+	if aclPath != "" && name == aclPath {
+		return fmt.Errorf("Mount name '%s' overlaps with ACL path", name)
+	}
+	return nil
+}
+
+/*
+	Mount serves the given service below the given name,
+	requests to "/{name}/..." are handled by the mounted service
+	as if they were sent to "/...".
+	URLs returned by UrlFor of the mounted service are prefixed
+	by the URL of the mount point.
+	An error will be returned in either of the cases:
+	1) the name is malformed or overlaps with a root level resource,
+	the ACL path, an API version prefix or another mounted service.
+	2) the service is already mounted or mounting it would form a cycle.
+*/
+func (service *Service) Mount(name string, mounted *Service) error {
+	mountLock.Lock()
+	defer mountLock.Unlock()
+	service.treeLock.Lock()
+	defer service.treeLock.Unlock()
+
+	err := verifyMountName(name, service.resources(), service.Config.AclPath())
+	if err != nil {
+		return err
+	}
+	if service.versions.prefixed(name) {
+		return fmt.Errorf("Mount name '%s' overlaps with API version prefix", name)
+	}
+	current := service.mounted()
+	if _, exists := current[name]; exists {
+		return fmt.Errorf("Mount name '%s' already in use", name)
+	}
+	if point, _ := mounted.mountPoint.Load().(*mountPoint); point != nil && point.host != nil {
+		return fmt.Errorf("Service '%s' already mounted", mounted.Config.Name())
+	}
+	for host := service; host != nil; {
+		if host == mounted {
+			return fmt.Errorf(
+				"Mounting service '%s' would form a cycle",
+				mounted.Config.Name(),
+			)
+		}
+		point, _ := host.mountPoint.Load().(*mountPoint)
+		if point == nil {
+			break
+		}
+		host = point.host
+	}
+
+	mounts := make(map[string] *Service, len(current) + 1)
+	for mountName, mountedService := range current {
+		mounts[mountName] = mountedService
+	}
+	mounts[name] = mounted
+	mounted.mountPoint.Store(&mountPoint {
+		host: service,
+		name: name,
+	})
+	service.mounts.Store(mounts)
+	return nil
+}
+
+/*
+	Unmount stops serving the service mounted below the given name.
+	An error will be returned in case no service is mounted below the name.
+*/
+func (service *Service) Unmount(name string) error {
+	mountLock.Lock()
+	defer mountLock.Unlock()
+	service.treeLock.Lock()
+	defer service.treeLock.Unlock()
+
+	current := service.mounted()
+	mounted, exists := current[name]
+	if !exists {
+		return fmt.Errorf("No service mounted below '%s'", name)
+	}
+	mounts := make(map[string] *Service, len(current))
+	for mountName, mountedService := range current {
+		if mountName != name {
+			mounts[mountName] = mountedService
+		}
+	}
+	mounted.mountPoint.Store(&mountPoint {})
+	service.mounts.Store(mounts)
+	return nil
+}
@@ -0,0 +1,148 @@
+package apperix
+
+import (
+	"testing"
+	"net/http"
+	"net/http/httptest"
+)
+
+/*
+	mountTestServices returns a host service with the resource "items"
+	and a service sharing its users with the resource "docs" readable
+	by guests and "private" readable by users, mounted below "files".
+*/
+func mountTestServices(t *testing.T) (*Service, *Service) {
+	t.Helper()
+	host := newTestService(t, ServiceConfig {
+		Network: NetworkConfig {
+			BaseUrl: "https://example.com/api",
+		},
+		Security: SecurityConfig {
+			AclPath: "_acl",
+		},
+		Resources: map[string] Resource {
+			"items": guestReadable(Resource {
+				Type: STATIC,
+				Name: "items",
+			}),
+		},
+	})
+	files := newTestService(t, ServiceConfig {
+		Name: "files",
+		SharedUsers: host,
+		Resources: map[string] Resource {
+			"docs": guestReadable(Resource {
+				Type: STATIC,
+				Name: "docs",
+			}),
+			"private": Resource {
+				Type: STATIC,
+				Name: "private",
+				Permissions: DefaultResourcePermissions {
+					UserPermissions: Permissions {
+						Read: true,
+					},
+				},
+				Handlers: map[Method] Handler {
+					READ: okHandler,
+				},
+			},
+		},
+	})
+	err := host.Mount("files", files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return host, files
+}
+
+func TestMountedServicesShareUsers(t *testing.T) {
+	host, files := mountTestServices(t)
+	_, token := testUser(t, host, "user")
+	expectStatus(t, testRequest(host, "GET", "/items", ""), http.StatusOK)
+	expectStatus(t, testRequest(host, "GET", "/files/docs", ""), http.StatusOK)
+	expectStatus(t, testRequest(host, "GET", "/files/private", ""), http.StatusForbidden)
+	expectStatus(t, testRequest(host, "GET", "/files/private", token), http.StatusOK)
+
+	_, err := files.CreateUser("other", "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := host.FindUserByUsername("other"); err != nil {
+		t.Errorf("Expected user created by the mounted service to be shared, got %v", err)
+	}
+
+	url, err := files.UrlFor("docs", nil)
+	if err != nil || url != "https://example.com/api/files/docs" {
+		t.Errorf("Expected URL of the mount point, got '%s' (%v)", url, err)
+	}
+
+	err = host.Unmount("files")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectStatus(t, testRequest(host, "GET", "/files/docs", ""), http.StatusNotFound)
+	if url, _ := files.UrlFor("docs", nil); url != "/docs" {
+		t.Errorf("Expected URL of the unmounted service, got '%s'", url)
+	}
+	if err := host.Unmount("files"); err == nil {
+		t.Error("Expected unmounting twice to fail")
+	}
+}
+
+func TestHandlerCanBeEmbedded(t *testing.T) {
+	host, _ := mountTestServices(t)
+	mux := http.NewServeMux()
+	mux.Handle("/api/", http.StripPrefix("/api", host.Handler()))
+	for _, target := range []string {"/api/items", "/api/files/docs"} {
+		recorder := httptest.NewRecorder()
+		mux.ServeHTTP(recorder, httptest.NewRequest("GET", target, nil))
+		expectStatus(t, recorder, http.StatusOK)
+	}
+}
+
+func TestMountErrors(t *testing.T) {
+	host, files := mountTestServices(t)
+	other := newTestService(t, ServiceConfig {
+		Name: "other",
+	})
+	for _, test := range []struct {
+		description string
+		host *Service
+		name string
+		mounted *Service
+	} {
+		{"empty name", host, "", other},
+		{"malformed name", host, "a/b", other},
+		{"root level resource", host, "items", other},
+		{"authentication resource", host, "auth", other},
+		{"ACL path", host, "_acl", other},
+		{"name in use", host, "files", other},
+		{"already mounted", host, "copy", files},
+		{"cycle", files, "host", host},
+	} {
+		if err := test.host.Mount(test.name, test.mounted); err == nil {
+			t.Errorf("%s: expected mounting to fail", test.description)
+		}
+	}
+
+	//resources can't take the name of mounted services
+	err := host.RegisterResource("files", guestReadable(Resource {
+		Type: STATIC,
+		Name: "files",
+	}))
+	if err == nil {
+		t.Error("Expected resource overlapping with mount point to be rejected")
+	}
+	conf := host.loadedConfig
+	conf.Resources = map[string] Resource {
+		"files": guestReadable(Resource {
+			Type: STATIC,
+			Name: "files",
+		}),
+	}
+	if _, isConfigErr := host.Reload(conf).(ConfigError); !isConfigErr {
+		t.Error("Expected reload overlapping with mount point to be rejected")
+	}
+	expectStatus(t, testRequest(host, "GET", "/files/docs", ""), http.StatusOK)
+}
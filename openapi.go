@@ -0,0 +1,318 @@
+package apperix
+
+import (
+	"strings"
+	"encoding/json"
+)
+
+/*
+	The OperationSchema type describes a single operation
+	of a resource in the OpenAPI document.
+	Request and response schemas are JSON schemas represented by values
+	marshalable to JSON, like map[string] interface{}.
+	The request schema describes the form data of the request,
+	the response schema describes the "data" member of the response.
+*/
+type OperationSchema struct {
+	Summary string
+	Description string
+	Request interface{}
+	Response interface{}
+}
+
+/*
+	openApiMethods maps methods to OpenAPI operations,
+	methods not listed can't be described by OpenAPI 3.1.
+*/
+var openApiMethods = map[Method] string {
+	CREATE: "post",
+	READ: "get",
+	UPDATE: "put",
+	DELETE: "delete",
+	PATCH: "patch",
+	READ_HEADERS: "head",
+	READ_OPTIONS: "options",
+}
+
+/*
+	openApiErrorSchema describes the error envelope of ResponseJson.
+*/
+var openApiErrorSchema = map[string] interface{} {
+	"type": "object",
+	"required": []string {"error"},
+	"properties": map[string] interface{} {
+		"error": map[string] interface{} {
+			"type": "object",
+			"required": []string {"code", "message"},
+			"properties": map[string] interface{} {
+				"code": map[string] interface{} {
+					"type": "string",
+				},
+				"message": map[string] interface{} {
+					"type": "string",
+				},
+			},
+		},
+	},
+}
+
+/*
+	openApiVariableSchema describes the values of the given variable resource.
+*/
+func openApiVariableSchema(resourceObj *variableResource) map[string] interface{} {
+	schema := map[string] interface{} {
+		"type": "string",
+		"pattern": resourceObj.Pattern(),
+	}
+	if resourceObj.Wildcard() {
+		//the pattern applies to each segment of the path
+		delete(schema, "pattern")
+		schema["description"] = "Path of one or more segments separated by slashes"
+	}
+	switch resourceObj.Kind() {
+	case INT64:
+		schema["type"] = "integer"
+		schema["format"] = "int64"
+		delete(schema, "pattern")
+	case DATE:
+		schema["format"] = "date"
+	}
+	return schema
+}
+
+/*
+	openApiOperation describes the given method of the given route.
+*/
+func openApiOperation(
+	route Route,
+	method Method,
+	schema OperationSchema,
+) map[string] interface{} {
+	var dataSchema interface{} = map[string] interface{} {
+		"type": "object",
+	}
+	if schema.Response != nil {
+		dataSchema = schema.Response
+	}
+	errorResponse := map[string] interface{} {
+		"$ref": "#/components/responses/Error",
+	}
+	operation := map[string] interface{} {
+		"operationId": ConcatStrings(route.Identifier, "-", permissionNames[method]),
+		"tags": []string {route.Identifier},
+		"responses": map[string] interface{} {
+			"200": map[string] interface{} {
+				"description": "Success",
+				"content": map[string] interface{} {
+					"application/json": map[string] interface{} {
+						"schema": map[string] interface{} {
+							"type": "object",
+							"properties": map[string] interface{} {
+								"data": dataSchema,
+							},
+						},
+					},
+				},
+			},
+			"400": errorResponse,
+			"403": errorResponse,
+			"404": errorResponse,
+			"default": errorResponse,
+		},
+	}
+	if schema.Summary != "" {
+		operation["summary"] = schema.Summary
+	}
+	if schema.Description != "" {
+		operation["description"] = schema.Description
+	}
+	if schema.Request != nil {
+		operation["requestBody"] = map[string] interface{} {
+			"content": map[string] interface{} {
+				"application/x-www-form-urlencoded": map[string] interface{} {
+					"schema": schema.Request,
+				},
+				"multipart/form-data": map[string] interface{} {
+					"schema": schema.Request,
+				},
+			},
+		}
+	}
+	if method == CREATE {
+		responses := operation["responses"].(map[string] interface{})
+		responses["201"] = map[string] interface{} {
+			"description": "Created",
+		}
+	}
+	return operation
+}
+
+/*
+	OpenAPI returns an OpenAPI 3.1 document describing
+	all resources of the running service.
+	Variable segments are described as path parameters
+	constrained by their patterns, handled methods as operations.
+	Methods without an HTTP equivalent in OpenAPI, like PURGE or LOCK,
+	are left out. Clients authenticate with the bare access token
+	issued by the authentication resource in the Authorization header,
+	guests are described by an empty security requirement.
+*/
+func (service *Service) OpenAPI() ([]byte, error) {
+	documentation := service.Config.current().documentationConfig
+	title := documentation.Title
+	if title == "" {
+		title = service.Config.Name()
+	}
+	version := documentation.Version
+	if version == "" {
+		version = "1.0.0"
+	}
+
+	paths := make(map[string] interface{})
+	resources := service.resources()
+	routes := service.Routes()
+	for _, route := range routes {
+		resourceObj := resources[route.Identifier]
+		item := make(map[string] interface{})
+
+		//describe variable segments
+		parameters := make([]interface{}, 0)
+		for identifier := route.Identifier; identifier != "root" && identifier != ""; {
+			ancestor, exists := resources[identifier]
+			if !exists {
+				break
+			}
+			if variableObj, isVariable := ancestor.(*variableResource); isVariable {
+				parameters = append([]interface{} {
+					map[string] interface{} {
+						"name": identifier,
+						"in": "path",
+						"required": true,
+						"schema": openApiVariableSchema(variableObj),
+					},
+				}, parameters...)
+			}
+			identifier = ancestor.Parent()
+		}
+		if len(parameters) > 0 {
+			item["parameters"] = parameters
+		}
+
+		//describe operations
+		schemas := resourceObj.Schemas()
+		//the default API version is documented
+		for _, method := range resourceObj.Methods(service.versions.defaultVersion) {
+			operationName, exists := openApiMethods[method]
+			if !exists {
+				continue
+			}
+			item[operationName] = openApiOperation(route, method, schemas[method])
+		}
+		if len(item) < 1 {
+			continue
+		}
+		//OpenAPI path templates don't distinguish wildcard parameters
+		paths[strings.Replace(route.Path, "...}", "}", -1)] = item
+	}
+
+	//describe authentication resource
+	if auth, exists := resources["auth"]; exists {
+		authPath := ConcatStrings("/", auth.Name())
+		item, _ := paths[authPath].(map[string] interface{})
+		if operation, exists := item["get"].(map[string] interface{}); exists {
+			operation["summary"] = "Issue an access token"
+			operation["security"] = []interface{} {}
+			operation["parameters"] = []interface{} {
+				map[string] interface{} {
+					"name": "username",
+					"in": "query",
+					"required": true,
+					"schema": map[string] interface{} {"type": "string"},
+				},
+				map[string] interface{} {
+					"name": "password",
+					"in": "query",
+					"required": true,
+					"schema": map[string] interface{} {"type": "string"},
+				},
+			}
+		}
+	}
+
+	document := map[string] interface{} {
+		"openapi": "3.1.0",
+		"info": map[string] interface{} {
+			"title": title,
+			"version": version,
+		},
+		"paths": paths,
+		"security": []interface{} {
+			map[string] interface{} {
+				"accessToken": []string {},
+			},
+			map[string] interface{} {},
+		},
+		"components": map[string] interface{} {
+			"securitySchemes": map[string] interface{} {
+				"accessToken": map[string] interface{} {
+					"type": "apiKey",
+					"in": "header",
+					"name": "Authorization",
+					"description": "Access token (JWT) issued by the authentication resource, sent without authentication scheme",
+				},
+			},
+			"schemas": map[string] interface{} {
+				"Error": openApiErrorSchema,
+			},
+			"responses": map[string] interface{} {
+				"Error": map[string] interface{} {
+					"description": "Error",
+					"content": map[string] interface{} {
+						"application/json": map[string] interface{} {
+							"schema": map[string] interface{} {
+								"$ref": "#/components/schemas/Error",
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	//paths are relative to the prefix of the documented API version
+	versionPrefix := service.versions.urlPrefix(service.versions.defaultVersion)
+	if baseUrl := service.Config.BaseUrl(); baseUrl != "" || versionPrefix != "" {
+		document["servers"] = []interface{} {
+			map[string] interface{} {
+				"url": ConcatStrings(strings.TrimRight(baseUrl, "/"), versionPrefix),
+			},
+		}
+	}
+	return json.Marshal(document)
+}
+
+/*
+	documentResponse is a JSON response
+	which body is a prepared document instead of the data envelope.
+*/
+type documentResponse struct {
+	ResponseJson
+	document []byte
+}
+
+func (response *documentResponse) String() *[]byte {
+	if len(response.errorCode) > 0 {
+		return response.ResponseJson.String()
+	}
+	return &response.document
+}
+
+func openApiReadHandler(client *Client, request *Request, service *Service) Response {
+	document, err := service.OpenAPI()
+	response := documentResponse {
+		document: document,
+	}
+	if err != nil {
+		response.ReplyServerError("DOCUMENT_FAILURE", "Could not generate OpenAPI document")
+	}
+	return &response
+}
@@ -0,0 +1,122 @@
+package apperix
+
+import (
+	"testing"
+	"net/http"
+	"encoding/json"
+)
+
+func TestOpenAPIDescribesResources(t *testing.T) {
+	service := newTestService(t, ServiceConfig {
+		Network: NetworkConfig {
+			BaseUrl: "https://example.com/api/",
+		},
+		Documentation: DocumentationConfig {
+			OpenApiPath: "openapi.json",
+			Title: "Items",
+		},
+		Resources: map[string] Resource {
+			"items": guestReadable(Resource {
+				Type: STATIC,
+				Name: "items",
+				Schemas: map[Method] OperationSchema {
+					READ: OperationSchema {
+						Summary: "List items",
+					},
+				},
+			}),
+			"item": Resource {
+				Type: VARIABLE,
+				Parent: "items",
+				Kind: INT64,
+				Handlers: map[Method] Handler {
+					UPDATE: okHandler,
+					LOCK: okHandler,
+				},
+			},
+			"empty": Resource {
+				Type: STATIC,
+				Name: "empty",
+			},
+		},
+	})
+	recorder := testRequest(service, "GET", "/openapi.json", "")
+	expectStatus(t, recorder, http.StatusOK)
+	var document struct {
+		OpenApi string `json:"openapi"`
+		Info map[string] string `json:"info"`
+		Servers []map[string] string `json:"servers"`
+		Paths map[string] map[string] interface{} `json:"paths"`
+		Components struct {
+			SecuritySchemes map[string] map[string] string `json:"securitySchemes"`
+		} `json:"components"`
+	}
+	err := json.Unmarshal(recorder.Body.Bytes(), &document)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if document.OpenApi != "3.1.0" ||
+		document.Info["title"] != "Items" ||
+		document.Info["version"] != "1.0.0" ||
+		len(document.Servers) != 1 ||
+		document.Servers[0]["url"] != "https://example.com/api" {
+		t.Errorf("Unexpected document header %+v", document)
+	}
+	if _, exists := document.Paths["/empty"]; exists {
+		t.Error("Expected resource without operations to be left out")
+	}
+	if operation, _ := document.Paths["/items"]["get"].(map[string] interface{}); operation["summary"] != "List items" {
+		t.Errorf("Expected summary of the read operation, got %v", document.Paths["/items"])
+	}
+	item := document.Paths["/items/{item}"]
+	if _, exists := item["put"]; !exists || len(item) != 2 {
+		t.Errorf("Expected parameters and update operation only, got %v", item)
+	}
+	parameters, _ := item["parameters"].([]interface{})
+	if len(parameters) != 1 {
+		t.Fatalf("Expected one path parameter, got %v", item["parameters"])
+	}
+	parameter, _ := parameters[0].(map[string] interface{})
+	schema, _ := parameter["schema"].(map[string] interface{})
+	if parameter["name"] != "item" || schema["type"] != "integer" {
+		t.Errorf("Unexpected path parameter %v", parameter)
+	}
+	auth, _ := document.Paths["/auth"]["get"].(map[string] interface{})
+	if security, _ := auth["security"].([]interface{}); security == nil || len(security) != 0 {
+		t.Errorf("Expected authentication without security requirement, got %v", auth)
+	}
+	scheme := document.Components.SecuritySchemes["accessToken"]
+	if scheme["type"] != "apiKey" || scheme["in"] != "header" || scheme["name"] != "Authorization" {
+		t.Errorf("Unexpected security scheme %v", scheme)
+	}
+}
+
+func TestOpenAPINotServedUnlessConfigured(t *testing.T) {
+	service := newTestService(t, ServiceConfig {})
+	expectStatus(t, testRequest(service, "GET", "/openapi.json", ""), http.StatusNotFound)
+}
+
+func TestAccessTokenIsSentWithoutScheme(t *testing.T) {
+	service := newTestService(t, ServiceConfig {
+		Resources: map[string] Resource {
+			"private": Resource {
+				Type: STATIC,
+				Name: "private",
+				Permissions: DefaultResourcePermissions {
+					UserPermissions: Permissions {
+						Read: true,
+					},
+				},
+				Handlers: map[Method] Handler {
+					READ: okHandler,
+				},
+			},
+		},
+	})
+	_, token := testUser(t, service, "user")
+	expectStatus(t, testRequest(service, "GET", "/private", token), http.StatusOK)
+	recorder := testRequest(service, "GET", "/private", ConcatStrings("Bearer ", token))
+	if recorder.Code == http.StatusOK {
+		t.Error("Expected access token with scheme to be rejected")
+	}
+}
@@ -2,13 +2,16 @@ package apperix
 
 import (
 	"fmt"
+	"strings"
+	"sync/atomic"
 	"database/sql"
 	"github.com/hashicorp/golang-lru"
 )
 
 type ownerProvider struct {
 	db *sql.DB
-	cache *lru.ARCCache
+	//current *lru.ARCCache, replaced on reload
+	cacheRef atomic.Value
 }
 
 /*
@@ -26,10 +29,24 @@ func (provider *ownerProvider) initialize(
 		return fmt.Errorf("Could not initialize cache: %s", err)
 	}
 	provider.db = db
-	provider.cache = cache
+	provider.cacheRef.Store(cache)
 	return nil
 }
 
+/*
+	cache returns the current cache of the provider.
+*/
+func (provider *ownerProvider) cache() *lru.ARCCache {
+	return provider.cacheRef.Load().(*lru.ARCCache)
+}
+
+/*
+	replaceCache replaces the cache by the given empty cache.
+*/
+func (provider *ownerProvider) replaceCache(cache *lru.ARCCache) {
+	provider.cacheRef.Store(cache)
+}
+
 /*
 	GetOwnerOf returns the owner of the given resource
 */
@@ -42,7 +59,7 @@ func (provider *ownerProvider) GetOwnerOf(
 	serializedResId := resourceId.Serialize()
 
 	//cache lookup
-	fromCache, exists := provider.cache.Get(serializedResId)
+	fromCache, exists := provider.cache().Get(serializedResId)
 	if exists {
 		return fromCache.(Identifier), nil
 	}
@@ -91,7 +108,107 @@ func (provider *ownerProvider) GetOwnerOf(
 	}
 
 	//fill cache
-	provider.cache.Add(serializedResId, ownerId)
+	provider.cache().Add(serializedResId, ownerId)
 
 	return ownerId, nil
-}
\ No newline at end of file
+}
+
+/*
+	GetOwnersOf returns the owner followed by all co-owners
+	of the given resource
+*/
+func (provider *ownerProvider) GetOwnersOf(
+	resourceId ResourceIdentifier,
+) (
+	owners []Identifier,
+	err error,
+) {
+	serializedResId := resourceId.Serialize()
+	cacheKey := ConcatStrings("owners:", serializedResId)
+
+	//cache lookup
+	fromCache, exists := provider.cache().Get(cacheKey)
+	if exists {
+		return fromCache.([]Identifier), nil
+	}
+
+	//gather from database
+	statement, err := provider.db.Prepare(`
+		SELECT owner_id, 0 AS rank FROM resources
+		WHERE str_id = ? AND owner_id IS NOT NULL
+		UNION ALL
+		SELECT resource_owners.owner_id, 1 AS rank FROM resource_owners
+		JOIN resources ON resources.id = resource_owners.resource_id
+		WHERE resources.str_id = ?
+		ORDER BY rank ASC;
+	`)
+	if err != nil {
+		return owners, DatabaseFailureError {
+			message: fmt.Sprintf("Coult not prepare statement: %s", err),
+		}
+	}
+	defer statement.Close()
+	rows, err := statement.Query(serializedResId, serializedResId)
+	if err != nil {
+		return owners, DatabaseFailureError {
+			message: fmt.Sprintf("Coult not query database: %s", err),
+		}
+	}
+	defer rows.Close()
+	owners = make([]Identifier, 0)
+	for rows.Next() {
+		var ownerIdAsString string
+		var rank int
+		err = rows.Scan(
+			&ownerIdAsString,
+			&rank,
+		)
+		if err != nil {
+			return owners, DatabaseFailureError {
+				message: fmt.Sprintf("Coult not scan row: %s", err),
+			}
+		}
+		ownerId := Identifier {}
+		ownerId.FromString(ownerIdAsString)
+		owners = append(owners, ownerId)
+	}
+	if len(owners) < 1 {
+		return owners, NotFoundError {
+			message: fmt.Sprintf(
+				"Not owner for resource '%s'",
+				resourceId.String(),
+			),
+		}
+	}
+
+	//fill cache
+	provider.cache().Add(cacheKey, owners)
+
+	return owners, nil
+}
+
+/*
+	Invalidate removes the cached owners of the given resource.
+*/
+func (provider *ownerProvider) Invalidate(
+	resourceId ResourceIdentifier,
+) {
+	serializedResId := resourceId.Serialize()
+	provider.cache().Remove(serializedResId)
+	provider.cache().Remove(ConcatStrings("owners:", serializedResId))
+}
+
+/*
+	InvalidateMatching removes cached owners of all resources
+	whose serialized identifier matches the given function.
+*/
+func (provider *ownerProvider) InvalidateMatching(
+	matches func(string) bool,
+) {
+	for _, key := range provider.cache().Keys() {
+		serializedResId := strings.TrimPrefix(key.(string), "owners:")
+		if matches(serializedResId) {
+			provider.cache().Remove(key)
+		}
+	}
+}
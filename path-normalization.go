@@ -0,0 +1,145 @@
+package apperix
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"net/http"
+)
+
+/*
+	The PathPolicy type represents an enumeration of treatments
+	of request paths differing from the canonical path of their resource.
+	Canonical paths contain neither empty segments nor a trailing slash,
+	static names are spelled as declared and segments are percent-encoded
+	like by ResourceIdentifier.Url.
+*/
+type PathPolicy int
+const (
+	//serve non-canonical paths like their canonical path
+	PATH_TOLERANT PathPolicy = iota
+	//redirect non-canonical paths to their canonical path
+	PATH_REDIRECT
+	//reply not found to non-canonical paths
+	PATH_STRICT
+)
+
+var pathPolicyNames = map[string] PathPolicy {
+	"tolerant": PATH_TOLERANT,
+	"redirect": PATH_REDIRECT,
+	"strict": PATH_STRICT,
+}
+
+/*
+	pathSegments splits the given percent-encoded URL path
+	into decoded segments, empty segments are dropped.
+	Encoded slashes are part of their segment.
+	An error will be returned in case a segment is malformed
+	or a dot segment.
+*/
+func pathSegments(escapedPath string) (
+	segments []string,
+	err error,
+) {
+	segments = make([]string, 0)
+	for _, escaped := range strings.Split(escapedPath, "/") {
+		if escaped == "" {
+			continue
+		}
+		segment, err := url.PathUnescape(escaped)
+		if err != nil {
+			return nil, fmt.Errorf("Malformed path segment '%s'", escaped)
+		}
+		if segment == "." || segment == ".." {
+			return nil, fmt.Errorf("Dot segment '%s' not allowed", escaped)
+		}
+		segments = append(segments, segment)
+	}
+	return segments, nil
+}
+
+/*
+	canonicalPath returns the percent-encoded path of the given segments.
+*/
+func canonicalPath(segments []string) string {
+	if len(segments) < 1 {
+		return "/"
+	}
+	escaped := make([]string, len(segments))
+	for index, segment := range segments {
+		escaped[index] = url.PathEscape(segment)
+	}
+	return ConcatStrings("/", strings.Join(escaped, "/"))
+}
+
+/*
+	canonicalRedirect returns the response redirecting the given request
+	to the given canonical path of the service.
+	GET and HEAD requests are redirected using 301,
+	all other methods using 308 so clients preserve method and body.
+*/
+func canonicalRedirect(
+	service *Service,
+	request *http.Request,
+	canonical string,
+) Response {
+	location := ConcatStrings(service.urlPrefix(), canonical)
+	if request.URL.RawQuery != "" {
+		location = ConcatStrings(location, "?", request.URL.RawQuery)
+	}
+	response := &ResponseJson {}
+	if request.Method == "GET" || request.Method == "HEAD" {
+		response.ReplyCustom(http.StatusMovedPermanently)
+	} else {
+		response.ReplyCustom(http.StatusPermanentRedirect)
+	}
+	response.Header("Location", location)
+	response.Data("location", location)
+	return response
+}
+
+/*
+	foldedStaticConflicts returns an error for each pair of static siblings
+	which names only differ in case.
+*/
+func foldedStaticConflicts(conf ServiceConfig) (problems []error) {
+	//identifiers and names of static resources by parent and lower case name
+	type declaration struct {
+		identifier string
+		name string
+	}
+	declarations := map[string] declaration {
+		"root/auth": {
+			"auth",
+			"auth",
+		},
+	}
+	if conf.Documentation.OpenApiPath != "" {
+		declarations[ConcatStrings("root/", strings.ToLower(conf.Documentation.OpenApiPath))] = declaration {
+			"openapi",
+			conf.Documentation.OpenApiPath,
+		}
+	}
+	for _, identifier := range sortedResourceIdentifiers(conf.Resources) {
+		resource := conf.Resources[identifier]
+		if resource.Type != STATIC || identifier == "root" {
+			continue
+		}
+		if resource.Parent == "" {
+			resource.Parent = "root"
+		}
+		key := ConcatStrings(resource.Parent, "/", strings.ToLower(resource.Name))
+		if other, exists := declarations[key]; exists && other.name != resource.Name {
+			problems = append(problems, fmt.Errorf(
+				"Static resource names of '%s' and '%s' only differ in case",
+				other.identifier,
+				identifier,
+			))
+		}
+		declarations[key] = declaration {
+			identifier,
+			resource.Name,
+		}
+	}
+	return problems
+}
@@ -3,13 +3,17 @@ package apperix
 import (
 	"fmt"
 	"bytes"
+	"strings"
+	"sync/atomic"
 	"database/sql"
 	"github.com/hashicorp/golang-lru"
 )
 
 type permissionProvider struct {
 	db *sql.DB
-	cache *lru.ARCCache
+	//current *lru.ARCCache, replaced on reload
+	cacheRef atomic.Value
+	customPermissions customPermissionSet
 }
 
 /*
@@ -19,6 +23,7 @@ type permissionProvider struct {
 func (provider *permissionProvider) initialize(
 	db *sql.DB,
 	cacheSize int,
+	customPermissions customPermissionSet,
 ) (
 	err error,
 ) {
@@ -27,10 +32,25 @@ func (provider *permissionProvider) initialize(
 		return fmt.Errorf("Could not initialize cache: %s", err)
 	}
 	provider.db = db
-	provider.cache = cache
+	provider.cacheRef.Store(cache)
+	provider.customPermissions = customPermissions
 	return nil
 }
 
+/*
+	cache returns the current cache of the provider.
+*/
+func (provider *permissionProvider) cache() *lru.ARCCache {
+	return provider.cacheRef.Load().(*lru.ARCCache)
+}
+
+/*
+	replaceCache replaces the cache by the given empty cache.
+*/
+func (provider *permissionProvider) replaceCache(cache *lru.ARCCache) {
+	provider.cacheRef.Store(cache)
+}
+
 func (provider *permissionProvider) GetPermissionsFor(
 	resourceId ResourceIdentifier,
 	user string,
@@ -38,14 +58,10 @@ func (provider *permissionProvider) GetPermissionsFor(
 	permissions Permissions,
 	err error,
 ) {
-	var cacheKeyBuf bytes.Buffer
-	cacheKeyBuf.WriteString(user)
-	cacheKeyBuf.WriteRune(':')
-	cacheKeyBuf.WriteString(resourceId.Serialize())
-	cacheKey := cacheKeyBuf.String()
+	cacheKey := provider.cacheKey(resourceId, user)
 
 	//cache lookup
-	fromCache, exists := provider.cache.Get(cacheKey)
+	fromCache, exists := provider.cache().Get(cacheKey)
 	if exists {
 		return fromCache.(Permissions), nil
 	}
@@ -75,7 +91,7 @@ func (provider *permissionProvider) GetPermissionsFor(
 		err = rows.Scan(
 			&encodedPermissions,
 		)
-		permissions.Deserialize(encodedPermissions)
+		permissions = provider.customPermissions.deserialize(encodedPermissions)
 		if err != nil {
 			return permissions, DatabaseFailureError {
 				message: fmt.Sprintf("Coult not scan row: %s", err),
@@ -93,7 +109,94 @@ func (provider *permissionProvider) GetPermissionsFor(
 	}
 
 	//fill cache
-	provider.cache.Add(cacheKey, permissions)
+	provider.cache().Add(cacheKey, permissions)
 
 	return permissions, nil
-}
\ No newline at end of file
+}
+
+/*
+	cacheKey returns the cache key of the permissions
+	of the given user on the given resource.
+*/
+func (provider *permissionProvider) cacheKey(
+	resourceId ResourceIdentifier,
+	user string,
+) string {
+	var cacheKeyBuf bytes.Buffer
+	cacheKeyBuf.WriteString(user)
+	cacheKeyBuf.WriteRune(':')
+	cacheKeyBuf.WriteString(resourceId.Serialize())
+	return cacheKeyBuf.String()
+}
+
+/*
+	Invalidate removes cached permissions of the given user
+	on the given resource.
+*/
+func (provider *permissionProvider) Invalidate(
+	resourceId ResourceIdentifier,
+	user string,
+) {
+	provider.cache().Remove(provider.cacheKey(resourceId, user))
+}
+
+/*
+	ListPermissionsOn returns all permission entries directly assigned
+	on the given resource mapped by the serialized user identifier.
+*/
+func (provider *permissionProvider) ListPermissionsOn(
+	resourceId ResourceIdentifier,
+) (
+	entries map[string] Permissions,
+	err error,
+) {
+	entries = make(map[string] Permissions)
+	statement, err := provider.db.Prepare(`
+		SELECT user_id, permissions FROM resource_permissions
+		WHERE resource_id = (SELECT id FROM resources WHERE str_id = ?);
+	`)
+	if err != nil {
+		return entries, DatabaseFailureError {
+			message: fmt.Sprintf("Coult not prepare statement: %s", err),
+		}
+	}
+	defer statement.Close()
+	rows, err := statement.Query(resourceId.Serialize())
+	if err != nil {
+		return entries, DatabaseFailureError {
+			message: fmt.Sprintf("Coult not query database: %s", err),
+		}
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var user string
+		var encodedPermissions uint32
+		err = rows.Scan(
+			&user,
+			&encodedPermissions,
+		)
+		if err != nil {
+			return entries, DatabaseFailureError {
+				message: fmt.Sprintf("Coult not scan row: %s", err),
+			}
+		}
+		entries[user] = provider.customPermissions.deserialize(encodedPermissions)
+	}
+	return entries, nil
+}
+
+/*
+	InvalidateMatching removes cached permissions of all users on all
+	resources whose serialized identifier matches the given function.
+*/
+func (provider *permissionProvider) InvalidateMatching(
+	matches func(string) bool,
+) {
+	for _, key := range provider.cache().Keys() {
+		cacheKey := key.(string)
+		separator := strings.IndexRune(cacheKey, ':')
+		if separator >= 0 && matches(cacheKey[separator + 1:]) {
+			provider.cache().Remove(key)
+		}
+	}
+}
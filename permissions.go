@@ -1,5 +1,10 @@
 package apperix
 
+import (
+	"fmt"
+	"sort"
+)
+
 type Permissions struct {
 	Create bool
 	Read bool
@@ -18,6 +23,8 @@ type Permissions struct {
 	ReadProperties bool
 	UpdateProperties bool
 	CreateCollection bool
+	//application defined permissions declared by resources
+	Custom map[string] bool
 }
 
 func (perm *Permissions) AllowAll() {
@@ -58,6 +65,7 @@ func (perm *Permissions) ForbidAll() {
 	perm.ReadProperties = false
 	perm.UpdateProperties = false
 	perm.CreateCollection = false
+	perm.Custom = nil
 }
 
 func (perm *Permissions) Serialize() (mask uint32) {
@@ -169,3 +177,131 @@ func (perm *Permissions) Deserialize(mask uint32) {
 	}
 }
 
+/*
+	permissionNames maps each bit of the serialized permissions mask
+	to its name as used in the HTTP interface.
+*/
+var permissionNames = []string {
+	"create",
+	"read",
+	"update",
+	"delete",
+	"patch",
+	"read-headers",
+	"read-options",
+	"purge",
+	"copy",
+	"move",
+	"link",
+	"unlink",
+	"lock",
+	"unlock",
+	"read-properties",
+	"update-properties",
+	"create-collection",
+}
+
+/*
+	Names returns the names of all allowed permissions
+	followed by the names of all allowed custom permissions.
+*/
+func (perm *Permissions) Names() []string {
+	mask := perm.Serialize()
+	names := make([]string, 0)
+	for bit, name := range permissionNames {
+		if mask & (1 << uint(bit)) > 0 {
+			names = append(names, name)
+		}
+	}
+	custom := make([]string, 0, len(perm.Custom))
+	for name, allowed := range perm.Custom {
+		if allowed {
+			custom = append(custom, name)
+		}
+	}
+	sort.Strings(custom)
+	return append(names, custom...)
+}
+
+/*
+	Allows returns true if the permission identified by the given name
+	is allowed, the name can either identify a built-in
+	or a custom permission.
+*/
+func (perm *Permissions) Allows(name string) bool {
+	mask := perm.Serialize()
+	for bit, permissionName := range permissionNames {
+		if permissionName == name {
+			return mask & (1 << uint(bit)) > 0
+		}
+	}
+	return perm.Custom[name]
+}
+
+/*
+	AllowsMethod returns true if the permission
+	required by the given method is allowed.
+*/
+func (perm *Permissions) AllowsMethod(method Method) bool {
+	if method < CREATE || method > CREATE_COLLECTION {
+		return false
+	}
+	return perm.Serialize() & (1 << uint(method)) > 0
+}
+
+/*
+	FromNames allows all permissions identified by the given names.
+	An error will be returned in case a name is unknown.
+*/
+func (perm *Permissions) FromNames(names []string) error {
+	var mask uint32
+	for _, name := range names {
+		found := false
+		for bit, permissionName := range permissionNames {
+			if permissionName == name {
+				mask |= (1 << uint(bit))
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("Unknown permission '%s'", name)
+		}
+	}
+	perm.Deserialize(mask)
+	return nil
+}
+
+/*
+	Covers returns true if all permissions allowed in the given
+	permissions are allowed in these permissions too.
+*/
+func (perm *Permissions) Covers(other Permissions) bool {
+	mask := perm.Serialize()
+	if other.Serialize() & ^mask != 0 {
+		return false
+	}
+	for name, allowed := range other.Custom {
+		if allowed && !perm.Custom[name] {
+			return false
+		}
+	}
+	return true
+}
+
+/*
+	Intersect returns permissions allowing only permissions
+	allowed in both these and the given permissions.
+*/
+func (perm *Permissions) Intersect(other Permissions) (result Permissions) {
+	result.Deserialize(perm.Serialize() & other.Serialize())
+	for name, allowed := range perm.Custom {
+		if allowed && other.Custom[name] {
+			if result.Custom == nil {
+				result.Custom = make(map[string] bool)
+			}
+			result.Custom[name] = true
+		}
+	}
+	return result
+}
@@ -0,0 +1,100 @@
+package apperix
+
+import (
+	"time"
+)
+
+/*
+	The PolicyDecision type represents the outcome of a policy evaluation.
+	The decided permissions are intersected with the resolved permissions
+	unless Extend is set, in which case they replace them.
+	Reason is included in the audit trail.
+*/
+type PolicyDecision struct {
+	Permissions Permissions
+	Extend bool
+	Reason string
+}
+
+/*
+	The Policy interface allows resources to apply attribute based rules
+	which can't be expressed as stored permissions, like time or network
+	restrictions. Policies are evaluated after permissions are resolved
+	and before the permissions of the requested method are verified,
+	for owners as well as for other users.
+	The request is nil when permissions are checked outside of request handling.
+*/
+type Policy interface {
+	Evaluate(
+		client *Client,
+		request *Request,
+		resourceId ResourceIdentifier,
+		permissions Permissions,
+	) PolicyDecision
+}
+
+/*
+	The AuditEntry type represents an authorization decision
+	made while processing a request.
+	Client is nil for guests, PolicyReason is empty
+	in case no policy was evaluated.
+	Acl is true for requests to the access control list of the resource.
+*/
+type AuditEntry struct {
+	Time time.Time
+	Client *Identifier
+	Method Method
+	Resource string
+	Acl bool
+	Allowed bool
+	PolicyApplied bool
+	PolicyReason string
+}
+
+/*
+	applyPolicy evaluates the given policy and returns
+	the effective permissions.
+*/
+func applyPolicy(
+	policy Policy,
+	client *Client,
+	request *Request,
+	resourceId ResourceIdentifier,
+	permissions Permissions,
+) (
+	effective Permissions,
+	decision PolicyDecision,
+) {
+	decision = policy.Evaluate(client, request, resourceId, permissions)
+	if decision.Extend {
+		return decision.Permissions, decision
+	}
+	return permissions.Intersect(decision.Permissions), decision
+}
+
+/*
+	ownerRetained returns true if an owner keeps the privileges of ownership
+	on access control lists after a policy turned the given permissions
+	into the given effective permissions, which is the case unless the policy
+	withheld the read- or update-properties permission.
+*/
+func ownerRetained(permissions Permissions, effective Permissions) bool {
+	if permissions.ReadProperties && !effective.ReadProperties {
+		return false
+	}
+	if permissions.UpdateProperties && !effective.UpdateProperties {
+		return false
+	}
+	return true
+}
+
+/*
+	audit passes the given entry to the configured audit function if any.
+*/
+func (service *Service) audit(entry AuditEntry) {
+	if service.auditor == nil {
+		return
+	}
+	entry.Time = time.Now().UTC()
+	service.auditor(entry)
+}
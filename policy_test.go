@@ -0,0 +1,180 @@
+package apperix
+
+import (
+	"testing"
+	"net/http"
+)
+
+/*
+	testPolicy returns the configured permissions as decision
+	and counts its evaluations.
+*/
+type testPolicy struct {
+	decision PolicyDecision
+	evaluations int
+}
+
+func (policy *testPolicy) Evaluate(
+	client *Client,
+	request *Request,
+	resourceId ResourceIdentifier,
+	permissions Permissions,
+) PolicyDecision {
+	policy.evaluations++
+	return policy.decision
+}
+
+/*
+	policyTestService returns a service serving access control lists
+	of the resource "items" governed by the given policy
+	and records audit entries in the given slice.
+*/
+func policyTestService(
+	t *testing.T,
+	policy Policy,
+	entries *[]AuditEntry,
+) (*Service, ResourceIdentifier) {
+	t.Helper()
+	service := newTestService(t, ServiceConfig {
+		Security: SecurityConfig {
+			AclPath: "_acl",
+		},
+		Audit: func(entry AuditEntry) {
+			*entries = append(*entries, entry)
+		},
+		Resources: map[string] Resource {
+			"items": Resource {
+				Type: STATIC,
+				Name: "items",
+				Policy: policy,
+				CustomPermissions: []string {"approve"},
+				Handlers: map[Method] Handler {
+					READ: okHandler,
+					CREATE: okHandler,
+				},
+			},
+		},
+	})
+	resourceId, err := service.GetResourceIdentifier("items", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return service, resourceId
+}
+
+func TestPolicyRestrictsAndAudits(t *testing.T) {
+	entries := make([]AuditEntry, 0)
+	policy := &testPolicy {
+		decision: PolicyDecision {
+			Permissions: Permissions {
+				Read: true,
+			},
+			Reason: "read only",
+		},
+	}
+	service, resourceId := policyTestService(t, policy, &entries)
+	ownerId, ownerToken := testUser(t, service, "owner")
+	err := service.AssignOwner(resourceId, ownerId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	//drop entries of the authentication
+	entries = entries[:0]
+
+	expectStatus(t, testRequest(service, "GET", "/items", ownerToken), http.StatusOK)
+	expectStatus(t, testRequest(service, "POST", "/items", ownerToken), http.StatusForbidden)
+	if policy.evaluations != 2 {
+		t.Errorf("Expected policy evaluated twice, got %d", policy.evaluations)
+	}
+	if len(entries) != 2 ||
+		!entries[0].Allowed ||
+		entries[1].Allowed ||
+		!entries[1].PolicyApplied ||
+		entries[1].PolicyReason != "read only" ||
+		entries[1].Client == nil ||
+		entries[1].Client.String() != ownerId.String() {
+		t.Errorf("Unexpected audit entries %v", entries)
+	}
+}
+
+func TestPolicyAppliesToOwnersOnAccessControlLists(t *testing.T) {
+	entries := make([]AuditEntry, 0)
+	policy := &testPolicy {
+		decision: PolicyDecision {
+			Permissions: Permissions {
+				Read: true,
+				ReadProperties: true,
+			},
+		},
+	}
+	service, resourceId := policyTestService(t, policy, &entries)
+	ownerId, ownerToken := testUser(t, service, "owner")
+	err := service.AssignOwner(resourceId, ownerId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	//drop entries of the authentication
+	entries = entries[:0]
+
+	expectStatus(t, testRequest(service, "GET", "/items/_acl", ownerToken), http.StatusOK)
+	recorder := testRequest(service, "PUT", "/items/_acl?user=others&permissions=read", ownerToken)
+	expectStatus(t, recorder, http.StatusForbidden)
+	if len(entries) != 2 || !entries[1].Acl || entries[1].Allowed || !entries[1].PolicyApplied {
+		t.Errorf("Unexpected audit entries %v", entries)
+	}
+
+	//the policy withholding read-properties too denies listing
+	policy.decision.Permissions.ReadProperties = false
+	expectStatus(t, testRequest(service, "GET", "/items/_acl", ownerToken), http.StatusForbidden)
+}
+
+func TestPolicyExtendsPermissions(t *testing.T) {
+	entries := make([]AuditEntry, 0)
+	policy := &testPolicy {
+		decision: PolicyDecision {
+			Permissions: Permissions {
+				Read: true,
+			},
+			Extend: true,
+		},
+	}
+	service, _ := policyTestService(t, policy, &entries)
+	expectStatus(t, testRequest(service, "GET", "/items", ""), http.StatusOK)
+	expectStatus(t, testRequest(service, "POST", "/items", ""), http.StatusForbidden)
+}
+
+func TestCanAppliesPolicy(t *testing.T) {
+	entries := make([]AuditEntry, 0)
+	policy := &testPolicy {
+		decision: PolicyDecision {
+			Permissions: Permissions {
+				Read: true,
+			},
+		},
+	}
+	service, resourceId := policyTestService(t, policy, &entries)
+	ownerId, _ := testUser(t, service, "owner")
+	err := service.AssignOwner(resourceId, ownerId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &Client {
+		Identifier: &ownerId,
+	}
+	for name, expected := range map[string] bool {
+		"read": true,
+		"create": false,
+		"approve": false,
+	} {
+		allowed, err := client.Can(service, resourceId, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if allowed != expected {
+			t.Errorf("Expected '%s' allowed to be %v", name, expected)
+		}
+	}
+	if policy.evaluations != 3 {
+		t.Errorf("Expected policy evaluated for each check, got %d", policy.evaluations)
+	}
+}
@@ -0,0 +1,263 @@
+package apperix
+
+import (
+	"os"
+	"fmt"
+	"sort"
+	"syscall"
+	"os/signal"
+	"crypto/tls"
+	"github.com/hashicorp/golang-lru"
+)
+
+/*
+	configDiff returns a human readable list of differences
+	between the given configurations, secrets are not revealed.
+*/
+func configDiff(previous ServiceConfig, next ServiceConfig) (diff []string) {
+	compare := func(key string, before interface{}, after interface{}) {
+		if fmt.Sprint(before) != fmt.Sprint(after) {
+			diff = append(diff, fmt.Sprintf("%s: %v -> %v", key, before, after))
+		}
+	}
+	compare("name", previous.Name, next.Name)
+	compare("database.location", previous.Database.Location, next.Database.Location)
+	compare("database.cache", previous.Database.Cache, next.Database.Cache)
+	compare("authentication.path", previous.Authentication.Path, next.Authentication.Path)
+	compare("authentication.token-expiry", previous.Authentication.TokenExpiry, next.Authentication.TokenExpiry)
+	if previous.Authentication.SignatureSecret != next.Authentication.SignatureSecret {
+		diff = append(diff, "authentication.signature-secret: changed")
+	}
+	compare("network.http-port", previous.Network.HttpPort, next.Network.HttpPort)
+	compare("network.https-port", previous.Network.HttpsPort, next.Network.HttpsPort)
+	compare("network.base-url", previous.Network.BaseUrl, next.Network.BaseUrl)
+	compare("security.https", previous.Security.Https, next.Security.Https)
+	compare("security.certificate", previous.Security.Certificate, next.Security.Certificate)
+	compare("security.private-key", previous.Security.PrivateKey, next.Security.PrivateKey)
+	compare("security.hash-algorithm", previous.Security.HashAlgorithm, next.Security.HashAlgorithm)
+	compare("security.acl-path", previous.Security.AclPath, next.Security.AclPath)
+	compare("defaults.max-upload-size", previous.Defaults.MaxUploadSize, next.Defaults.MaxUploadSize)
+	compare("defaults.upload-directory", previous.Defaults.UploadDirectory, next.Defaults.UploadDirectory)
+	compare("defaults.auto-clean-uploads", previous.Defaults.AutoCleanUploads, next.Defaults.AutoCleanUploads)
+	compare("documentation.openapi-path", previous.Documentation.OpenApiPath, next.Documentation.OpenApiPath)
+	compare("documentation.title", previous.Documentation.Title, next.Documentation.Title)
+	compare("documentation.version", previous.Documentation.Version, next.Documentation.Version)
+	compare("custom-methods", previous.CustomMethods, next.CustomMethods)
+	compare("versioning.versions", previous.Versioning.Versions, next.Versioning.Versions)
+	compare("versioning.default", previous.Versioning.Default, next.Versioning.Default)
+	compare("versioning.prefix", previous.Versioning.Prefix, next.Versioning.Prefix)
+	compare("versioning.header", previous.Versioning.Header, next.Versioning.Header)
+	compare("versioning.accept-parameter", previous.Versioning.AcceptParameter, next.Versioning.AcceptParameter)
+	compare("normalization.policy", previous.Normalization.Policy, next.Normalization.Policy)
+	compare("normalization.case-insensitive", previous.Normalization.CaseInsensitive, next.Normalization.CaseInsensitive)
+
+	//compare resources
+	if next.Resources == nil {
+		return diff
+	}
+	for _, identifier := range sortedResourceIdentifiers(previous.Resources) {
+		if _, exists := next.Resources[identifier]; !exists {
+			diff = append(diff, fmt.Sprintf("resources.%s: removed", identifier))
+		}
+	}
+	for _, identifier := range sortedResourceIdentifiers(next.Resources) {
+		resource := next.Resources[identifier]
+		previousResource, exists := previous.Resources[identifier]
+		if !exists {
+			diff = append(diff, fmt.Sprintf("resources.%s: added", identifier))
+			continue
+		}
+		key := ConcatStrings("resources.", identifier, ".permissions")
+		compare(
+			ConcatStrings(key, ".user"),
+			previousResource.Permissions.UserPermissions.Names(),
+			resource.Permissions.UserPermissions.Names(),
+		)
+		compare(
+			ConcatStrings(key, ".guest"),
+			previousResource.Permissions.GuestPermissions.Names(),
+			resource.Permissions.GuestPermissions.Names(),
+		)
+		compare(
+			ConcatStrings(key, ".inheritance"),
+			previousResource.Permissions.Inheritance,
+			resource.Permissions.Inheritance,
+		)
+	}
+	return diff
+}
+
+/*
+	Reload applies the given configuration to the running service
+	without dropping connections. Token expiry, signature secret,
+	cache sizes, ACL path, defaults, TLS certificates and resources
+	(if given) are replaced atomically, changed cache sizes
+	empty the caches.
+	Resources registered at runtime are dropped in case resources are given.
+	An error will be returned and nothing will be changed in case
+	the configuration is invalid or changes the service name,
+	database location, network ports, HTTPS mode or custom permissions,
+	or in case it changes the OpenAPI document path without giving resources.
+*/
+func (service *Service) Reload(conf ServiceConfig) (err error) {
+	service.reloadLock.Lock()
+	defer service.reloadLock.Unlock()
+	previous := service.loadedConfig
+
+	problems := ValidateConfig(conf)
+	rejectChange := func(key string, changed bool) {
+		if changed {
+			problems = append(problems, fmt.Errorf(
+				"Changing '%s' requires a restart",
+				key,
+			))
+		}
+	}
+	rejectChange("name", previous.Name != conf.Name)
+	rejectChange("database.location", previous.Database.Location != conf.Database.Location)
+	rejectChange("network.http-port", previous.Network.HttpPort != conf.Network.HttpPort)
+	rejectChange("network.https-port", previous.Network.HttpsPort != conf.Network.HttpsPort)
+	rejectChange("network.base-url", previous.Network.BaseUrl != conf.Network.BaseUrl)
+	rejectChange("security.https", previous.Security.Https != conf.Security.Https)
+	rejectChange("shared users", previous.SharedUsers != conf.SharedUsers)
+	rejectChange("versioning", fmt.Sprint(previous.Versioning) != fmt.Sprint(conf.Versioning))
+	rejectChange("normalization", previous.Normalization != conf.Normalization)
+	rejectChange(
+		"custom methods",
+		fmt.Sprint(previous.CustomMethods) != fmt.Sprint(conf.CustomMethods),
+	)
+	//the resource tree is only rebuilt in case resources are given
+	if conf.Resources == nil {
+		rejectChange(
+			"documentation.openapi-path without resources",
+			previous.Documentation.OpenApiPath != conf.Documentation.OpenApiPath,
+		)
+	}
+
+	//prepare resource tree
+	var tree *resourceTree
+	if conf.Resources != nil {
+		customPermissions, err := newCustomPermissionSet(conf.Resources)
+		if err == nil {
+			rejectChange(
+				"custom permissions",
+				fmt.Sprint(customPermissions.names) != fmt.Sprint(service.customPermissions.names),
+			)
+		}
+		if len(problems) < 1 {
+			resources, err := buildResourceTree(conf)
+			if err == nil {
+				tree, err = newResourceTree(resources)
+			}
+			if err != nil {
+				problems = append(problems, err)
+			}
+		}
+	} else {
+		conf.Resources = previous.Resources
+	}
+
+	//verify mounted services remain addressable
+	mountedResources := service.resources()
+	if tree != nil {
+		mountedResources = tree.resources
+	}
+	mountNames := make([]string, 0)
+	for name := range service.mounted() {
+		mountNames = append(mountNames, name)
+	}
+	sort.Strings(mountNames)
+	for _, name := range mountNames {
+		err := verifyMountName(name, mountedResources, conf.Security.AclPath)
+		if err != nil {
+			problems = append(problems, err)
+		}
+	}
+
+	//prepare certificate
+	certificate := service.Config.current().certificate
+	if conf.Security.Https && len(problems) < 1 {
+		var loaded tls.Certificate
+		var certificateProblems []error
+		_, _, loaded, certificateProblems = loadCertificate(conf.Security)
+		problems = append(problems, certificateProblems...)
+		certificate = &loaded
+	}
+
+	//prepare resized caches
+	var caches []*lru.ARCCache
+	if conf.Database.Cache != previous.Database.Cache {
+		userCacheSize, permissionCacheSize, ownerCacheSize := cacheSizes(conf.Database)
+		for _, size := range []int {userCacheSize, permissionCacheSize, ownerCacheSize} {
+			cache, err := lru.NewARC(size)
+			if err != nil {
+				problems = append(problems, fmt.Errorf("Could not initialize cache: %s", err))
+				continue
+			}
+			caches = append(caches, cache)
+		}
+	}
+	if len(problems) > 0 {
+		return ConfigError {
+			Errors: problems,
+		}
+	}
+
+	//resize caches
+	if caches != nil {
+		service.userProvider.replaceCache(caches[0])
+		service.permissionProvider.replaceCache(caches[1])
+		service.ownerProvider.replaceCache(caches[2])
+	}
+
+	//swap configuration
+	service.Config.reloadable.Store(&reloadableConfiguration {
+		aclPath: conf.Security.AclPath,
+		authConfig: conf.Authentication,
+		defaultsConfig: conf.Defaults,
+		documentationConfig: conf.Documentation,
+		certificate: certificate,
+	})
+	if tree != nil {
+		service.treeLock.Lock()
+		service.tree.Store(tree)
+		service.treeLock.Unlock()
+	}
+	service.loadedConfig = conf
+
+	diff := configDiff(previous, conf)
+	sort.Strings(diff)
+	fmt.Printf("RELOAD: configuration of '%s' reloaded (%d changes)\n", conf.Name, len(diff))
+	for _, line := range diff {
+		fmt.Printf("RELOAD: %s\n", line)
+	}
+	return nil
+}
+
+/*
+	ReloadOnSignal reloads the service configuration
+	returned by the given function whenever SIGHUP is received
+	until the service is shut down. Failed reloads are logged
+	and leave the running configuration untouched.
+*/
+func (service *Service) ReloadOnSignal(load func() (ServiceConfig, error)) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	go func() {
+		defer signal.Stop(signals)
+		for {
+			select {
+			case <- service.shutdownSignal:
+				return
+			case <- signals:
+				conf, err := load()
+				if err == nil {
+					err = service.Reload(conf)
+				}
+				if err != nil {
+					fmt.Printf("ERROR: Could not reload configuration: %s\n", err)
+				}
+			}
+		}
+	}()
+}
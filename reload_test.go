@@ -0,0 +1,152 @@
+package apperix
+
+import (
+	"testing"
+	"net/http"
+)
+
+/*
+	reloadTestService returns a service with the resource "items"
+	readable by guests.
+*/
+func reloadTestService(t *testing.T) *Service {
+	t.Helper()
+	return newTestService(t, ServiceConfig {
+		Resources: map[string] Resource {
+			"items": guestReadable(Resource {
+				Type: STATIC,
+				Name: "items",
+			}),
+		},
+	})
+}
+
+func TestReloadAppliesChanges(t *testing.T) {
+	service := reloadTestService(t)
+	err := service.RegisterResource("runtime", guestReadable(Resource {
+		Type: STATIC,
+		Name: "runtime",
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectStatus(t, testRequest(service, "GET", "/items/_acl", ""), http.StatusNotFound)
+
+	//without resources the tree is kept
+	conf := service.loadedConfig
+	conf.Resources = nil
+	conf.Security.AclPath = "_acl"
+	err = service.Reload(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectStatus(t, testRequest(service, "GET", "/items/_acl", ""), http.StatusForbidden)
+	expectStatus(t, testRequest(service, "GET", "/runtime", ""), http.StatusOK)
+
+	//given resources replace the tree along with runtime resources
+	conf = service.loadedConfig
+	conf.Resources = map[string] Resource {
+		"reports": guestReadable(Resource {
+			Type: STATIC,
+			Name: "reports",
+		}),
+	}
+	err = service.Reload(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectStatus(t, testRequest(service, "GET", "/reports", ""), http.StatusOK)
+	expectStatus(t, testRequest(service, "GET", "/items", ""), http.StatusNotFound)
+	expectStatus(t, testRequest(service, "GET", "/runtime", ""), http.StatusNotFound)
+}
+
+func TestReloadResizesCaches(t *testing.T) {
+	service := reloadTestService(t)
+	userId, _ := testUser(t, service, "user")
+	resourceId, _ := service.GetResourceIdentifier("items", nil)
+	_, _, err := service.ResolvePermissionsFor(resourceId, &userId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if service.userProvider.cache().Len() == 0 {
+		t.Fatal("Expected cached user")
+	}
+	conf := service.loadedConfig
+	conf.Resources = nil
+	conf.Database.Cache = 10
+	err = service.Reload(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if service.userProvider.cache().Len() != 0 ||
+		service.permissionProvider.cache().Len() != 0 ||
+		service.ownerProvider.cache().Len() != 0 {
+		t.Error("Expected resized caches to be empty")
+	}
+}
+
+func TestReloadRejectsChanges(t *testing.T) {
+	service := reloadTestService(t)
+	for _, test := range []struct {
+		description string
+		change func(*ServiceConfig)
+	} {
+		{
+			"service name",
+			func(conf *ServiceConfig) {
+				conf.Name = "renamed"
+			},
+		},
+		{
+			"database location",
+			func(conf *ServiceConfig) {
+				conf.Database.Location = t.TempDir()
+			},
+		},
+		{
+			"OpenAPI document path without resources",
+			func(conf *ServiceConfig) {
+				conf.Resources = nil
+				conf.Documentation.OpenApiPath = "openapi.json"
+			},
+		},
+		{
+			"custom permissions",
+			func(conf *ServiceConfig) {
+				conf.Resources = map[string] Resource {
+					"items": Resource {
+						Type: STATIC,
+						Name: "items",
+						CustomPermissions: []string {"approve"},
+					},
+				}
+			},
+		},
+		{
+			"invalid resources",
+			func(conf *ServiceConfig) {
+				conf.Resources = map[string] Resource {
+					"orphan": Resource {
+						Type: STATIC,
+						Name: "orphan",
+						Parent: "missing",
+					},
+				}
+			},
+		},
+	} {
+		conf := service.loadedConfig
+		//changes rejected along with others leave the configuration untouched
+		conf.Security.AclPath = "_acl"
+		conf.Database.Cache = 10
+		test.change(&conf)
+		err := service.Reload(conf)
+		if _, isConfigErr := err.(ConfigError); !isConfigErr {
+			t.Errorf("%s: expected configuration error, got %v", test.description, err)
+		}
+		if service.Config.AclPath() != "" || service.loadedConfig.Database.Cache != 0 {
+			t.Errorf("%s: expected configuration to stay unchanged", test.description)
+		}
+	}
+	expectStatus(t, testRequest(service, "GET", "/items", ""), http.StatusOK)
+}
@@ -2,8 +2,10 @@ package apperix
 
 import (
 	"fmt"
+	"sort"
 	"runtime"
 	"strings"
+	"strconv"
 	"sync/atomic"
 	"net/http"
 	"github.com/dgrijalva/jwt-go"
@@ -12,8 +14,17 @@ import (
 type targetResource struct {
 	identifier string
 	variables map[string] string
+	acl bool
+	//decoded segments of the canonical path
+	canonical []string
 }
 
+/*
+	parseAuth returns the client authenticated by the given
+	Authorization header, which holds the bare access token
+	without authentication scheme. Clients without header are guests.
+	An error will be returned in case the access token is invalid.
+*/
 func parseAuth(authHeader string, signatureSecret []byte) (*Client, error) {
 	defer func() {
 		err := recover()
@@ -48,7 +59,17 @@ func parseAuth(authHeader string, signatureSecret []byte) (*Client, error) {
 	return &client, nil
 }
 
-func identifyTargetResource(urlPath string, service *Service) (targetResource, error) {
+/*
+	identifyTargetResource walks the given resource tree segment by segment
+	trying the pattern of each variable child in turn.
+	Requests are routed by the compiled router, which is equivalent
+	and tested against this reference implementation.
+*/
+func identifyTargetResource(
+	urlPath string,
+	service *Service,
+	resources map[string] resourceObject,
+) (targetResource, error) {
 	target := targetResource {
 		variables: make(map[string] string),
 	}
@@ -59,27 +80,55 @@ func identifyTargetResource(urlPath string, service *Service) (targetResource, e
 			path = append(path, str)
 		}
 	}
-	currentResource := service.resources["root"]
+	currentResource := resources["root"]
 	var numOfVar int
 	var matched bool
-	for _, segment := range path {
+	aclPath := service.Config.AclPath()
+	for index := 0; index < len(path); index++ {
+		segment := path[index]
 		matched = false
 		child, err := currentResource.StaticChildIdentifier(segment)
+		if err != nil && aclPath != "" && segment == aclPath && index == len(path) - 1 {
+			//access control list of the current resource
+			target.acl = true
+			break
+		}
 		if err == nil {
 			//static resource identified
-			currentResource = service.resources[child]
+			currentResource = resources[child]
 		} else if numOfVar = currentResource.NumberOfVariableChildren(); numOfVar > 0 {
-			//maybe the resource is a matching placeholder
+			//maybe the resource is a matching placeholder,
+			//wildcards are tried after all other placeholders
 			parentId := currentResource.Identifier()
-			for itr := 0; itr < numOfVar; itr++ {
-				child, err = currentResource.VariableChildIdentifier(itr)
-				if err != nil {
-					continue
-				}
-				currentResource = service.resources[child]
-				if currentResource.(*variableResource).MatchPattern(segment) {
-					matched = true
-					break
+			parentResource := currentResource
+			for _, wildcard := range []bool {false, true} {
+				for itr := 0; itr < numOfVar && !matched; itr++ {
+					child, err = parentResource.VariableChildIdentifier(itr)
+					if err != nil {
+						continue
+					}
+					candidate := resources[child].(*variableResource)
+					if candidate.Wildcard() != wildcard {
+						continue
+					}
+					if wildcard {
+						//consume the rest of the path except a trailing ACL segment
+						rest := path[index:]
+						if aclPath != "" && len(rest) > 1 && rest[len(rest) - 1] == aclPath {
+							rest = rest[:len(rest) - 1]
+							target.acl = true
+						}
+						if candidate.MatchPattern(strings.Join(rest, "/")) {
+							segment = strings.Join(rest, "/")
+							index = len(path)
+							matched = true
+						} else {
+							target.acl = false
+						}
+					} else {
+						matched = candidate.MatchPattern(segment)
+					}
+					currentResource = candidate
 				}
 			}
 			if !matched {
@@ -106,15 +155,103 @@ func identifyTargetResource(urlPath string, service *Service) (targetResource, e
 }
 
 func writeReponse(data Response, response *http.ResponseWriter) {
+	writeReponseHeader(data, response)
+	(*response).Write([]byte(*data.String()))
+	(*response).(http.Flusher).Flush()
+}
+
+/*
+	writeHeadReponse writes the status and headers of the given response
+	discarding its body, as required for HEAD requests.
+*/
+func writeHeadReponse(data Response, response *http.ResponseWriter) {
+	(*response).Header().Set("Content-Length", strconv.Itoa(len(*data.String())))
+	writeReponseHeader(data, response)
+	(*response).(http.Flusher).Flush()
+}
+
+func writeReponseHeader(data Response, response *http.ResponseWriter) {
 	switch data.(type) {
-	case *ResponseJson:
+	case *ResponseJson, *documentResponse:
 		(*response).Header().Set("Content-Type", "application/json")
 	default:
 		(*response).Header().Set("Content-Type", "text/plain")
 	}
+	if withHeaders, ok := data.(interface {
+		Headers() map[string] string
+	}); ok {
+		for head, value := range withHeaders.Headers() {
+			(*response).Header().Set(head, value)
+		}
+	}
 	(*response).WriteHeader(data.Status())
-	(*response).Write([]byte(*data.String()))
-	(*response).(http.Flusher).Flush()
+}
+
+/*
+	supportedMethods returns the methods supported by the given resource
+	in the given API version, HEAD is supported by resources handling READ
+	and OPTIONS is supported by all resources.
+*/
+func supportedMethods(resourceObj resourceObject, version int) []Method {
+	methods := resourceObj.Methods(version)
+	supported := make(map[Method] bool)
+	for _, method := range methods {
+		supported[method] = true
+	}
+	if supported[READ] && !supported[READ_HEADERS] {
+		methods = append(methods, READ_HEADERS)
+	}
+	if !supported[READ_OPTIONS] {
+		methods = append(methods, READ_OPTIONS)
+	}
+	sort.Slice(methods, func(i, j int) bool {
+		return methods[i] < methods[j]
+	})
+	return methods
+}
+
+/*
+	allowHeader returns the value of the Allow header
+	listing the given methods.
+*/
+func allowHeader(registry *methodRegistry, methods []Method) string {
+	verbs := make([]string, 0, len(methods))
+	for _, method := range methods {
+		verbs = append(verbs, registry.verb(method))
+	}
+	return strings.Join(verbs, ", ")
+}
+
+/*
+	optionsResponse answers OPTIONS requests on resources
+	without READ_OPTIONS handler, listing the supported methods
+	the client is allowed to use.
+*/
+func optionsResponse(
+	registry *methodRegistry,
+	resourceObj resourceObject,
+	version int,
+	permissions Permissions,
+) Response {
+	allowed := make([]Method, 0)
+	for _, method := range supportedMethods(resourceObj, version) {
+		switch {
+		case method == READ_OPTIONS:
+		case method == READ_HEADERS && permissions.Read:
+		case registry.allows(permissions, method):
+		default:
+			continue
+		}
+		allowed = append(allowed, method)
+	}
+	verbs := make([]string, 0, len(allowed))
+	for _, method := range allowed {
+		verbs = append(verbs, registry.verb(method))
+	}
+	response := &ResponseJson {}
+	response.Header("Allow", allowHeader(registry, allowed))
+	response.Data("methods", verbs)
+	return response
 }
 
 func finishProcessing(service *Service) {
@@ -131,6 +268,12 @@ type apperixRequestHandler struct {
 }
 
 func (handler *apperixRequestHandler) ServeHTTP(response http.ResponseWriter, request *http.Request) {
+	//delegate requests addressing mounted services
+	if mounted, rest, exists := handler.service.mountedService(request.URL.EscapedPath()); exists {
+		mounted.Handler().ServeHTTP(response, mountedRequest(request, rest))
+		return
+	}
+
 	//increment amount of currently processed requests
 	atomic.AddUint32(&handler.service.reqsInProcess, 1)
 	handler.service.syncGroup.Add(1)
@@ -152,48 +295,20 @@ func (handler *apperixRequestHandler) ServeHTTP(response http.ResponseWriter, re
 	defer finishProcessing(handler.service)
 
 	//parse method
-	var method Method
-	switch request.Method {
-	case "POST":
-		method = CREATE
-	case "GET":
-		method = READ
-	case "PUT":
-		method = UPDATE
-	case "DELETE":
-		method = DELETE
-	case "PATCH":
-		method = PATCH
-	case "HEAD":
-		method = READ_HEADERS
-	case "OPTIONS":
-		method = READ_OPTIONS
-	case "PURGE":
-		method = PURGE
-	case "COPY":
-		method = COPY
-	case "MOVE":
-		method = MOVE
-	case "LINK":
-		method = LINK
-	case "UNLINK":
-		method = UNLINK
-	case "LOCK":
-		method = LOCK
-	case "UNLOCK":
-		method = UNLOCK
-	case "PROPFIND":
-		method = READ_PROPERTIES
-	case "PROPPATCH":
-		method = UPDATE_PROPERTIES
-	case "MKCOL":
-		method = CREATE_COLLECTION
+	method, known := handler.service.methods.method(request.Method)
+	if !known {
+		responseErr := ResponseJson {}
+		responseErr.ReplyNotImplemented(
+			fmt.Sprintf("Method '%s' not implemented", request.Method),
+		)
+		writeReponse(&responseErr, &response)
+		return
 	}
 
 	//authenticate client
 	client, err := parseAuth(
 		request.Header.Get("Authorization"),
-		handler.service.Config.JwtSignatureSecret(),
+		handler.service.accounts().Config.JwtSignatureSecret(),
 	)
 	if err != nil {
 		responseErr := ResponseJson {}
@@ -206,8 +321,31 @@ func (handler *apperixRequestHandler) ServeHTTP(response http.ResponseWriter, re
 		return
 	}
 
+	//select API version
+	version, versionPrefix, urlPath, err := handler.service.versions.selectVersion(request)
+	if err != nil {
+		responseErr := ResponseJson {}
+		responseErr.ReplyClientError("UNKNOWN_VERSION", fmt.Sprintf("%s", err))
+		writeReponse(&responseErr, &response)
+		return
+	}
+	path, err := pathSegments(urlPath)
+	if err != nil {
+		responseErr := ResponseJson {}
+		responseErr.ReplyClientError("INVALID_PATH", fmt.Sprintf("%s", err))
+		writeReponse(&responseErr, &response)
+		return
+	}
+
 	//identify target resource
-	target, err := identifyTargetResource(request.URL.Path, handler.service)
+	normalization := handler.service.Config.normalizationConfig
+	tree := handler.service.tree.Load().(*resourceTree)
+	resources := tree.resources
+	target, err := tree.router.route(
+		path,
+		handler.service.Config.AclPath(),
+		normalization.CaseInsensitive,
+	)
 	if err != nil {
 		responseErr := ResponseJson {}
 		responseErr.ReplyNotFound(fmt.Sprintf("%s", err))
@@ -215,18 +353,43 @@ func (handler *apperixRequestHandler) ServeHTTP(response http.ResponseWriter, re
 		return
 	}
 
+	//apply path policy to non-canonical paths
+	canonical := canonicalPath(target.canonical)
+	if versionPrefix != "" {
+		canonical = strings.TrimSuffix(ConcatStrings(versionPrefix, canonical), "/")
+	}
+	if canonical != request.URL.EscapedPath() {
+		switch normalization.Policy {
+		case PATH_REDIRECT:
+			writeReponse(canonicalRedirect(handler.service, request, canonical), &response)
+			return
+		case PATH_STRICT:
+			responseErr := ResponseJson {}
+			responseErr.ReplyNotFound(fmt.Sprintf(
+				"Path '%s' not canonical ('%s')",
+				request.URL.EscapedPath(),
+				canonical,
+			))
+			writeReponse(&responseErr, &response)
+			return
+		}
+	}
+
 	//verify permissions
 	allowed := false
 	var permissions Permissions
-	resourceId, err := handler.service.GetResourceIdentifier(
+	resourceId, err := resourceIdentifierIn(
+		resources,
 		target.identifier,
 		target.variables,
 	)
 	if err != nil {
 		panic(fmt.Errorf("Could not get resource identifier: %s", err))
 	}
+	var isOwner bool
 	if client.Identifier != nil {
-		_, permissions, err = handler.service.ResolvePermissionsFor(
+		isOwner, permissions, err = handler.service.resolvePermissionsIn(
+			resources,
 			resourceId,
 			client.Identifier,
 		)
@@ -238,7 +401,8 @@ func (handler *apperixRequestHandler) ServeHTTP(response http.ResponseWriter, re
 			))
 		}
 	} else {
-		_, permissions, err = handler.service.ResolvePermissionsFor(
+		_, permissions, err = handler.service.resolvePermissionsIn(
+			resources,
 			resourceId,
 			GUESTS,
 		)
@@ -253,42 +417,88 @@ func (handler *apperixRequestHandler) ServeHTTP(response http.ResponseWriter, re
 	if err != nil {
 		panic(fmt.Errorf("Could not resolve permissions: %s", err))
 	}
-	switch method {
-	case CREATE:
-		allowed = permissions.Create
-	case READ:
-		allowed = permissions.Read
-	case UPDATE:
-		allowed = permissions.Update
-	case DELETE:
-		allowed = permissions.Delete
-	case PATCH:
-		allowed = permissions.Patch
-	case READ_HEADERS:
-		allowed = permissions.ReadHeaders
-	case READ_OPTIONS:
-		allowed = permissions.ReadOptions
-	case PURGE:
-		allowed = permissions.Purge
-	case COPY:
-		allowed = permissions.Copy
-	case MOVE:
-		allowed = permissions.Move
-	case LINK:
-		allowed = permissions.Link
-	case UNLINK:
-		allowed = permissions.Unlink
-	case LOCK:
-		allowed = permissions.Lock
-	case UNLOCK:
-		allowed = permissions.Unlock
-	case READ_PROPERTIES:
-		allowed = permissions.ReadProperties
-	case UPDATE_PROPERTIES:
-		allowed = permissions.UpdateProperties
-	case CREATE_COLLECTION:
-		allowed = permissions.CreateCollection
+	requestData := &Request {
+		requestObject: request,
+		resourceId: resourceId,
+		version: handler.service.versions.name(version),
+		Parameters: request.URL.Query(),
+	}
+	client.request = requestData
+
+	//evaluate policy
+	auditEntry := AuditEntry {
+		Client: client.Identifier,
+		Method: method,
+		Resource: resourceId.String(),
+	}
+	resourceObj, exists := resources[target.identifier]
+	if !exists {
+		panic(fmt.Errorf("Resource '%s' not found", target.identifier))
 	}
+	policy := resourceObj.Policy()
+	if policy != nil {
+		var decision PolicyDecision
+		var effective Permissions
+		effective, decision = applyPolicy(
+			policy,
+			client,
+			requestData,
+			resourceId,
+			permissions,
+		)
+		isOwner = isOwner && ownerRetained(permissions, effective)
+		permissions = effective
+		auditEntry.PolicyApplied = true
+		auditEntry.PolicyReason = decision.Reason
+	}
+
+	//serve access control list
+	if target.acl {
+		auditEntry.Acl = true
+		auditEntry.Allowed = aclAllowed(method, client, isOwner, permissions)
+		handler.service.audit(auditEntry)
+		responseData := aclHandler(
+			method,
+			client,
+			requestData,
+			handler.service,
+			resourceObj,
+			customPermissionsOf(resources, resourceObj.Identifier()),
+			resourceId,
+			isOwner,
+			permissions,
+		)
+		if method == READ_HEADERS {
+			writeHeadReponse(responseData, &response)
+			return
+		}
+		writeReponse(responseData, &response)
+		return
+	}
+
+	//synthesize HEAD and OPTIONS if not handled
+	handlerFunction, err := resourceObj.Handler(version, method)
+	synthesizedHead := false
+	if err != nil && method == READ_HEADERS {
+		handlerFunction, err = resourceObj.Handler(version, READ)
+		synthesizedHead = err == nil
+	}
+	if err != nil && method == READ_OPTIONS {
+		auditEntry.Allowed = true
+		handler.service.audit(auditEntry)
+		writeReponse(optionsResponse(
+			&handler.service.methods,
+			resourceObj,
+			version,
+			permissions,
+		), &response)
+		return
+	}
+
+	allowed = handler.service.methods.allows(permissions, method) ||
+		(synthesizedHead && permissions.Read)
+	auditEntry.Allowed = allowed
+	handler.service.audit(auditEntry)
 	if !allowed {
 		responseErr := ResponseJson {}
 		responseErr.ReplyForbidden(
@@ -299,10 +509,13 @@ func (handler *apperixRequestHandler) ServeHTTP(response http.ResponseWriter, re
 	}
 
 	//verify method support
-	handlerFunction, err := handler.service.resources[target.identifier].Handler(method)
 	if err != nil {
 		//method not supported
 		responseErr := ResponseJson {}
+		responseErr.Header("Allow", allowHeader(
+			&handler.service.methods,
+			supportedMethods(resourceObj, version),
+		))
 		responseErr.ReplyCustomError(
 			http.StatusMethodNotAllowed,
 			"METHOD_NOT_SUPPORTED",
@@ -317,7 +530,7 @@ func (handler *apperixRequestHandler) ServeHTTP(response http.ResponseWriter, re
 	}
 
 	//execute handler
-	if request.Header["Content-Type"][0] == "multipart/form-data" {
+	if strings.HasPrefix(request.Header.Get("Content-Type"), "multipart/form-data") {
 		err = request.ParseMultipartForm(65536)
 		if err != nil {
 			panic(fmt.Sprintf("Could not parse multipart form data: %s", err))
@@ -325,11 +538,50 @@ func (handler *apperixRequestHandler) ServeHTTP(response http.ResponseWriter, re
 	}
 	responseData := handlerFunction(
 		client,
-		&Request {
-			requestObject: request,
-			Parameters: request.URL.Query(),
-		},
+		requestData,
 		handler.service,
 	)
+
+	//apply creation template of created resource
+	if created, ok := responseData.(interface {
+		CreatedResource() *ResourceIdentifier
+	}); ok && created.CreatedResource() != nil {
+		createdId := *created.CreatedResource()
+		//resources unknown to the served tree have no template to apply
+		createdObj, exists := resources[createdId.Identifier()]
+		if exists && createdObj.CreationTemplate() != nil {
+			//applied after the handler, which may have been served during another batch
+			err = handler.service.batch(func(batch *Batch) error {
+				return batch.ApplyCreationTemplate(createdId, client.Identifier)
+			})
+			if err != nil {
+				panic(fmt.Errorf(
+					"Could not apply creation template to '%s': %s",
+					createdId.String(),
+					err,
+				))
+			}
+		}
+	}
+
+	//forget deleted resource
+	if method == DELETE &&
+		responseData.Status() < 300 &&
+		resourceObj.ForgetOnDelete() {
+		handler.service.batchLock.RLock()
+		err = handler.service.ForgetResource(resourceId, true)
+		handler.service.batchLock.RUnlock()
+		if err != nil {
+			panic(fmt.Errorf(
+				"Could not forget resource '%s': %s",
+				resourceId.String(),
+				err,
+			))
+		}
+	}
+	if synthesizedHead {
+		writeHeadReponse(responseData, &response)
+		return
+	}
 	writeReponse(responseData, &response)
 }
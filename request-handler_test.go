@@ -0,0 +1,155 @@
+package apperix
+
+import (
+	"strings"
+	"testing"
+	"net/http"
+)
+
+/*
+	methodTestService returns a service with the resource "items"
+	handling READ and UPDATE, readable by guests and updatable by users,
+	and the resource "reports" handling UPDATE only,
+	which headers are readable by guests.
+*/
+func methodTestService(t *testing.T) *Service {
+	t.Helper()
+	readHandler := func(client *Client, request *Request, service *Service) Response {
+		response := &ResponseJson {}
+		response.Header("X-Items", "2")
+		response.Data("items", []string {"a", "b"})
+		return response
+	}
+	return newTestService(t, ServiceConfig {
+		Security: SecurityConfig {
+			AclPath: "_acl",
+		},
+		Resources: map[string] Resource {
+			"items": Resource {
+				Type: STATIC,
+				Name: "items",
+				Permissions: DefaultResourcePermissions {
+					UserPermissions: Permissions {
+						Read: true,
+						Update: true,
+						Delete: true,
+					},
+					GuestPermissions: Permissions {
+						Read: true,
+					},
+				},
+				Handlers: map[Method] Handler {
+					READ: readHandler,
+					UPDATE: okHandler,
+				},
+			},
+			"reports": Resource {
+				Type: STATIC,
+				Name: "reports",
+				Permissions: DefaultResourcePermissions {
+					GuestPermissions: Permissions {
+						ReadHeaders: true,
+					},
+				},
+				Handlers: map[Method] Handler {
+					UPDATE: okHandler,
+				},
+			},
+		},
+	})
+}
+
+func TestHeadIsSynthesizedFromRead(t *testing.T) {
+	service := methodTestService(t)
+	recorder := testRequest(service, "HEAD", "/items", "")
+	expectStatus(t, recorder, http.StatusOK)
+	if recorder.Body.Len() != 0 {
+		t.Errorf("Expected empty body, got '%s'", recorder.Body.String())
+	}
+	if recorder.Header().Get("X-Items") != "2" {
+		t.Errorf("Expected headers of the READ handler, got %v", recorder.Header())
+	}
+
+	//resources without READ handler don't support HEAD
+	recorder = testRequest(service, "HEAD", "/reports", "")
+	expectStatus(t, recorder, http.StatusMethodNotAllowed)
+	if allow := recorder.Header().Get("Allow"); allow != "PUT, OPTIONS" {
+		t.Errorf("Expected Allow 'PUT, OPTIONS', got '%s'", allow)
+	}
+}
+
+func TestOptionsListsAllowedMethods(t *testing.T) {
+	service := methodTestService(t)
+	_, token := testUser(t, service, "user")
+	for _, test := range []struct {
+		token string
+		allow string
+	} {
+		{"", "GET, HEAD, OPTIONS"},
+		{token, "GET, PUT, HEAD, OPTIONS"},
+	} {
+		recorder := testRequest(service, "OPTIONS", "/items", test.token)
+		expectStatus(t, recorder, http.StatusOK)
+		if allow := recorder.Header().Get("Allow"); allow != test.allow {
+			t.Errorf("Expected Allow '%s', got '%s'", test.allow, allow)
+		}
+		methods, _ := responseData(t, recorder)["methods"].([]interface{})
+		if len(methods) != len(splitAllow(test.allow)) {
+			t.Errorf("Expected methods %s, got %v", test.allow, methods)
+		}
+	}
+}
+
+func TestMethodNotAllowedListsSupportedMethods(t *testing.T) {
+	service := methodTestService(t)
+	_, token := testUser(t, service, "user")
+	recorder := testRequest(service, "DELETE", "/items", token)
+	expectStatus(t, recorder, http.StatusMethodNotAllowed)
+	if allow := recorder.Header().Get("Allow"); allow != "GET, PUT, HEAD, OPTIONS" {
+		t.Errorf("Expected Allow 'GET, PUT, HEAD, OPTIONS', got '%s'", allow)
+	}
+	//missing permissions are reported before missing handlers
+	expectStatus(t, testRequest(service, "DELETE", "/items", ""), http.StatusForbidden)
+}
+
+func TestHeadAndOptionsOnAccessControlLists(t *testing.T) {
+	service := methodTestService(t)
+	userId, token := testUser(t, service, "user")
+	resourceId, _ := service.GetResourceIdentifier("items", nil)
+	err := service.AssignOwner(resourceId, userId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorder := testRequest(service, "HEAD", "/items/_acl", token)
+	expectStatus(t, recorder, http.StatusOK)
+	if recorder.Body.Len() != 0 {
+		t.Errorf("Expected empty body, got '%s'", recorder.Body.String())
+	}
+	expectStatus(t, testRequest(service, "HEAD", "/items/_acl", ""), http.StatusForbidden)
+
+	for _, test := range []struct {
+		token string
+		allow string
+	} {
+		{"", "OPTIONS"},
+		{token, "GET, PUT, DELETE, HEAD, OPTIONS"},
+	} {
+		recorder := testRequest(service, "OPTIONS", "/items/_acl", test.token)
+		expectStatus(t, recorder, http.StatusOK)
+		if allow := recorder.Header().Get("Allow"); allow != test.allow {
+			t.Errorf("Expected Allow '%s', got '%s'", test.allow, allow)
+		}
+	}
+	recorder = testRequest(service, "POST", "/items/_acl", token)
+	expectStatus(t, recorder, http.StatusMethodNotAllowed)
+	if allow := recorder.Header().Get("Allow"); allow != "GET, PUT, DELETE, HEAD, OPTIONS" {
+		t.Errorf("Expected Allow listing ACL methods, got '%s'", allow)
+	}
+}
+
+/*
+	splitAllow returns the methods listed by the given Allow header.
+*/
+func splitAllow(allow string) []string {
+	return strings.Split(allow, ", ")
+}
@@ -9,6 +9,9 @@ import (
 
 type Request struct {
 	requestObject *http.Request
+	resourceId ResourceIdentifier
+	//name of the API version serving the request
+	version string
 	Parameters url.Values
 }
 
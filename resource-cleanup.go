@@ -0,0 +1,173 @@
+package apperix
+
+import (
+	"fmt"
+	"strings"
+)
+
+/*
+	likeEscaper escapes the wildcards of LIKE patterns using backslashes.
+*/
+var likeEscaper = strings.NewReplacer(
+	"\\", "\\\\",
+	"%", "\\%",
+	"_", "\\_",
+)
+
+/*
+	isDescendantOf returns true if the resource identified by the given
+	identifier is a (transitive) child of the given ancestor.
+*/
+func isDescendantOf(
+	resources map[string] resourceObject,
+	identifier string,
+	ancestor string,
+) bool {
+	resourceObj, exists := resources[identifier]
+	for exists {
+		parent := resourceObj.Parent()
+		if parent == ancestor {
+			return true
+		}
+		if parent == "" {
+			return false
+		}
+		resourceObj, exists = resources[parent]
+	}
+	return false
+}
+
+/*
+	ForgetResource removes the ownership and permission entries
+	of the given resource from both database and caches.
+	In case recursive is true all entries of descendant resources
+	sharing the variable values of the given resource are removed as well.
+	Should be called whenever a resource instance is deleted,
+	to prevent new instances reusing its variable values
+	from inheriting its permissions.
+*/
+func (service *Service) ForgetResource(
+	resourceId ResourceIdentifier,
+	recursive bool,
+) (
+	err error,
+) {
+	err = service.rejectDuringBatch()
+	if err != nil {
+		return err
+	}
+	values := make([]string, 0)
+	for _, segment := range resourceId.path {
+		if segment.isVariable() {
+			values = append(values, segment.serializedValue())
+		}
+	}
+	suffix := ""
+	if len(values) > 0 {
+		suffix = ConcatStrings("/", strings.Join(values, "/"))
+	}
+
+	//collect affected serialized identifiers
+	exactKeys := []string {
+		resourceId.Serialize(),
+	}
+	prefixKeys := make([]string, 0)
+	if recursive {
+		resources := service.resources()
+		for identifier := range resources {
+			if isDescendantOf(resources, identifier, resourceId.Identifier()) {
+				key := ConcatStrings(identifier, suffix)
+				exactKeys = append(exactKeys, key)
+				prefixKeys = append(prefixKeys, ConcatStrings(key, "/"))
+			}
+		}
+	}
+	matches := func(serialized string) bool {
+		for _, key := range exactKeys {
+			if serialized == key {
+				return true
+			}
+		}
+		for _, prefix := range prefixKeys {
+			if strings.HasPrefix(serialized, prefix) {
+				return true
+			}
+		}
+		return false
+	}
+
+	txn, err := service.database.Begin()
+	if err != nil {
+		return fmt.Errorf("Could not begin cleanup transaction: %s", err)
+	}
+	defer func() {
+		if err != nil {
+			txn.Rollback()
+		}
+	}()
+
+	//gather affected resource entries
+	ids := make([]int64, 0)
+	gathered := make(map[int64] bool)
+	for index, key := range exactKeys {
+		query := `SELECT id, str_id FROM resources WHERE str_id = ?`
+		arguments := []interface{} {key}
+		if index > 0 {
+			//instances of descendants append their own variable values
+			query = `
+				SELECT id, str_id FROM resources
+				WHERE str_id = ? OR str_id LIKE ? ESCAPE '\'
+			`
+			arguments = append(arguments, ConcatStrings(
+				likeEscaper.Replace(prefixKeys[index - 1]),
+				"%",
+			))
+		}
+		rows, err := txn.Query(query, arguments...)
+		if err != nil {
+			return fmt.Errorf("Failed gathering resource entries: %s", err)
+		}
+		for rows.Next() {
+			var id int64
+			var serialized string
+			err = rows.Scan(&id, &serialized)
+			if err != nil {
+				rows.Close()
+				return fmt.Errorf("Failed gathering resource entries: %s", err)
+			}
+			//LIKE ignores case, so candidates have to be matched exactly
+			if matches(serialized) && !gathered[id] {
+				gathered[id] = true
+				ids = append(ids, id)
+			}
+		}
+		rows.Close()
+	}
+
+	//delete entries
+	for _, id := range ids {
+		for _, query := range []string {
+			`DELETE FROM resource_permissions WHERE resource_id = ?`,
+			`DELETE FROM resource_owners WHERE resource_id = ?`,
+			`DELETE FROM ownership_history WHERE resource_id = ?`,
+			`DELETE FROM resources WHERE id = ?`,
+		} {
+			_, err = txn.Exec(query, id)
+			if err != nil {
+				return fmt.Errorf(
+					"Failed deleting resource entries of '%s': %s",
+					resourceId.String(),
+					err,
+				)
+			}
+		}
+	}
+	err = txn.Commit()
+	if err != nil {
+		return fmt.Errorf("Could not commit cleanup transaction: %s", err)
+	}
+
+	service.permissionProvider.InvalidateMatching(matches)
+	service.ownerProvider.InvalidateMatching(matches)
+	return nil
+}
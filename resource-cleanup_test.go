@@ -0,0 +1,142 @@
+package apperix
+
+import (
+	"testing"
+	"net/http"
+)
+
+/*
+	cleanupTestService returns a service with the resources
+	"/users/{user}/posts/{post}" where posts are forgotten on deletion.
+*/
+func cleanupTestService(t *testing.T) *Service {
+	t.Helper()
+	return newTestService(t, ServiceConfig {
+		Resources: map[string] Resource {
+			"users": Resource {
+				Type: STATIC,
+				Name: "users",
+			},
+			"user": Resource {
+				Type: VARIABLE,
+				Parent: "users",
+				Pattern: "^[a-z]+$",
+			},
+			"posts": Resource {
+				Type: STATIC,
+				Parent: "user",
+				Name: "posts",
+			},
+			"post": Resource {
+				Type: VARIABLE,
+				Parent: "posts",
+				Pattern: "^[0-9]+$",
+				ForgetOnDelete: true,
+				Handlers: map[Method] Handler {
+					DELETE: okHandler,
+				},
+			},
+		},
+	})
+}
+
+/*
+	cleanupTestIdentifier returns the identifier of the given resource
+	with the given user and post variable values.
+*/
+func cleanupTestIdentifier(
+	t *testing.T,
+	service *Service,
+	identifier string,
+	user string,
+	post string,
+) ResourceIdentifier {
+	t.Helper()
+	variables := map[string] string {
+		"user": user,
+	}
+	if post != "" {
+		variables["post"] = post
+	}
+	resourceId, err := service.GetResourceIdentifier(identifier, variables)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resourceId
+}
+
+func TestForgetResource(t *testing.T) {
+	service := cleanupTestService(t)
+	userId, _ := testUser(t, service, "user")
+	alice := cleanupTestIdentifier(t, service, "user", "alice", "")
+	alicePosts := cleanupTestIdentifier(t, service, "posts", "alice", "")
+	alicePost := cleanupTestIdentifier(t, service, "post", "alice", "1")
+	alicexPost := cleanupTestIdentifier(t, service, "post", "alicex", "1")
+	bobPost := cleanupTestIdentifier(t, service, "post", "bob", "1")
+	all := []ResourceIdentifier {alice, alicePosts, alicePost, alicexPost, bobPost}
+	for _, resourceId := range all {
+		err := service.AssignPermissions(resourceId, userId, Permissions {
+			Read: true,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = service.AssignOwner(resourceId, userId)
+		if err != nil {
+			t.Fatal(err)
+		}
+		//warm up caches
+		_, _, err = service.ResolvePermissionsFor(resourceId, &userId)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	remembered := func(resourceId ResourceIdentifier) bool {
+		_, err := service.GetPermissionsFor(resourceId, userId)
+		return err == nil
+	}
+
+	err := service.ForgetResource(alice, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if remembered(alice) || !remembered(alicePosts) || !remembered(alicePost) {
+		t.Error("Expected only the resource itself to be forgotten")
+	}
+
+	err = service.ForgetResource(alice, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if remembered(alicePosts) || remembered(alicePost) {
+		t.Error("Expected descendants to be forgotten")
+	}
+	if !remembered(alicexPost) || !remembered(bobPost) {
+		t.Error("Expected resources of other variable values to be kept")
+	}
+	isOwner, _, err := service.ResolvePermissionsFor(alicePost, &userId)
+	if err != nil || isOwner {
+		t.Errorf("Expected forgotten ownership to be dropped from caches (%v)", err)
+	}
+	history, err := service.GetOwnershipHistory(alicePost)
+	if err != nil || len(history) != 0 {
+		t.Errorf("Expected forgotten ownership history, got %v (%v)", history, err)
+	}
+}
+
+func TestForgetOnDelete(t *testing.T) {
+	service := cleanupTestService(t)
+	userId, token := testUser(t, service, "user")
+	post := cleanupTestIdentifier(t, service, "post", "alice", "1")
+	err := service.AssignPermissions(post, userId, Permissions {
+		Delete: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectStatus(t, testRequest(service, "DELETE", "/users/alice/posts/1", token), http.StatusOK)
+	_, err = service.GetPermissionsFor(post, userId)
+	if _, isNotFound := err.(NotFoundError); !isNotFound {
+		t.Errorf("Expected permissions of the deleted resource to be forgotten, got %v", err)
+	}
+}
@@ -0,0 +1,265 @@
+package apperix
+
+import (
+	"fmt"
+	"sort"
+)
+
+/*
+	HandlerRegistry maps handler names used in resource definition files
+	to handler functions.
+*/
+type HandlerRegistry map[string] Handler
+
+/*
+	The BindingReport type lists problems found
+	while binding handlers to a resource definition.
+	Unbound methods reference handler names missing in the registry,
+	unused handlers are registered but never referenced.
+*/
+type BindingReport struct {
+	UnboundMethods []string
+	UnusedHandlers []string
+}
+
+/*
+	Complete returns true if all methods are bound
+	and all registered handlers are used.
+*/
+func (report *BindingReport) Complete() bool {
+	return len(report.UnboundMethods) < 1 && len(report.UnusedHandlers) < 1
+}
+
+type inheritanceDefinition struct {
+	Owner bool `json:"owner" yaml:"owner" toml:"owner"`
+	UserPermissions bool `json:"user-permissions" yaml:"user-permissions" toml:"user-permissions"`
+	OtherUserPermissions bool `json:"other-user-permissions" yaml:"other-user-permissions" toml:"other-user-permissions"`
+	GuestPermissions bool `json:"guest-permissions" yaml:"guest-permissions" toml:"guest-permissions"`
+}
+
+type permissionsDefinition struct {
+	User []string `json:"user" yaml:"user" toml:"user"`
+	Guest []string `json:"guest" yaml:"guest" toml:"guest"`
+	Inheritance inheritanceDefinition `json:"inheritance" yaml:"inheritance" toml:"inheritance"`
+	ForbidGuestGrants bool `json:"forbid-guest-grants" yaml:"forbid-guest-grants" toml:"forbid-guest-grants"`
+}
+
+type resourceDefinition struct {
+	Name string `json:"name" yaml:"name" toml:"name"`
+	Parent string `json:"parent" yaml:"parent" toml:"parent"`
+	Type string `json:"type" yaml:"type" toml:"type"`
+	Pattern string `json:"pattern" yaml:"pattern" toml:"pattern"`
+	Kind string `json:"kind" yaml:"kind" toml:"kind"`
+	Priority int `json:"priority" yaml:"priority" toml:"priority"`
+	MaxUploadSize int64 `json:"max-upload-size" yaml:"max-upload-size" toml:"max-upload-size"`
+	CustomPermissions []string `json:"custom-permissions" yaml:"custom-permissions" toml:"custom-permissions"`
+	ForgetOnDelete bool `json:"forget-on-delete" yaml:"forget-on-delete" toml:"forget-on-delete"`
+	Permissions permissionsDefinition `json:"permissions" yaml:"permissions" toml:"permissions"`
+	//handler names mapped by method name
+	Handlers map[string] string `json:"handlers" yaml:"handlers" toml:"handlers"`
+	//handler names mapped by method name per API version,
+	//empty names remove inherited handlers
+	Versions map[string] map[string] string `json:"versions" yaml:"versions" toml:"versions"`
+}
+
+type resourceTreeDefinition struct {
+	Resources map[string] resourceDefinition `json:"resources" yaml:"resources" toml:"resources"`
+}
+
+/*
+	methodByName returns the method identified by the given name,
+	method names equal the names of their permissions, like "read".
+*/
+func methodByName(name string) (Method, bool) {
+	for index, permissionName := range permissionNames {
+		if permissionName == name {
+			return Method(index), true
+		}
+	}
+	return CREATE, false
+}
+
+/*
+	LoadResources reads a resource tree definition from the given
+	JSON (.json), YAML (.yaml, .yml) or TOML (.toml) file and binds
+	handlers from the given registry by name.
+	The returned resources can be used as ServiceConfig.Resources.
+	Methods referencing unknown handlers are left out and listed
+	in the report along with registered handlers never referenced.
+	An error will be returned in case the file can't be parsed or
+	contains unknown resource types, methods or permissions.
+*/
+func LoadResources(
+	path string,
+	registry HandlerRegistry,
+) (
+	resources map[string] Resource,
+	report BindingReport,
+	err error,
+) {
+	var definition resourceTreeDefinition
+	err = decodeConfigFile(path, &definition)
+	if err != nil {
+		return nil, report, err
+	}
+
+	//collect custom permissions to parse permission names
+	declared := make(map[string] Resource)
+	for identifier, resourceDef := range definition.Resources {
+		declared[identifier] = Resource {
+			Parent: resourceDef.Parent,
+			CustomPermissions: resourceDef.CustomPermissions,
+		}
+	}
+	customPermissions, err := newCustomPermissionSet(declared)
+	if err != nil {
+		return nil, report, err
+	}
+
+	used := make(map[string] bool)
+	resources = make(map[string] Resource)
+	for _, identifier := range sortedResourceIdentifiers(declared) {
+		resourceDef := definition.Resources[identifier]
+		resource := Resource {
+			Name: resourceDef.Name,
+			Parent: resourceDef.Parent,
+			Pattern: resourceDef.Pattern,
+			Priority: resourceDef.Priority,
+			MaxUploadSize: resourceDef.MaxUploadSize,
+			CustomPermissions: resourceDef.CustomPermissions,
+			ForgetOnDelete: resourceDef.ForgetOnDelete,
+		}
+		switch resourceDef.Type {
+		case "", "static":
+			resource.Type = STATIC
+		case "variable":
+			resource.Type = VARIABLE
+		case "wildcard":
+			resource.Type = WILDCARD
+		default:
+			return nil, report, fmt.Errorf(
+				"Unknown type '%s' of resource '%s'",
+				resourceDef.Type,
+				identifier,
+			)
+		}
+		var known bool
+		resource.Kind, known = variableKindByName(resourceDef.Kind)
+		if !known {
+			return nil, report, fmt.Errorf(
+				"Unknown kind '%s' of resource '%s'",
+				resourceDef.Kind,
+				identifier,
+			)
+		}
+
+		//parse permissions, custom ones have to be declared by the resource or its ancestors
+		inScope := make([]string, 0)
+		for ancestor, depth := identifier, 0; depth <= len(declared); depth++ {
+			ancestorDef, exists := declared[ancestor]
+			if !exists {
+				break
+			}
+			inScope = append(inScope, ancestorDef.CustomPermissions...)
+			if ancestor == "root" {
+				break
+			}
+			ancestor = ancestorDef.Parent
+			if ancestor == "" {
+				ancestor = "root"
+			}
+		}
+		resource.Permissions.UserPermissions, err = customPermissions.parseNames(
+			resourceDef.Permissions.User,
+			inScope,
+		)
+		if err != nil {
+			return nil, report, fmt.Errorf("Invalid user permissions of '%s': %s", identifier, err)
+		}
+		resource.Permissions.GuestPermissions, err = customPermissions.parseNames(
+			resourceDef.Permissions.Guest,
+			inScope,
+		)
+		if err != nil {
+			return nil, report, fmt.Errorf("Invalid guest permissions of '%s': %s", identifier, err)
+		}
+		resource.Permissions.Inheritance = PermissionInheritance {
+			Owner: resourceDef.Permissions.Inheritance.Owner,
+			UserPermissions: resourceDef.Permissions.Inheritance.UserPermissions,
+			OtherUserPermissions: resourceDef.Permissions.Inheritance.OtherUserPermissions,
+			GuestPermissions: resourceDef.Permissions.Inheritance.GuestPermissions,
+		}
+		resource.Permissions.ForbidGuestGrants = resourceDef.Permissions.ForbidGuestGrants
+
+		//bind handlers
+		bind := func(handlerNames map[string] string, prefix string) (map[Method] Handler, error) {
+			handlers := make(map[Method] Handler)
+			methodNames := make([]string, 0, len(handlerNames))
+			for methodName := range handlerNames {
+				methodNames = append(methodNames, methodName)
+			}
+			sort.Strings(methodNames)
+			for _, methodName := range methodNames {
+				handlerName := handlerNames[methodName]
+				method, exists := methodByName(methodName)
+				if !exists {
+					return nil, fmt.Errorf(
+						"Unknown method '%s' of resource '%s'",
+						methodName,
+						identifier,
+					)
+				}
+				if handlerName == "" && prefix != "" {
+					//removes the inherited handler
+					handlers[method] = nil
+					continue
+				}
+				handler, exists := registry[handlerName]
+				if !exists {
+					report.UnboundMethods = append(report.UnboundMethods, fmt.Sprintf(
+						"%s:%s%s ('%s')",
+						identifier,
+						prefix,
+						methodName,
+						handlerName,
+					))
+					continue
+				}
+				used[handlerName] = true
+				handlers[method] = handler
+			}
+			return handlers, nil
+		}
+		resource.Handlers, err = bind(resourceDef.Handlers, "")
+		if err != nil {
+			return nil, report, err
+		}
+		versionNames := make([]string, 0, len(resourceDef.Versions))
+		for version := range resourceDef.Versions {
+			versionNames = append(versionNames, version)
+		}
+		sort.Strings(versionNames)
+		for _, version := range versionNames {
+			if resource.VersionHandlers == nil {
+				resource.VersionHandlers = make(map[string] map[Method] Handler)
+			}
+			resource.VersionHandlers[version], err = bind(
+				resourceDef.Versions[version],
+				ConcatStrings(version, ":"),
+			)
+			if err != nil {
+				return nil, report, err
+			}
+		}
+		resources[identifier] = resource
+	}
+
+	//collect unused handlers
+	for handlerName := range registry {
+		if !used[handlerName] {
+			report.UnusedHandlers = append(report.UnusedHandlers, handlerName)
+		}
+	}
+	sort.Strings(report.UnusedHandlers)
+	return resources, report, nil
+}
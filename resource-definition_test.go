@@ -0,0 +1,116 @@
+package apperix
+
+import (
+	"testing"
+	"net/http"
+)
+
+func TestLoadResourcesBindsHandlers(t *testing.T) {
+	path := writeConfigFile(t, "resources.yaml", `
+resources:
+  items:
+    name: items
+    permissions:
+      guest: [read]
+    handlers:
+      read: listItems
+  item:
+    parent: items
+    type: variable
+    kind: int64
+    pattern: "^[0-9]+$"
+    permissions:
+      guest: [read, update]
+      forbid-guest-grants: true
+    handlers:
+      read: getItem
+      update: missing
+    versions:
+      v2:
+        read: ""
+`)
+	resources, report, err := LoadResources(path, HandlerRegistry {
+		"listItems": okHandler,
+		"getItem": okHandler,
+		"unused": okHandler,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	item := resources["item"]
+	if item.Type != VARIABLE ||
+		item.Kind != INT64 ||
+		item.Parent != "items" ||
+		!item.Permissions.GuestPermissions.Update ||
+		!item.Permissions.ForbidGuestGrants {
+		t.Errorf("Unexpected resource %+v", item)
+	}
+	if item.Handlers[READ] == nil {
+		t.Error("Expected handler bound to READ")
+	}
+	if _, bound := item.Handlers[UPDATE]; bound {
+		t.Error("Expected method referencing a missing handler to be left out")
+	}
+	if handler, exists := item.VersionHandlers["v2"][READ]; !exists || handler != nil {
+		t.Error("Expected empty handler name to remove the inherited handler")
+	}
+	if report.Complete() ||
+		len(report.UnboundMethods) != 1 ||
+		report.UnboundMethods[0] != "item:update ('missing')" ||
+		len(report.UnusedHandlers) != 1 ||
+		report.UnusedHandlers[0] != "unused" {
+		t.Errorf("Unexpected binding report %+v", report)
+	}
+
+	service := newTestService(t, ServiceConfig {
+		Versioning: VersioningConfig {
+			Versions: []string {"v1", "v2"},
+			Prefix: true,
+		},
+		Resources: resources,
+	})
+	expectStatus(t, testRequest(service, "GET", "/v1/items/42", ""), http.StatusOK)
+	expectStatus(t, testRequest(service, "GET", "/v2/items", ""), http.StatusOK)
+	expectStatus(t, testRequest(service, "GET", "/v2/items/42", ""), http.StatusMethodNotAllowed)
+}
+
+func TestLoadResourcesErrors(t *testing.T) {
+	for _, test := range []struct {
+		description string
+		definition string
+	} {
+		{
+			"unknown type",
+			`{"resources": {"items": {"name": "items", "type": "dynamic"}}}`,
+		},
+		{
+			"unknown kind",
+			`{"resources": {"item": {"type": "variable", "kind": "float"}}}`,
+		},
+		{
+			"unknown method",
+			`{"resources": {"items": {"name": "items", "handlers": {"fetch": "listItems"}}}}`,
+		},
+		{
+			"unknown versioned method",
+			`{"resources": {"items": {"name": "items", "versions": {"v2": {"fetch": "listItems"}}}}}`,
+		},
+		{
+			"unknown permission",
+			`{"resources": {"items": {"name": "items", "permissions": {"guest": ["fly"]}}}}`,
+		},
+		{
+			"malformed file",
+			`{"resources": `,
+		},
+	} {
+		path := writeConfigFile(t, "resources.json", test.definition)
+		_, _, err := LoadResources(path, HandlerRegistry {
+			"listItems": okHandler,
+		})
+		if err == nil {
+			t.Errorf("%s: expected loading to fail", test.description)
+		}
+	}
+}
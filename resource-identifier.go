@@ -1,7 +1,11 @@
 package apperix
 
 import (
+	"fmt"
 	"bytes"
+	"strings"
+	"net/url"
+	"database/sql"
 )
 
 type resourceIdSegment struct {
@@ -9,6 +13,43 @@ type resourceIdSegment struct {
 	identifier string
 	name string
 	value string
+	kind VariableKind
+	parsed interface{}
+}
+
+/*
+	isVariable returns true for segments of variable and wildcard resources.
+*/
+func (segment *resourceIdSegment) isVariable() bool {
+	return segment.typ == VARIABLE || segment.typ == WILDCARD
+}
+
+/*
+	variableValueEscaper percent-encodes the characters of variable
+	and wildcard values which would make serialized resource identifiers
+	ambiguous, values without them are serialized unchanged.
+*/
+var variableValueEscaper = strings.NewReplacer(
+	"%", "%25",
+	"/", "%2F",
+)
+
+/*
+	variableValueUnescaper reverts variableValueEscaper.
+*/
+var variableValueUnescaper = strings.NewReplacer(
+	"%25", "%",
+	"%2F", "/",
+)
+
+/*
+	serializedValue returns the value of a variable segment
+	as represented in serialized resource identifiers,
+	values are escaped to keep their slashes distinguishable
+	from the separators of the serialized identifier.
+*/
+func (segment *resourceIdSegment) serializedValue() string {
+	return variableValueEscaper.Replace(segment.value)
 }
 
 type ResourceIdentifier struct {
@@ -27,7 +68,7 @@ func (resId *ResourceIdentifier) VariableValues() map[string] string {
 	result := make(map[string] string)
 	var segment resourceIdSegment
 	for _, segment = range resId.path {
-		if segment.typ != VARIABLE {
+		if !segment.isVariable() {
 			continue
 		}
 		result[segment.identifier] = segment.value
@@ -35,15 +76,30 @@ func (resId *ResourceIdentifier) VariableValues() map[string] string {
 	return result
 }
 
+/*
+	Url returns the URL path of the resource,
+	variable values and names are percent-encoded.
+*/
 func (resId *ResourceIdentifier) Url() string {
+	if len(resId.path) < 1 {
+		return "/"
+	}
 	var buffer bytes.Buffer
 	var segment resourceIdSegment
 	for _, segment = range resId.path {
 		buffer.WriteRune('/')
-		if segment.typ == VARIABLE {
-			buffer.Write([]byte(segment.value))
+		switch segment.typ {
+		case VARIABLE:
+			buffer.WriteString(url.PathEscape(segment.value))
+		case WILDCARD:
+			parts := strings.Split(segment.value, "/")
+			for index, part := range parts {
+				parts[index] = url.PathEscape(part)
+			}
+			buffer.WriteString(strings.Join(parts, "/"))
+		default:
+			buffer.WriteString(url.PathEscape(segment.name))
 		}
-		buffer.Write([]byte(segment.name))
 	}
 	return buffer.String()
 }
@@ -54,7 +110,7 @@ func (resId *ResourceIdentifier) String() string {
 	for _, segment = range resId.path {
 		buffer.WriteRune('/')
 		buffer.Write([]byte(segment.identifier))
-		if segment.typ == VARIABLE {
+		if segment.isVariable() {
 			buffer.WriteRune('(')
 			buffer.Write([]byte(segment.value))
 			buffer.WriteRune(')')
@@ -72,11 +128,11 @@ func (resId *ResourceIdentifier) Serialize() string {
 	}
 	buffer.WriteString(resId.path[len(resId.path) - 1].identifier)
 	for _, segment = range resId.path {
-		if segment.typ != VARIABLE {
+		if !segment.isVariable() {
 			continue
 		}
 		buffer.WriteRune('/')
-		buffer.Write([]byte(segment.value))
+		buffer.WriteString(segment.serializedValue())
 	}
 	return buffer.String()
 }
@@ -98,4 +154,133 @@ func (resId *ResourceIdentifier) Parent() (
 
 func (resId *ResourceIdentifier) HasParent() (bool) {
 	return len(resId.path) > 0
-}
\ No newline at end of file
+}
+
+/*
+	escapedIdentifiersVersion is the database version
+	since which variable values of serialized resource identifiers are escaped.
+*/
+const escapedIdentifiersVersion = 1
+
+/*
+	escapeLegacyIdentifier returns the given resource identifier
+	serialized before variable values were escaped in the current notation.
+	Values of legacy identifiers are separated by unescaped slashes,
+	slashes of the only variable value of a resource are retained.
+	False will be returned in case the values can't be told apart.
+*/
+func escapeLegacyIdentifier(
+	serialized string,
+	resources map[string] resourceObject,
+) (
+	escaped string,
+	ok bool,
+) {
+	parts := strings.Split(serialized, "/")
+	values := parts[1:]
+	if resourceObj, exists := resources[parts[0]]; exists {
+		variables := 0
+		for resourceObj != nil {
+			if _, isVariable := resourceObj.(*variableResource); isVariable {
+				variables++
+			}
+			resourceObj = resources[resourceObj.Parent()]
+		}
+		if variables == 1 && len(values) > 1 {
+			values = []string {strings.Join(values, "/")}
+		} else if len(values) != variables {
+			return serialized, false
+		}
+	}
+	var buffer bytes.Buffer
+	buffer.WriteString(parts[0])
+	for _, value := range values {
+		buffer.WriteRune('/')
+		buffer.WriteString(variableValueEscaper.Replace(value))
+	}
+	return buffer.String(), true
+}
+
+/*
+	migrateResourceIdentifiers escapes the variable values
+	of resource identifiers persisted before they were escaped,
+	so their owners and permissions remain assigned.
+	Identifiers which values can't be told apart are left unchanged
+	and reported. The database is migrated once.
+	An error will be returned in case the database could not be accessed.
+*/
+func migrateResourceIdentifiers(
+	database *sql.DB,
+	resources map[string] resourceObject,
+) error {
+	var version int
+	err := database.QueryRow(`PRAGMA user_version`).Scan(&version)
+	if err != nil {
+		return fmt.Errorf("Could not query database version: %s", err)
+	}
+	if version >= escapedIdentifiersVersion {
+		return nil
+	}
+
+	transaction, err := database.Begin()
+	if err != nil {
+		return fmt.Errorf("Could not begin transaction: %s", err)
+	}
+	defer transaction.Rollback()
+	rows, err := transaction.Query(`SELECT id, str_id FROM resources`)
+	if err != nil {
+		return fmt.Errorf("Could not query resource identifiers: %s", err)
+	}
+	escaped := make(map[int64] string)
+	for rows.Next() {
+		var id int64
+		var serialized string
+		if err := rows.Scan(&id, &serialized); err != nil {
+			rows.Close()
+			return fmt.Errorf("Could not scan resource identifier: %s", err)
+		}
+		migrated, ok := escapeLegacyIdentifier(serialized, resources)
+		if !ok {
+			fmt.Printf("WARNING: Could not migrate resource identifier '%s'\n", serialized)
+			continue
+		}
+		if migrated != serialized {
+			escaped[id] = migrated
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("Could not query resource identifiers: %s", err)
+	}
+
+	//escaped identifiers may equal legacy ones not migrated yet,
+	//so all are moved out of the way first
+	for id := range escaped {
+		_, err = transaction.Exec(
+			`UPDATE resources SET str_id = ? WHERE id = ?`,
+			fmt.Sprintf("/migrating/%d", id),
+			id,
+		)
+		if err != nil {
+			return fmt.Errorf("Could not migrate resource identifier: %s", err)
+		}
+	}
+	for id, migrated := range escaped {
+		_, err = transaction.Exec(
+			`UPDATE resources SET str_id = ? WHERE id = ?`,
+			migrated,
+			id,
+		)
+		if err != nil {
+			return fmt.Errorf("Could not migrate resource identifier: %s", err)
+		}
+	}
+	_, err = transaction.Exec(fmt.Sprintf(
+		`PRAGMA user_version = %d`,
+		escapedIdentifiersVersion,
+	))
+	if err != nil {
+		return fmt.Errorf("Could not update database version: %s", err)
+	}
+	return transaction.Commit()
+}
@@ -0,0 +1,207 @@
+package apperix
+
+import (
+	"fmt"
+	"testing"
+)
+
+/*
+	TestSerializeEncodedSlashes verifies variable values
+	containing encoded slashes serialize to distinct keys
+	which parse back to the same values.
+*/
+func TestSerializeEncodedSlashes(t *testing.T) {
+	conf := ServiceConfig {
+		Name: "serialize",
+		Authentication: AuthenticationConfig {
+			Path: "auth",
+		},
+		Resources: map[string] Resource {
+			"items": Resource {
+				Type: STATIC,
+				Name: "items",
+			},
+			"a": Resource {
+				Type: VARIABLE,
+				Parent: "items",
+				Pattern: ".+",
+			},
+			"b": Resource {
+				Type: VARIABLE,
+				Parent: "a",
+				Pattern: ".+",
+			},
+		},
+	}
+	service := routedService(t, conf, "")
+	tree := service.tree.Load().(*resourceTree)
+
+	keys := make(map[string] string)
+	for _, urlPath := range []string {
+		"/items/x%2Fy/z",
+		"/items/x/y%2Fz",
+		"/items/x%252Fy/z",
+	} {
+		path, err := pathSegments(urlPath)
+		if err != nil {
+			t.Fatalf("%s: %s", urlPath, err)
+		}
+		target, err := tree.router.route(path, "", false)
+		if err != nil {
+			t.Fatalf("%s: %s", urlPath, err)
+		}
+		resourceId, err := resourceIdentifierIn(
+			tree.resources,
+			target.identifier,
+			target.variables,
+		)
+		if err != nil {
+			t.Fatalf("%s: %s", urlPath, err)
+		}
+		key := resourceId.Serialize()
+		if other, exists := keys[key]; exists {
+			t.Errorf("%s and %s both serialize to '%s'", other, urlPath, key)
+		}
+		keys[key] = urlPath
+
+		parsed, err := service.ParseResourceIdentifier(key)
+		if err != nil {
+			t.Fatalf("%s: %s", key, err)
+		}
+		if parsed.Url() != resourceId.Url() {
+			t.Errorf("'%s' parsed as %s, expected %s", key, parsed.Url(), resourceId.Url())
+		}
+	}
+}
+
+/*
+	identifierTestConfig returns a configuration with the variable resource
+	"item", the wildcard resource "file" and the resource "pair"
+	of two variables, persisted in the given location.
+*/
+func identifierTestConfig(location string) ServiceConfig {
+	return ServiceConfig {
+		Database: DatabaseConfig {
+			Location: location,
+		},
+		Resources: map[string] Resource {
+			"items": Resource {
+				Type: STATIC,
+				Name: "items",
+			},
+			"item": Resource {
+				Type: VARIABLE,
+				Parent: "items",
+				Name: "item",
+				Pattern: ".+",
+			},
+			"files": Resource {
+				Type: STATIC,
+				Name: "files",
+			},
+			"file": Resource {
+				Type: WILDCARD,
+				Parent: "files",
+				Pattern: ".+",
+			},
+			"pair": Resource {
+				Type: VARIABLE,
+				Parent: "item",
+				Name: "pair",
+				Pattern: ".+",
+			},
+		},
+	}
+}
+
+func TestSerializeEscapesVariablesAndWildcardsAlike(t *testing.T) {
+	service := newTestService(t, identifierTestConfig(""))
+	for _, test := range []struct {
+		identifier string
+		variables map[string] string
+		expected string
+	} {
+		{"item", map[string] string {"item": "50%/a b"}, "item/50%25%2Fa b"},
+		{"file", map[string] string {"file": "50%/a b"}, "file/50%25%2Fa b"},
+		{"pair", map[string] string {"item": "a/b", "pair": "%2F"}, "pair/a%2Fb/%252F"},
+	} {
+		resourceId, err := service.GetResourceIdentifier(test.identifier, test.variables)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if serialized := resourceId.Serialize(); serialized != test.expected {
+			t.Errorf("Expected '%s', got '%s'", test.expected, serialized)
+		}
+		parsed, err := service.ParseResourceIdentifier(test.expected)
+		if err != nil || fmt.Sprint(parsed.VariableValues()) != fmt.Sprint(test.variables) {
+			t.Errorf("'%s': expected values %v, got %v (%v)", test.expected, test.variables, parsed.VariableValues(), err)
+		}
+	}
+}
+
+func TestLegacyResourceIdentifiersAreMigrated(t *testing.T) {
+	location := t.TempDir()
+	service := newTestService(t, identifierTestConfig(location))
+	userId, err := service.CreateUser("user", "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	//identifiers persisted before variable values were escaped
+	legacy := []struct {
+		identifier string
+		variables map[string] string
+		serialized string
+	} {
+		{"item", map[string] string {"item": "50%"}, "item/50%"},
+		{"item", map[string] string {"item": "50%25"}, "item/50%25"},
+		{"item", map[string] string {"item": "a/b"}, "item/a/b"},
+		{"pair", map[string] string {"item": "a", "pair": "b"}, "pair/a/b"},
+	}
+	for _, entry := range legacy {
+		resourceId, err := service.GetResourceIdentifier(entry.identifier, entry.variables)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = service.AssignOwner(resourceId, userId)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = service.database.Exec(
+			`UPDATE resources SET str_id = ? WHERE str_id = ?`,
+			entry.serialized,
+			resourceId.Serialize(),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	//ambiguous identifiers are left unchanged
+	_, err = service.database.Exec(`INSERT INTO resources (str_id) VALUES ('pair/a/b/c')`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = service.database.Exec(`PRAGMA user_version = 0`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	service.database.Close()
+
+	for restart := 0; restart < 2; restart++ {
+		service = newTestService(t, identifierTestConfig(location))
+		for _, entry := range legacy {
+			resourceId, _ := service.GetResourceIdentifier(entry.identifier, entry.variables)
+			owners, err := service.GetOwnersOf(resourceId)
+			if err != nil || len(owners) != 1 || owners[0].String() != userId.String() {
+				t.Errorf("'%s': expected owner to be retained, got %v (%v)", entry.serialized, owners, err)
+			}
+		}
+		var count int
+		err = service.database.QueryRow(
+			`SELECT COUNT(*) FROM resources WHERE str_id = 'pair/a/b/c'`,
+		).Scan(&count)
+		if err != nil || count != 1 {
+			t.Errorf("Expected ambiguous identifier to be left unchanged (%v)", err)
+		}
+		service.database.Close()
+	}
+}
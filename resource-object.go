@@ -2,13 +2,17 @@ package apperix
 
 import (
 	"fmt"
+	"sort"
 	"regexp"
+	"strings"
 )
 
 type resourceObject interface {
 	Identifier() string
 	Name() string
-	Handler(Method) (Handler, error)
+	Handler(int, Method) (Handler, error)
+	Methods(int) []Method
+	Pattern() string
 	HasStaticChild(string) bool
 	HasVariableChild(string) bool
 	StaticChildIdentifier(string) (string, error)
@@ -16,8 +20,17 @@ type resourceObject interface {
 	NumberOfVariableChildren() int
 	DefineStaticChild(string, string)
 	DefineVariableChild(string)
+	RemoveStaticChild(string)
+	RemoveVariableChild(string)
+	SortVariableChildren(func(string, string) bool)
+	clone() resourceObject
 	Parent() string
 	DefaultPermissions() DefaultResourcePermissions
+	CustomPermissions() []string
+	Policy() Policy
+	ForgetOnDelete() bool
+	CreationTemplate() *CreationTemplate
+	Schemas() map[Method] OperationSchema
 }
 
 type staticResource struct {
@@ -25,7 +38,13 @@ type staticResource struct {
 	name string
 	parent string
 	defaultPermissions DefaultResourcePermissions
-	handlers map[Method] Handler
+	customPermissions []string
+	policy Policy
+	forgetOnDelete bool
+	creationTemplate *CreationTemplate
+	schemas map[Method] OperationSchema
+	//resolved handlers of each API version
+	handlers []map[Method] Handler
 	staticChildren map[string] string
 	variableChildren [] string
 }
@@ -38,12 +57,20 @@ func (res *staticResource) Name() string {
 	return res.name
 }
 
-func (res *staticResource) Handler(method Method) (Handler, error) {
-	if handler, exists := res.handlers[method]; exists {
-		return handler, nil
-	} else {
-		return handler, fmt.Errorf("No handler found for method %s", method)
-	}
+func (res *staticResource) Handler(version int, method Method) (Handler, error) {
+	return versionHandler(res.handlers, version, method)
+}
+
+/*
+	Methods returns the methods handled by the resource
+	in the given API version in ascending order.
+*/
+func (res *staticResource) Methods(version int) []Method {
+	return versionMethods(res.handlers, version)
+}
+
+func (res *staticResource) Pattern() string {
+	return ""
 }
 
 func (res *staticResource) HasStaticChild(name string) bool {
@@ -87,6 +114,42 @@ func (res *staticResource) DefineVariableChild(identifier string) {
 	res.variableChildren = append(res.variableChildren, identifier)
 }
 
+func (res *staticResource) RemoveStaticChild(name string) {
+	delete(res.staticChildren, name)
+}
+
+func (res *staticResource) RemoveVariableChild(identifier string) {
+	for index, value := range res.variableChildren {
+		if value == identifier {
+			res.variableChildren = append(
+				res.variableChildren[:index:index],
+				res.variableChildren[index + 1:]...
+			)
+			return
+		}
+	}
+}
+
+func (res *staticResource) SortVariableChildren(less func(string, string) bool) {
+	sort.SliceStable(res.variableChildren, func(i, j int) bool {
+		return less(res.variableChildren[i], res.variableChildren[j])
+	})
+}
+
+/*
+	clone returns a copy of the resource
+	which children can be modified independently.
+*/
+func (res *staticResource) clone() resourceObject {
+	copied := *res
+	copied.staticChildren = make(map[string] string)
+	for name, identifier := range res.staticChildren {
+		copied.staticChildren[name] = identifier
+	}
+	copied.variableChildren = append([]string {}, res.variableChildren...)
+	return &copied
+}
+
 func (res *staticResource) Parent() string {
 	return res.parent
 }
@@ -95,15 +158,48 @@ func (res *staticResource) DefaultPermissions() DefaultResourcePermissions {
 	return res.defaultPermissions
 }
 
+func (res *staticResource) CustomPermissions() []string {
+	return res.customPermissions
+}
+
+func (res *staticResource) Policy() Policy {
+	return res.policy
+}
+
+func (res *staticResource) ForgetOnDelete() bool {
+	return res.forgetOnDelete
+}
+
+func (res *staticResource) CreationTemplate() *CreationTemplate {
+	return res.creationTemplate
+}
+
+func (res *staticResource) Schemas() map[Method] OperationSchema {
+	return res.schemas
+}
+
 type variableResource struct {
 	identifier string
 	name string
 	parent string
 	defaultPermissions DefaultResourcePermissions
-	handlers map[Method] Handler
+	customPermissions []string
+	policy Policy
+	forgetOnDelete bool
+	creationTemplate *CreationTemplate
+	schemas map[Method] OperationSchema
+	//resolved handlers of each API version
+	handlers []map[Method] Handler
 	staticChildren map[string] string
 	variableChildren [] string
+	//pattern as declared, or the pattern of the kind if none is declared
+	declaredPattern string
+	//declared pattern anchored to match whole segments
 	pattern regexp.Regexp
+	kind VariableKind
+	priority int
+	//consumes the rest of the path
+	wildcard bool
 }
 
 func (res *variableResource) Identifier() string {
@@ -114,12 +210,20 @@ func (res *variableResource) Name() string {
 	return res.name
 }
 
-func (res *variableResource) Handler(method Method) (Handler, error) {
-	if handler, exists := res.handlers[method]; exists {
-		return handler, nil
-	} else {
-		return handler, fmt.Errorf("No handler found for method %s", method)
-	}
+func (res *variableResource) Handler(version int, method Method) (Handler, error) {
+	return versionHandler(res.handlers, version, method)
+}
+
+/*
+	Methods returns the methods handled by the resource
+	in the given API version in ascending order.
+*/
+func (res *variableResource) Methods(version int) []Method {
+	return versionMethods(res.handlers, version)
+}
+
+func (res *variableResource) Pattern() string {
+	return res.declaredPattern
 }
 
 func (res *variableResource) HasStaticChild(name string) bool {
@@ -144,6 +248,42 @@ func (res *variableResource) DefineVariableChild(identifier string) {
 	res.variableChildren = append(res.variableChildren, identifier)
 }
 
+func (res *variableResource) RemoveStaticChild(name string) {
+	delete(res.staticChildren, name)
+}
+
+func (res *variableResource) RemoveVariableChild(identifier string) {
+	for index, value := range res.variableChildren {
+		if value == identifier {
+			res.variableChildren = append(
+				res.variableChildren[:index:index],
+				res.variableChildren[index + 1:]...
+			)
+			return
+		}
+	}
+}
+
+func (res *variableResource) SortVariableChildren(less func(string, string) bool) {
+	sort.SliceStable(res.variableChildren, func(i, j int) bool {
+		return less(res.variableChildren[i], res.variableChildren[j])
+	})
+}
+
+/*
+	clone returns a copy of the resource
+	which children can be modified independently.
+*/
+func (res *variableResource) clone() resourceObject {
+	copied := *res
+	copied.staticChildren = make(map[string] string)
+	for name, identifier := range res.staticChildren {
+		copied.staticChildren[name] = identifier
+	}
+	copied.variableChildren = append([]string {}, res.variableChildren...)
+	return &copied
+}
+
 func (res *variableResource) StaticChildIdentifier(name string) (string, error) {
 	result, exists := res.staticChildren[name]
 	if !exists {
@@ -164,7 +304,48 @@ func (res *variableResource) NumberOfVariableChildren() int {
 }
 
 func (res *variableResource) MatchPattern(str string) bool {
-	return res.pattern.MatchString(str)
+	_, err := res.Parse(str)
+	return err == nil
+}
+
+/*
+	Parse verifies the given value against the pattern
+	and kind of the resource and returns the converted value.
+*/
+func (res *variableResource) Parse(str string) (interface{}, error) {
+	if res.wildcard {
+		//verify each segment of the path
+		for _, segment := range strings.Split(str, "/") {
+			if segment == "" || !res.pattern.MatchString(segment) {
+				return nil, fmt.Errorf(
+					"Value '%s' doesn't match pattern of '%s'",
+					str,
+					res.identifier,
+				)
+			}
+		}
+		return str, nil
+	}
+	if !res.pattern.MatchString(str) {
+		return nil, fmt.Errorf(
+			"Value '%s' doesn't match pattern of '%s'",
+			str,
+			res.identifier,
+		)
+	}
+	return parseVariable(res.kind, str)
+}
+
+func (res *variableResource) Kind() VariableKind {
+	return res.kind
+}
+
+func (res *variableResource) Priority() int {
+	return res.priority
+}
+
+func (res *variableResource) Wildcard() bool {
+	return res.wildcard
 }
 
 func (res *variableResource) Parent() string {
@@ -174,3 +355,90 @@ func (res *variableResource) Parent() string {
 func (res *variableResource) DefaultPermissions() DefaultResourcePermissions {
 	return res.defaultPermissions
 }
+
+func (res *variableResource) CustomPermissions() []string {
+	return res.customPermissions
+}
+
+func (res *variableResource) Policy() Policy {
+	return res.policy
+}
+
+func (res *variableResource) ForgetOnDelete() bool {
+	return res.forgetOnDelete
+}
+
+func (res *variableResource) CreationTemplate() *CreationTemplate {
+	return res.creationTemplate
+}
+
+func (res *variableResource) Schemas() map[Method] OperationSchema {
+	return res.schemas
+}
+
+/*
+	newResourceObject constructs the resource object
+	of the given resource configuration without children
+	resolving its handlers for each of the given API versions.
+	An error will be returned in case the resource type is unknown
+	or the pattern of a variable resource can't be compiled.
+*/
+func newResourceObject(
+	identifier string,
+	resource Resource,
+	versions []string,
+) (
+	resourceObj resourceObject,
+	err error,
+) {
+	switch resource.Type {
+	case STATIC:
+		return &staticResource {
+			identifier: identifier,
+			name: resource.Name,
+			parent: resource.Parent,
+			handlers: resolveVersionHandlers(resource, versions),
+			defaultPermissions: resource.Permissions,
+			customPermissions: resource.CustomPermissions,
+			policy: resource.Policy,
+			forgetOnDelete: resource.ForgetOnDelete,
+			creationTemplate: resource.OnCreate,
+			schemas: resource.Schemas,
+			staticChildren: make(map[string] string),
+			variableChildren: make([]string, 0),
+		}, nil
+	case VARIABLE, WILDCARD:
+		declaredPattern := resource.Pattern
+		if kindPattern, exists := variableKindPatterns[resource.Kind]; exists && declaredPattern == "" {
+			declaredPattern = kindPattern.String()
+		}
+		regex, err := regexp.Compile(anchorPattern(declaredPattern))
+		if err != nil {
+			return nil, fmt.Errorf(
+				"Pattern regex ('%s') compilation for resource ('%s') failed",
+				resource.Pattern,
+				identifier,
+			)
+		}
+		return &variableResource {
+			identifier: identifier,
+			name: resource.Name,
+			parent: resource.Parent,
+			handlers: resolveVersionHandlers(resource, versions),
+			defaultPermissions: resource.Permissions,
+			customPermissions: resource.CustomPermissions,
+			policy: resource.Policy,
+			forgetOnDelete: resource.ForgetOnDelete,
+			creationTemplate: resource.OnCreate,
+			schemas: resource.Schemas,
+			staticChildren: make(map[string] string),
+			variableChildren: make([]string, 0),
+			declaredPattern: declaredPattern,
+			pattern: *regex,
+			kind: resource.Kind,
+			priority: resource.Priority,
+			wildcard: resource.Type == WILDCARD,
+		}, nil
+	}
+	return nil, fmt.Errorf("Wrong resource type (%d)", resource.Type)
+}
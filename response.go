@@ -29,6 +29,7 @@ type ResponseJson struct {
 	errorCode string
 	errorMessage string
 	status int
+	createdResource *ResourceIdentifier
 }
 
 func (response *ResponseJson) String() *[]byte {
@@ -69,9 +70,19 @@ func (response *ResponseJson) Status() int {
 }
 
 func (response *ResponseJson) Header(head string, value string) {
+	if response.headers == nil {
+		response.headers = make(map[string] string)
+	}
 	response.headers[head] = value
 }
 
+/*
+	Headers returns the headers set on the response.
+*/
+func (response *ResponseJson) Headers() map[string] string {
+	return response.headers
+}
+
 func (response *ResponseJson) Data(key string, value interface{}) {
 	if response.data == nil {
 		response.data = make(map[string] interface {})
@@ -113,6 +124,15 @@ func (response *ResponseJson) ReplyCreated() {
 	response.status = http.StatusCreated
 }
 
+func (response *ResponseJson) ReplyCreatedResource(resourceId ResourceIdentifier) {
+	response.status = http.StatusCreated
+	response.createdResource = &resourceId
+}
+
+func (response *ResponseJson) CreatedResource() *ResourceIdentifier {
+	return response.createdResource
+}
+
 func (response *ResponseJson) ReplyCustom(status int) {
 	response.status = status
 }
@@ -0,0 +1,345 @@
+package apperix
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+	"regexp/syntax"
+)
+
+/*
+	maxOverlapStates limits the states explored
+	while searching for values matched by multiple patterns.
+*/
+const maxOverlapStates = 10000
+
+/*
+	anchorPattern anchors the given pattern at both ends
+	so it has to match whole segments.
+	Empty patterns are left empty and match any segment.
+*/
+func anchorPattern(pattern string) string {
+	if pattern == "" {
+		return pattern
+	}
+	return ConcatStrings("^(?:", pattern, ")$")
+}
+
+/*
+	variablePatterns returns all patterns a value of a variable
+	resource with the given pattern and kind has to match.
+*/
+func variablePatterns(pattern string, kind VariableKind) []string {
+	patterns := make([]string, 0, 2)
+	if pattern != "" {
+		patterns = append(patterns, anchorPattern(pattern))
+	}
+	if kindPattern, exists := variableKindPatterns[kind]; exists {
+		patterns = append(patterns, kindPattern.String())
+	}
+	if len(patterns) < 1 {
+		patterns = append(patterns, "^.+$")
+	}
+	return patterns
+}
+
+/*
+	kindSpecificity ranks variable kinds by the number of values they accept,
+	more specific kinds are matched first.
+*/
+func kindSpecificity(kind VariableKind) int {
+	switch kind {
+	case INT64, UUID, DATE:
+		return 2
+	case SLUG:
+		return 1
+	}
+	return 0
+}
+
+/*
+	matchedBefore returns true if the first of the given sibling
+	variable resources is matched before the second one.
+	Variable resources are ordered by priority, higher first,
+	then by the specificity of their kind and finally by identifier.
+	Wildcard resources are matched after all variable resources.
+*/
+func matchedBefore(first *variableResource, second *variableResource) bool {
+	if first.wildcard != second.wildcard {
+		return second.wildcard
+	}
+	if first.priority != second.priority {
+		return first.priority > second.priority
+	}
+	if kindSpecificity(first.kind) != kindSpecificity(second.kind) {
+		return kindSpecificity(first.kind) > kindSpecificity(second.kind)
+	}
+	return first.identifier < second.identifier
+}
+
+/*
+	orderVariableChildren sorts the variable children
+	of the given resource in matching order.
+*/
+func orderVariableChildren(
+	resourceObj resourceObject,
+	resources map[string] resourceObject,
+) {
+	resourceObj.SortVariableChildren(func(first string, second string) bool {
+		firstObj, _ := resources[first].(*variableResource)
+		secondObj, _ := resources[second].(*variableResource)
+		if firstObj == nil || secondObj == nil {
+			return first < second
+		}
+		return matchedBefore(firstObj, secondObj)
+	})
+}
+
+/*
+	overlapState is a set of program counters per program.
+*/
+type overlapState [][]uint32
+
+func (state overlapState) key() string {
+	parts := make([]string, len(state))
+	for index, pcs := range state {
+		parts[index] = fmt.Sprint(pcs)
+	}
+	return strings.Join(parts, "|")
+}
+
+/*
+	uniqueCounters sorts the given program counters and removes duplicates.
+*/
+func uniqueCounters(pcs []uint32) []uint32 {
+	sort.Slice(pcs, func(i, j int) bool {
+		return pcs[i] < pcs[j]
+	})
+	unique := pcs[:0]
+	for index, pc := range pcs {
+		if index == 0 || pc != pcs[index - 1] {
+			unique = append(unique, pc)
+		}
+	}
+	return unique
+}
+
+/*
+	closure returns the instructions consuming runes reachable
+	from the given program counters and whether the program matches.
+	Empty width assertions other than text and line boundaries
+	are assumed to be satisfied.
+*/
+func closure(
+	prog *syntax.Prog,
+	pcs []uint32,
+	atStart bool,
+	atEnd bool,
+) (
+	consuming []uint32,
+	matches bool,
+) {
+	visited := make(map[uint32] bool)
+	stack := append([]uint32 {}, pcs...)
+	for len(stack) > 0 {
+		pc := stack[len(stack) - 1]
+		stack = stack[:len(stack) - 1]
+		if visited[pc] {
+			continue
+		}
+		visited[pc] = true
+		inst := &prog.Inst[pc]
+		switch inst.Op {
+		case syntax.InstAlt, syntax.InstAltMatch:
+			stack = append(stack, inst.Out, inst.Arg)
+		case syntax.InstNop, syntax.InstCapture:
+			stack = append(stack, inst.Out)
+		case syntax.InstEmptyWidth:
+			op := syntax.EmptyOp(inst.Arg)
+			if op & (syntax.EmptyBeginText | syntax.EmptyBeginLine) != 0 && !atStart {
+				continue
+			}
+			if op & (syntax.EmptyEndText | syntax.EmptyEndLine) != 0 && !atEnd {
+				continue
+			}
+			stack = append(stack, inst.Out)
+		case syntax.InstMatch:
+			matches = true
+		case syntax.InstFail:
+		default:
+			consuming = append(consuming, pc)
+		}
+	}
+	return uniqueCounters(consuming), matches
+}
+
+/*
+	runeBoundaries adds the boundaries of the rune ranges
+	matched by the given instruction to the given set.
+*/
+func runeBoundaries(inst *syntax.Inst, boundaries map[rune] bool) {
+	add := func(lo rune, hi rune) {
+		boundaries[lo] = true
+		boundaries[hi + 1] = true
+		if syntax.Flags(inst.Arg) & syntax.FoldCase != 0 {
+			for folded := unicode.SimpleFold(lo); folded != lo; folded = unicode.SimpleFold(folded) {
+				boundaries[folded] = true
+				boundaries[folded + 1] = true
+			}
+			for folded := unicode.SimpleFold(hi); folded != hi; folded = unicode.SimpleFold(folded) {
+				boundaries[folded] = true
+				boundaries[folded + 1] = true
+			}
+		}
+	}
+	switch inst.Op {
+	case syntax.InstRune:
+		for index := 0; index + 1 < len(inst.Rune); index += 2 {
+			add(inst.Rune[index], inst.Rune[index + 1])
+		}
+		if len(inst.Rune) == 1 {
+			add(inst.Rune[0], inst.Rune[0])
+		}
+	case syntax.InstRune1:
+		add(inst.Rune[0], inst.Rune[0])
+	case syntax.InstRuneAnyNotNL:
+		add('\n', '\n')
+	}
+}
+
+/*
+	patternsOverlap searches a path segment matched by all given patterns.
+	Patterns which can't be parsed or are too complex to analyze
+	are reported as not overlapping.
+*/
+func patternsOverlap(patterns []string) (
+	witness string,
+	overlap bool,
+) {
+	progs := make([]*syntax.Prog, 0, len(patterns))
+	initial := make(overlapState, 0, len(patterns))
+	for _, pattern := range patterns {
+		parsed, err := syntax.Parse(pattern, syntax.Perl)
+		if err != nil {
+			return "", false
+		}
+		prog, err := syntax.Compile(parsed.Simplify())
+		if err != nil {
+			return "", false
+		}
+		progs = append(progs, prog)
+		initial = append(initial, []uint32 {uint32(prog.Start)})
+	}
+
+	type candidate struct {
+		state overlapState
+		value string
+	}
+	queue := []candidate {{initial, ""}}
+	visited := map[string] bool {initial.key(): true}
+	for len(queue) > 0 && len(visited) < maxOverlapStates {
+		current := queue[0]
+		queue = queue[1:]
+		atStart := current.value == ""
+
+		//verify whether all programs match the value
+		if !atStart {
+			allMatch := true
+			for index, prog := range progs {
+				if _, matches := closure(prog, current.state[index], false, true); !matches {
+					allMatch = false
+					break
+				}
+			}
+			if allMatch {
+				return current.value, true
+			}
+		}
+
+		//collect representative runes, readable ones are tried first
+		consuming := make(overlapState, len(progs))
+		boundaries := map[rune] bool {0: true, '/': true, '/' + 1: true, 'a': true}
+		for index, prog := range progs {
+			consuming[index], _ = closure(prog, current.state[index], atStart, false)
+			if len(consuming[index]) < 1 {
+				consuming = nil
+				break
+			}
+			for _, pc := range consuming[index] {
+				runeBoundaries(&prog.Inst[pc], boundaries)
+			}
+		}
+		if consuming == nil {
+			continue
+		}
+		runes := make([]rune, 0, len(boundaries))
+		for boundary := range boundaries {
+			runes = append(runes, boundary)
+		}
+		sort.Slice(runes, func(i, j int) bool {
+			if unicode.IsPrint(runes[i]) != unicode.IsPrint(runes[j]) {
+				return unicode.IsPrint(runes[i])
+			}
+			return runes[i] < runes[j]
+		})
+		for _, boundary := range runes {
+			if boundary == '/' || boundary < 0 || boundary > unicode.MaxRune {
+				//segments never contain slashes
+				continue
+			}
+			next := make(overlapState, len(progs))
+			for index, prog := range progs {
+				for _, pc := range consuming[index] {
+					inst := &prog.Inst[pc]
+					if inst.MatchRune(boundary) {
+						next[index] = append(next[index], inst.Out)
+					}
+				}
+				if len(next[index]) < 1 {
+					next = nil
+					break
+				}
+				next[index] = uniqueCounters(next[index])
+			}
+			if next == nil || visited[next.key()] {
+				continue
+			}
+			visited[next.key()] = true
+			queue = append(queue, candidate {next, ConcatStrings(current.value, string(boundary))})
+		}
+	}
+	return "", false
+}
+
+/*
+	variableConflict returns an error in case the given sibling
+	variable resources match common values and neither their
+	priorities nor their kinds define which one is matched first.
+*/
+func variableConflict(
+	firstId string,
+	first Resource,
+	secondId string,
+	second Resource,
+) error {
+	if (first.Type == WILDCARD) != (second.Type == WILDCARD) ||
+		first.Priority != second.Priority ||
+		kindSpecificity(first.Kind) != kindSpecificity(second.Kind) {
+		return nil
+	}
+	patterns := append(
+		variablePatterns(first.Pattern, first.Kind),
+		variablePatterns(second.Pattern, second.Kind)...
+	)
+	witness, overlap := patternsOverlap(patterns)
+	if !overlap {
+		return nil
+	}
+	return fmt.Errorf(
+		"Patterns of variable resources '%s' and '%s' overlap (both match '%s'), assign different priorities",
+		firstId,
+		secondId,
+		witness,
+	)
+}
@@ -0,0 +1,186 @@
+package apperix
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+	"net/http"
+)
+
+func TestAnchorPattern(t *testing.T) {
+	for _, test := range []struct {
+		pattern string
+		matching []string
+		mismatching []string
+	} {
+		{"[a-z]+", []string {"abc"}, []string {"abc1", "1abc"}},
+		{"^foo|bar$", []string {"foo", "bar"}, []string {"foox", "xbar"}},
+		{`^a\$`, []string {"a$"}, []string {"a$b", "a"}},
+		{"", []string {"anything"}, nil},
+	} {
+		regex := regexp.MustCompile(anchorPattern(test.pattern))
+		for _, value := range test.matching {
+			if !regex.MatchString(value) {
+				t.Errorf("'%s': expected '%s' to match", test.pattern, value)
+			}
+		}
+		for _, value := range test.mismatching {
+			if regex.MatchString(value) {
+				t.Errorf("'%s': expected '%s' not to match", test.pattern, value)
+			}
+		}
+	}
+}
+
+func TestVariablePriorities(t *testing.T) {
+	identified := func(client *Client, request *Request, service *Service) Response {
+		response := &ResponseJson {}
+		resourceId := request.ResourceIdentifier()
+		response.Data("identifier", resourceId.Identifier())
+		return response
+	}
+	variable := func(name string, pattern string, kind VariableKind, priority int) Resource {
+		resource := guestReadable(Resource {
+			Type: VARIABLE,
+			Parent: "items",
+			Name: name,
+			Pattern: pattern,
+			Kind: kind,
+			Priority: priority,
+		})
+		resource.Handlers[READ] = identified
+		return resource
+	}
+	service := newTestService(t, ServiceConfig {
+		Resources: map[string] Resource {
+			"items": Resource {
+				Type: STATIC,
+				Name: "items",
+			},
+			"latest": variable("latest", "^latest|newest$", ANY, 2),
+			"word": variable("word", "[a-z]+", ANY, 1),
+			"number": variable("number", "", INT64, 0),
+			"code": variable("code", "[0-9a-z]+", ANY, 0),
+		},
+	})
+	for _, test := range []struct {
+		target string
+		identifier string
+	} {
+		{"/items/latest", "latest"},
+		{"/items/newest", "latest"},
+		{"/items/latestx", "word"},
+		{"/items/42", "number"},
+		{"/items/42x", "code"},
+	} {
+		recorder := testRequest(service, "GET", test.target, "")
+		expectStatus(t, recorder, http.StatusOK)
+		if identifier := responseData(t, recorder)["identifier"]; identifier != test.identifier {
+			t.Errorf("%s: expected '%s', got %v", test.target, test.identifier, identifier)
+		}
+	}
+	expectStatus(t, testRequest(service, "GET", "/items/-", ""), http.StatusNotFound)
+	routes := service.Routes()
+	if routes[3].Identifier != "latest" || routes[3].Pattern != "^latest|newest$" {
+		t.Errorf("Expected declared pattern of the first variable, got %+v", routes[3])
+	}
+
+	err := service.RegisterResource("letters", Resource {
+		Type: VARIABLE,
+		Parent: "items",
+		Name: "letters",
+		Pattern: "[a-c]+",
+		Priority: 1,
+	})
+	if err == nil || !strings.Contains(err.Error(), "overlap") {
+		t.Errorf("Expected overlapping sibling to be rejected, got %v", err)
+	}
+	err = service.RegisterResource("letters", Resource {
+		Type: VARIABLE,
+		Parent: "items",
+		Name: "letters",
+		Pattern: "[A-Z]+",
+		Priority: 1,
+	})
+	if err != nil {
+		t.Errorf("Expected disjoint sibling to be registered, got %v", err)
+	}
+}
+
+func TestVariableConflicts(t *testing.T) {
+	for _, test := range []struct {
+		description string
+		first Resource
+		second Resource
+		conflict bool
+	} {
+		{
+			"overlapping patterns",
+			Resource {Type: VARIABLE, Pattern: "[a-z]+"},
+			Resource {Type: VARIABLE, Pattern: "[0-9a-f]+"},
+			true,
+		},
+		{
+			"alternatives overlapping only when anchored as a whole",
+			Resource {Type: VARIABLE, Pattern: "^ab|cd$"},
+			Resource {Type: VARIABLE, Pattern: "^abx$"},
+			false,
+		},
+		{
+			"disjoint patterns",
+			Resource {Type: VARIABLE, Pattern: "[a-z]+"},
+			Resource {Type: VARIABLE, Pattern: "[0-9]+"},
+			false,
+		},
+		{
+			"different priorities",
+			Resource {Type: VARIABLE, Pattern: "[a-z]+", Priority: 1},
+			Resource {Type: VARIABLE, Pattern: "[a-z]+"},
+			false,
+		},
+		{
+			"different kind specificity",
+			Resource {Type: VARIABLE, Kind: INT64},
+			Resource {Type: VARIABLE},
+			false,
+		},
+		{
+			"equally specific kinds",
+			Resource {Type: VARIABLE, Kind: INT64},
+			Resource {Type: VARIABLE, Pattern: "[0-9]+", Kind: DATE},
+			false,
+		},
+		{
+			"wildcard and variable",
+			Resource {Type: WILDCARD},
+			Resource {Type: VARIABLE},
+			false,
+		},
+	} {
+		err := variableConflict("first", test.first, "second", test.second)
+		if (err != nil) != test.conflict {
+			t.Errorf("%s: expected conflict %t, got %v", test.description, test.conflict, err)
+		}
+	}
+
+	problems := ValidateConfig(ServiceConfig {
+		Authentication: AuthenticationConfig {
+			Path: "auth",
+		},
+		Resources: map[string] Resource {
+			"first": Resource {
+				Type: VARIABLE,
+				Name: "first",
+				Pattern: "[a-z]+",
+			},
+			"second": Resource {
+				Type: VARIABLE,
+				Name: "second",
+				Pattern: "[a-f0-9]+",
+			},
+		},
+	})
+	if len(problems) != 1 || !strings.Contains(problems[0].Error(), "both match 'a'") {
+		t.Errorf("Expected overlap reported with witness 'a', got %v", problems)
+	}
+}
@@ -0,0 +1,360 @@
+package apperix
+
+import (
+	"fmt"
+	"sort"
+	"regexp"
+	"strings"
+)
+
+/*
+	resourceTree bundles a version of the resource tree
+	with the router compiled from it.
+*/
+type resourceTree struct {
+	resources map[string] resourceObject
+	router *router
+}
+
+/*
+	routerNode is a node of the compiled router.
+	Static children are looked up by name.
+	Variable children which patterns start with a literal prefix
+	are indexed by their prefix, the patterns of the remaining
+	variable children are combined into matchers of up to
+	matcherSize children with one capture group per child.
+*/
+type routerNode struct {
+	resource resourceObject
+	static map[string] *routerNode
+	//static children by lower case name
+	folded map[string] *routerNode
+	//variable children in matching order
+	variables []*routerNode
+	//indices of variable children by literal prefix of their patterns
+	prefixed map[string] []int
+	longestPrefix int
+	//indices of variable children without literal prefix
+	unprefixed []int
+	matchers []combinedMatcher
+	wildcards []*routerNode
+}
+
+/*
+	matcherSize is the maximum number of patterns per combined matcher,
+	small matchers are evaluated considerably faster than large ones.
+*/
+const matcherSize = 16
+
+/*
+	combinedMatcher matches the patterns of consecutive unprefixed children.
+*/
+type combinedMatcher struct {
+	regex *regexp.Regexp
+	//position of the first child in the unprefixed children
+	offset int
+	//index of the capture group of each child
+	groups []int
+}
+
+/*
+	newCombinedMatcher combines the given patterns
+	of the unprefixed children starting at the given offset.
+*/
+func newCombinedMatcher(
+	patterns []*regexp.Regexp,
+	offset int,
+) (
+	matcher combinedMatcher,
+	err error,
+) {
+	matcher.offset = offset
+	alternatives := make([]string, 0, len(patterns))
+	group := 1
+	for _, pattern := range patterns {
+		alternative := pattern.String()
+		if alternative == "" {
+			alternative = "(?s:.*)"
+		}
+		alternatives = append(alternatives, ConcatStrings("(", alternative, ")"))
+		matcher.groups = append(matcher.groups, group)
+		group += 1 + pattern.NumSubexp()
+	}
+	matcher.regex, err = regexp.Compile(ConcatStrings(
+		"^(?:",
+		strings.Join(alternatives, "|"),
+		")$",
+	))
+	return matcher, err
+}
+
+/*
+	first returns the position of the first child matching the given segment
+	in the unprefixed children.
+*/
+func (matcher *combinedMatcher) first(segment string) (int, bool) {
+	submatches := matcher.regex.FindStringSubmatchIndex(segment)
+	if submatches == nil {
+		return 0, false
+	}
+	for index, group := range matcher.groups {
+		if submatches[2 * group] >= 0 {
+			return matcher.offset + index, true
+		}
+	}
+	return 0, false
+}
+
+/*
+	router identifies target resources of request paths
+	using a tree compiled once per resource tree version.
+*/
+type router struct {
+	root *routerNode
+}
+
+/*
+	newRouter compiles a router from the given resource tree.
+	An error will be returned in case a combined matcher can't be compiled.
+*/
+func newRouter(resources map[string] resourceObject) (
+	compiled *router,
+	err error,
+) {
+	rootObj, exists := resources["root"]
+	if !exists {
+		return &router {}, nil
+	}
+	staticChildren := make(map[string] []resourceObject)
+	for identifier, child := range resources {
+		if _, isStatic := child.(*staticResource); !isStatic || identifier == child.Parent() {
+			continue
+		}
+		staticChildren[child.Parent()] = append(staticChildren[child.Parent()], child)
+	}
+	root, err := newRouterNode(rootObj, resources, staticChildren)
+	if err != nil {
+		return nil, err
+	}
+	return &router {
+		root: root,
+	}, nil
+}
+
+/*
+	newRouterNode compiles the router node of the given resource
+	and its descendants.
+*/
+func newRouterNode(
+	resourceObj resourceObject,
+	resources map[string] resourceObject,
+	staticChildren map[string] []resourceObject,
+) (
+	node *routerNode,
+	err error,
+) {
+	node = &routerNode {
+		resource: resourceObj,
+		static: make(map[string] *routerNode),
+		folded: make(map[string] *routerNode),
+		prefixed: make(map[string] []int),
+	}
+	for _, child := range staticChildren[resourceObj.Identifier()] {
+		childId, err := resourceObj.StaticChildIdentifier(child.Name())
+		if err != nil || childId != child.Identifier() {
+			continue
+		}
+		node.static[child.Name()], err = newRouterNode(child, resources, staticChildren)
+		if err != nil {
+			return nil, err
+		}
+		node.folded[strings.ToLower(child.Name())] = node.static[child.Name()]
+	}
+
+	//combine patterns of variable children in matching order
+	patterns := make([]*regexp.Regexp, 0)
+	for index := 0; index < resourceObj.NumberOfVariableChildren(); index++ {
+		childId, err := resourceObj.VariableChildIdentifier(index)
+		if err != nil {
+			continue
+		}
+		child, exists := resources[childId].(*variableResource)
+		if !exists {
+			continue
+		}
+		childNode, err := newRouterNode(child, resources, staticChildren)
+		if err != nil {
+			return nil, err
+		}
+		if child.Wildcard() {
+			node.wildcards = append(node.wildcards, childNode)
+			continue
+		}
+		node.variables = append(node.variables, childNode)
+		position := len(node.variables) - 1
+		if prefix, _ := child.pattern.LiteralPrefix(); prefix != "" {
+			node.prefixed[prefix] = append(node.prefixed[prefix], position)
+			if len(prefix) > node.longestPrefix {
+				node.longestPrefix = len(prefix)
+			}
+			continue
+		}
+		patterns = append(patterns, &child.pattern)
+		node.unprefixed = append(node.unprefixed, position)
+	}
+	for offset := 0; offset < len(patterns); offset += matcherSize {
+		end := offset + matcherSize
+		if end > len(patterns) {
+			end = len(patterns)
+		}
+		matcher, err := newCombinedMatcher(patterns[offset:end], offset)
+		if err != nil {
+			return nil, fmt.Errorf(
+				"Could not compile matcher of '%s': %s",
+				resourceObj.Identifier(),
+				err,
+			)
+		}
+		node.matchers = append(node.matchers, matcher)
+	}
+	return node, nil
+}
+
+/*
+	matchVariable returns the first variable child matching the given segment.
+	Candidates are the children which literal prefix the segment starts with
+	and the unprefixed children starting with the first one
+	the combined matchers select, they are verified in matching order.
+*/
+func (node *routerNode) matchVariable(segment string) *routerNode {
+	candidates := make([]int, 0)
+	for length := 1; length <= node.longestPrefix && length <= len(segment); length++ {
+		candidates = append(candidates, node.prefixed[segment[:length]]...)
+	}
+	for index := range node.matchers {
+		if position, matched := node.matchers[index].first(segment); matched {
+			//children rejecting the value by kind are skipped later
+			candidates = append(candidates, node.unprefixed[position:]...)
+			break
+		}
+	}
+	sort.Ints(candidates)
+	for _, position := range candidates {
+		child := node.variables[position]
+		if child.resource.(*variableResource).MatchPattern(segment) {
+			return child
+		}
+	}
+	return nil
+}
+
+/*
+	route identifies the target resource of the given decoded path segments
+	and collects the segments of its canonical path.
+	Static names are matched ignoring case if foldCase is set.
+	Matching is equivalent to identifyTargetResource otherwise.
+*/
+func (compiled *router) route(
+	path []string,
+	aclPath string,
+	foldCase bool,
+) (targetResource, error) {
+	target := targetResource {
+		variables: make(map[string] string),
+		canonical: make([]string, 0, len(path)),
+	}
+	if compiled.root == nil {
+		return target, fmt.Errorf("Resource tree not initialized")
+	}
+	node := compiled.root
+	for index := 0; index < len(path); index++ {
+		segment := path[index]
+		parentId := node.resource.Identifier()
+		child, exists := node.static[segment]
+		if !exists && foldCase {
+			child, exists = node.folded[strings.ToLower(segment)]
+		}
+		if exists {
+			//static resource identified
+			node = child
+			target.canonical = append(target.canonical, child.resource.Name())
+			continue
+		}
+		if aclPath != "" && segment == aclPath && index == len(path) - 1 {
+			//access control list of the current resource
+			target.acl = true
+			target.canonical = append(target.canonical, segment)
+			break
+		}
+		if child := node.matchVariable(segment); child != nil {
+			node = child
+			target.variables[child.resource.Identifier()] = segment
+			target.canonical = append(target.canonical, segment)
+			continue
+		}
+
+		//wildcards consume the rest of the path except a trailing ACL segment
+		rest := path[index:]
+		acl := false
+		if aclPath != "" && len(rest) > 1 && rest[len(rest) - 1] == aclPath {
+			rest = rest[:len(rest) - 1]
+			acl = true
+		}
+		value := strings.Join(rest, "/")
+		var matched *routerNode
+		for _, child := range node.wildcards {
+			if child.resource.(*variableResource).MatchPattern(value) {
+				matched = child
+				break
+			}
+		}
+		if matched == nil {
+			//resource not found
+			if parentId == "root" {
+				return target, fmt.Errorf("Resource '%s' not found in root", segment)
+			}
+			return target, fmt.Errorf("Resource '%s' not found in '%s'", segment, parentId)
+		}
+		node = matched
+		target.variables[matched.resource.Identifier()] = value
+		target.acl = acl
+		target.canonical = append(target.canonical, path[index:]...)
+		break
+	}
+	target.identifier = node.resource.Identifier()
+	return target, nil
+}
+
+/*
+	newResourceTree compiles the router of the given resource tree.
+	An error will be returned in case the router can't be compiled.
+*/
+func newResourceTree(resources map[string] resourceObject) (
+	tree *resourceTree,
+	err error,
+) {
+	compiled, err := newRouter(resources)
+	if err != nil {
+		return nil, err
+	}
+	return &resourceTree {
+		resources: resources,
+		router: compiled,
+	}, nil
+}
+
+/*
+	storeTree replaces the current resource tree
+	with the given one compiling its router,
+	the caller must hold the tree lock.
+	An error will be returned and the current tree will be left untouched
+	in case the router can't be compiled.
+*/
+func (service *Service) storeTree(resources map[string] resourceObject) error {
+	tree, err := newResourceTree(resources)
+	if err != nil {
+		return err
+	}
+	service.tree.Store(tree)
+	return nil
+}
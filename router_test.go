@@ -0,0 +1,385 @@
+package apperix
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"net/http"
+)
+
+/*
+	routedService returns a service without database
+	which resource tree is built from the given configuration.
+*/
+func routedService(tb testing.TB, conf ServiceConfig, aclPath string) *Service {
+	resources, err := buildResourceTree(conf)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	service := &Service {}
+	service.Config.reloadable.Store(&reloadableConfiguration {
+		aclPath: aclPath,
+	})
+	err = service.storeTree(resources)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return service
+}
+
+/*
+	benchmarkService returns a service without database
+	which resource tree contains the given number of
+	variable siblings below "/items", each with a child "details".
+	The patterns of the siblings are formatted with their index.
+*/
+func benchmarkService(b *testing.B, siblings int, pattern string) *Service {
+	conf := ServiceConfig {
+		Name: "benchmark",
+		Authentication: AuthenticationConfig {
+			Path: "auth",
+		},
+		Resources: map[string] Resource {
+			"items": Resource {
+				Type: STATIC,
+				Name: "items",
+			},
+		},
+	}
+	for index := 0; index < siblings; index++ {
+		identifier := fmt.Sprintf("item%d", index)
+		conf.Resources[identifier] = Resource {
+			Type: VARIABLE,
+			Parent: "items",
+			Pattern: fmt.Sprintf(pattern, index),
+		}
+		conf.Resources[ConcatStrings(identifier, "-details")] = Resource {
+			Type: STATIC,
+			Parent: identifier,
+			Name: "details",
+		}
+	}
+	return routedService(b, conf, "")
+}
+
+/*
+	TestRouterMatchesIdentifyTargetResource verifies the compiled router
+	identifies the same target resources as walking the resource tree.
+*/
+func TestRouterMatchesIdentifyTargetResource(t *testing.T) {
+	conf := ServiceConfig {
+		Name: "router",
+		Authentication: AuthenticationConfig {
+			Path: "auth",
+		},
+		Resources: map[string] Resource {
+			"items": Resource {
+				Type: STATIC,
+				Name: "items",
+			},
+			//bucketed by literal prefix
+			"alpha": Resource {
+				Type: VARIABLE,
+				Parent: "items",
+				Pattern: "a-[0-9]+",
+			},
+			"alpha-details": Resource {
+				Type: STATIC,
+				Parent: "alpha",
+				Name: "details",
+			},
+			"beta": Resource {
+				Type: VARIABLE,
+				Parent: "items",
+				Pattern: "b-[0-9]+",
+			},
+			//combined into a single matcher
+			"number": Resource {
+				Type: VARIABLE,
+				Parent: "items",
+				Pattern: "[0-9a-f]+",
+				Kind: INT64,
+				Priority: 2,
+			},
+			"hex": Resource {
+				Type: VARIABLE,
+				Parent: "items",
+				Pattern: "[0-9a-f]+",
+				Priority: 1,
+			},
+			"code": Resource {
+				Type: VARIABLE,
+				Parent: "items",
+				Pattern: "[0-9]+-x",
+			},
+			"files": Resource {
+				Type: STATIC,
+				Name: "files",
+			},
+			"file": Resource {
+				Type: WILDCARD,
+				Parent: "files",
+				Pattern: "[a-z.]+",
+			},
+		},
+	}
+	service := routedService(t, conf, "acl")
+	tree := service.tree.Load().(*resourceTree)
+
+	for _, test := range []struct {
+		urlPath string
+		//empty if not found
+		identifier string
+		acl bool
+	} {
+		{"/", "root", false},
+		{"/items", "items", false},
+		{"/items/acl", "items", true},
+		//prefix-bucketed
+		{"/items/a-1", "alpha", false},
+		{"/items/a-1/details", "alpha-details", false},
+		{"/items/a-1/acl", "alpha", true},
+		{"/items/b-2", "beta", false},
+		{"/items/b-2/details", "", false},
+		//combined matcher
+		{"/items/42", "number", false},
+		{"/items/7-x", "code", false},
+		{"/items/7-y", "", false},
+		//rejected by kind
+		{"/items/ff", "hex", false},
+		{"/items/a1", "hex", false},
+		//wildcard
+		{"/files/readme.md", "file", false},
+		{"/files/docs/readme.md", "file", false},
+		{"/files/docs/readme.md/acl", "file", true},
+		{"/files/acl", "files", true},
+		{"/files/docs/README", "", false},
+		{"/unknown", "", false},
+	} {
+		expected, expectedErr := identifyTargetResource(test.urlPath, service, tree.resources)
+		path, err := pathSegments(test.urlPath)
+		if err != nil {
+			t.Fatalf("%s: %s", test.urlPath, err)
+		}
+		actual, actualErr := tree.router.route(path, "acl", false)
+		if test.identifier == "" {
+			if expectedErr == nil || actualErr == nil {
+				t.Errorf(
+					"%s: expected not found, got '%s' and '%s'",
+					test.urlPath,
+					expected.identifier,
+					actual.identifier,
+				)
+			}
+			continue
+		}
+		if expectedErr != nil || actualErr != nil {
+			t.Errorf("%s: unexpected errors: %v, %v", test.urlPath, expectedErr, actualErr)
+			continue
+		}
+		for _, target := range []targetResource {expected, actual} {
+			if target.identifier != test.identifier || target.acl != test.acl {
+				t.Errorf(
+					"%s: expected '%s' (acl %t), got '%s' (acl %t)",
+					test.urlPath,
+					test.identifier,
+					test.acl,
+					target.identifier,
+					target.acl,
+				)
+			}
+		}
+		if fmt.Sprint(actual.variables) != fmt.Sprint(expected.variables) {
+			t.Errorf(
+				"%s: expected variables %v, got %v",
+				test.urlPath,
+				expected.variables,
+				actual.variables,
+			)
+		}
+	}
+}
+
+func benchmarkIdentifyTargetResource(b *testing.B, siblings int, pattern string, urlPath string) {
+	service := benchmarkService(b, siblings, pattern)
+	resources := service.resources()
+	b.ResetTimer()
+	for index := 0; index < b.N; index++ {
+		_, err := identifyTargetResource(urlPath, service, resources)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func benchmarkRouter(b *testing.B, siblings int, pattern string, urlPath string) {
+	service := benchmarkService(b, siblings, pattern)
+	compiled := service.tree.Load().(*resourceTree).router
+	b.ResetTimer()
+	for index := 0; index < b.N; index++ {
+		path, err := pathSegments(urlPath)
+		if err != nil {
+			b.Fatal(err)
+		}
+		_, err = compiled.route(path, "", false)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkIdentifyTargetResourcePrefixed10(b *testing.B) {
+	benchmarkIdentifyTargetResource(b, 10, "v%d-[0-9]+", "/items/v9-42/details")
+}
+
+func BenchmarkIdentifyTargetResourcePrefixed100(b *testing.B) {
+	benchmarkIdentifyTargetResource(b, 100, "v%d-[0-9]+", "/items/v99-42/details")
+}
+
+func BenchmarkIdentifyTargetResourcePrefixed500(b *testing.B) {
+	benchmarkIdentifyTargetResource(b, 500, "v%d-[0-9]+", "/items/v499-42/details")
+}
+
+func BenchmarkIdentifyTargetResourceUnprefixed10(b *testing.B) {
+	benchmarkIdentifyTargetResource(b, 10, "[0-9]+-v%d", "/items/42-v9/details")
+}
+
+func BenchmarkIdentifyTargetResourceUnprefixed100(b *testing.B) {
+	benchmarkIdentifyTargetResource(b, 100, "[0-9]+-v%d", "/items/42-v99/details")
+}
+
+func BenchmarkIdentifyTargetResourceUnprefixed500(b *testing.B) {
+	benchmarkIdentifyTargetResource(b, 500, "[0-9]+-v%d", "/items/42-v499/details")
+}
+
+func BenchmarkRouterPrefixed10(b *testing.B) {
+	benchmarkRouter(b, 10, "v%d-[0-9]+", "/items/v9-42/details")
+}
+
+func BenchmarkRouterPrefixed100(b *testing.B) {
+	benchmarkRouter(b, 100, "v%d-[0-9]+", "/items/v99-42/details")
+}
+
+func BenchmarkRouterPrefixed500(b *testing.B) {
+	benchmarkRouter(b, 500, "v%d-[0-9]+", "/items/v499-42/details")
+}
+
+func BenchmarkRouterUnprefixed10(b *testing.B) {
+	benchmarkRouter(b, 10, "[0-9]+-v%d", "/items/42-v9/details")
+}
+
+func BenchmarkRouterUnprefixed100(b *testing.B) {
+	benchmarkRouter(b, 100, "[0-9]+-v%d", "/items/42-v99/details")
+}
+
+func BenchmarkRouterUnprefixed500(b *testing.B) {
+	benchmarkRouter(b, 500, "[0-9]+-v%d", "/items/42-v499/details")
+}
+
+func TestWildcardResources(t *testing.T) {
+	variableHandler := func(client *Client, request *Request, service *Service) Response {
+		response := &ResponseJson {}
+		resourceId := request.ResourceIdentifier()
+		response.Data("identifier", resourceId.Identifier())
+		response.Data("values", resourceId.VariableValues())
+		return response
+	}
+	readable := func(resource Resource) Resource {
+		resource = guestReadable(resource)
+		resource.Handlers[READ] = variableHandler
+		return resource
+	}
+	service := newTestService(t, ServiceConfig {
+		Resources: map[string] Resource {
+			"files": Resource {
+				Type: STATIC,
+				Name: "files",
+			},
+			"file": readable(Resource {
+				Type: WILDCARD,
+				Parent: "files",
+				Name: "file",
+				Pattern: "^[a-z.]+$",
+			}),
+			"special": readable(Resource {
+				Type: VARIABLE,
+				Parent: "files",
+				Name: "special",
+				Pattern: "^special$",
+			}),
+		},
+	})
+	for _, test := range []struct {
+		target string
+		identifier string
+		value string
+	} {
+		{"/files/readme.md", "file", "readme.md"},
+		{"/files/docs/readme.md", "file", "docs/readme.md"},
+		{"/files/special", "special", "special"},
+	} {
+		recorder := testRequest(service, "GET", test.target, "")
+		expectStatus(t, recorder, http.StatusOK)
+		data := responseData(t, recorder)
+		values, _ := data["values"].(map[string] interface{})
+		if data["identifier"] != test.identifier || values[test.identifier] != test.value {
+			t.Errorf("%s: expected '%s' with value '%s', got %v", test.target, test.identifier, test.value, data)
+		}
+	}
+	expectStatus(t, testRequest(service, "GET", "/files/docs/README", ""), http.StatusNotFound)
+
+	url, err := service.UrlFor("file", map[string] string {"file": "docs/readme.md"})
+	if err != nil || url != "/files/docs/readme.md" {
+		t.Errorf("Expected '/files/docs/readme.md', got '%s' (%v)", url, err)
+	}
+	if _, err := service.UrlFor("file", map[string] string {"file": "docs/README"}); err == nil {
+		t.Error("Expected path with mismatching segment to be rejected")
+	}
+
+	err = service.RegisterResource("attachment", Resource {
+		Type: STATIC,
+		Parent: "file",
+		Name: "attachment",
+	})
+	if err == nil {
+		t.Error("Expected child of wildcard resource to be rejected")
+	}
+}
+
+func TestWildcardResourcesValidation(t *testing.T) {
+	problems := ValidateConfig(ServiceConfig {
+		Authentication: AuthenticationConfig {
+			Path: "auth",
+		},
+		Resources: map[string] Resource {
+			"typed": Resource {
+				Type: WILDCARD,
+				Name: "typed",
+				Kind: INT64,
+			},
+			"file": Resource {
+				Type: WILDCARD,
+			},
+			"attachment": Resource {
+				Type: STATIC,
+				Parent: "file",
+				Name: "attachment",
+			},
+		},
+	})
+	for _, expected := range []string {
+		"Wildcard resource 'typed' can't have a variable kind",
+		"Resource 'attachment' can't be a child of wildcard resource 'file'",
+	} {
+		found := false
+		for _, problem := range problems {
+			if strings.HasPrefix(problem.Error(), expected) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Errorf("Expected problem '%s', got %v", expected, problems)
+		}
+	}
+}
@@ -0,0 +1,222 @@
+package apperix
+
+import (
+	"io"
+	"fmt"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+/*
+	The Route type describes a single resource of the resource tree
+	as returned by Service.Routes.
+	The path is the URL template of the resource with variable segments
+	represented by their identifier in braces, like "/users/{user}".
+*/
+type Route struct {
+	Identifier string `json:"identifier"`
+	Name string `json:"name"`
+	Parent string `json:"parent"`
+	Type string `json:"type"`
+	Pattern string `json:"pattern,omitempty"`
+	Kind string `json:"kind,omitempty"`
+	Priority int `json:"priority,omitempty"`
+	Path string `json:"path"`
+	Methods []string `json:"methods"`
+	//methods of each API version, the methods of the default version are listed above
+	Versions map[string] []string `json:"versions,omitempty"`
+	UserPermissions []string `json:"user-permissions"`
+	GuestPermissions []string `json:"guest-permissions"`
+	Inheritance []string `json:"inheritance"`
+	CustomPermissions []string `json:"custom-permissions,omitempty"`
+}
+
+/*
+	inheritanceNames returns the names of the enabled inheritance flags.
+*/
+func inheritanceNames(inheritance PermissionInheritance) []string {
+	names := make([]string, 0)
+	if inheritance.Owner {
+		names = append(names, "owner")
+	}
+	if inheritance.UserPermissions {
+		names = append(names, "user-permissions")
+	}
+	if inheritance.OtherUserPermissions {
+		names = append(names, "other-user-permissions")
+	}
+	if inheritance.GuestPermissions {
+		names = append(names, "guest-permissions")
+	}
+	return names
+}
+
+/*
+	newRoute describes the given resource object located at the given path,
+	methods are named by the given registry.
+*/
+func newRoute(
+	registry *methodRegistry,
+	versions *versioning,
+	resourceObj resourceObject,
+	path string,
+) Route {
+	defaults := resourceObj.DefaultPermissions()
+	route := Route {
+		Identifier: resourceObj.Identifier(),
+		Name: resourceObj.Name(),
+		Parent: resourceObj.Parent(),
+		Type: "static",
+		Pattern: resourceObj.Pattern(),
+		Path: path,
+		Methods: make([]string, 0),
+		UserPermissions: defaults.UserPermissions.Names(),
+		GuestPermissions: defaults.GuestPermissions.Names(),
+		Inheritance: inheritanceNames(defaults.Inheritance),
+		CustomPermissions: resourceObj.CustomPermissions(),
+	}
+	if variableObj, isVariable := resourceObj.(*variableResource); isVariable {
+		route.Type = "variable"
+		route.Priority = variableObj.Priority()
+		if variableObj.Wildcard() {
+			route.Type = "wildcard"
+		}
+		if variableObj.Kind() != ANY {
+			route.Kind = variableObj.Kind().String()
+		}
+	}
+	for _, method := range resourceObj.Methods(versions.defaultVersion) {
+		route.Methods = append(route.Methods, registry.name(method))
+	}
+	if len(versions.names) > 0 {
+		route.Versions = make(map[string] []string)
+		for version, name := range versions.names {
+			route.Versions[name] = make([]string, 0)
+			for _, method := range resourceObj.Methods(version) {
+				route.Versions[name] = append(route.Versions[name], registry.name(method))
+			}
+		}
+	}
+	return route
+}
+
+/*
+	Routes returns all resources of the running service in routing order.
+	The tree is traversed depth first starting at the root resource,
+	static children are listed by name before variable children
+	in the order they are matched in.
+*/
+func (service *Service) Routes() []Route {
+	resources := service.resources()
+	routes := make([]Route, 0, len(resources))
+	var traverse func(identifier string, path string)
+	traverse = func(identifier string, path string) {
+		resourceObj, exists := resources[identifier]
+		if !exists {
+			return
+		}
+		if path == "" {
+			routes = append(routes, newRoute(&service.methods, &service.versions, resourceObj, "/"))
+		} else {
+			routes = append(routes, newRoute(&service.methods, &service.versions, resourceObj, path))
+		}
+
+		//static children sorted by name
+		names := make([]string, 0)
+		for childId, child := range resources {
+			if childId == identifier || child.Parent() != identifier {
+				continue
+			}
+			if _, isStatic := child.(*staticResource); isStatic {
+				names = append(names, child.Name())
+			}
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			childId, err := resourceObj.StaticChildIdentifier(name)
+			if err != nil {
+				continue
+			}
+			traverse(childId, ConcatStrings(path, "/", name))
+		}
+
+		//variable children in matching order
+		for index := 0; index < resourceObj.NumberOfVariableChildren(); index++ {
+			childId, err := resourceObj.VariableChildIdentifier(index)
+			if err != nil {
+				continue
+			}
+			if variableObj, _ := resources[childId].(*variableResource); variableObj != nil && variableObj.Wildcard() {
+				traverse(childId, ConcatStrings(path, "/{", childId, "...}"))
+				continue
+			}
+			traverse(childId, ConcatStrings(path, "/{", childId, "}"))
+		}
+	}
+	traverse("root", "")
+	return routes
+}
+
+/*
+	PrintRoutes writes the given routes as a table to the given writer,
+	one route per line.
+*/
+func PrintRoutes(writer io.Writer, routes []Route) error {
+	joined := func(names []string) string {
+		if len(names) < 1 {
+			return "-"
+		}
+		return strings.Join(names, ",")
+	}
+	table := tabwriter.NewWriter(writer, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(table, "PATH\tIDENTIFIER\tPATTERN\tMETHODS\tUSER\tGUEST\tINHERITANCE")
+	for _, route := range routes {
+		pattern := route.Pattern
+		if pattern == "" {
+			pattern = "-"
+		}
+		fmt.Fprintf(
+			table,
+			"%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			route.Path,
+			route.Identifier,
+			pattern,
+			joined(route.Methods),
+			joined(route.UserPermissions),
+			joined(route.GuestPermissions),
+			joined(route.Inheritance),
+		)
+	}
+	return table.Flush()
+}
+
+/*
+	RoutesHandler serves the resource tree of the service as JSON.
+*/
+func RoutesHandler(
+	client *Client,
+	request *Request,
+	service *Service,
+) Response {
+	response := &ResponseJson {}
+	response.Data("routes", service.Routes())
+	return response
+}
+
+/*
+	RoutesResource returns a resource serving the resource tree
+	of the service as JSON on READ, for debugging purposes.
+	Neither users nor guests are granted any permissions by default,
+	read permission must be assigned to administrators explicitly.
+*/
+func RoutesResource(parent string, name string) Resource {
+	return Resource {
+		Parent: parent,
+		Name: name,
+		Type: STATIC,
+		Handlers: map[Method] Handler {
+			READ: RoutesHandler,
+		},
+	}
+}
@@ -0,0 +1,94 @@
+package apperix
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"net/http"
+)
+
+func TestRoutesListsTreeInRoutingOrder(t *testing.T) {
+	service := newTestService(t, ServiceConfig {
+		Resources: map[string] Resource {
+			"users": guestReadable(Resource {
+				Type: STATIC,
+				Name: "users",
+			}),
+			"user": Resource {
+				Type: VARIABLE,
+				Parent: "users",
+				Pattern: "^[a-z]+$",
+				Kind: SLUG,
+			},
+			"files": Resource {
+				Type: WILDCARD,
+				Parent: "user",
+			},
+			"admin": RoutesResource("root", "admin"),
+		},
+	})
+	routes := service.Routes()
+	paths := make([]string, 0, len(routes))
+	for _, route := range routes {
+		paths = append(paths, route.Path)
+	}
+	expected := "/ /admin /auth /users /users/{user} /users/{user}/{files...}"
+	if strings.Join(paths, " ") != expected {
+		t.Fatalf("Expected paths '%s', got %v", expected, paths)
+	}
+	users, user, files := routes[3], routes[4], routes[5]
+	if users.Type != "static" ||
+		len(users.Methods) != 1 ||
+		users.Methods[0] != "read" ||
+		len(users.GuestPermissions) != 1 ||
+		users.GuestPermissions[0] != "read" {
+		t.Errorf("Unexpected static route %+v", users)
+	}
+	if user.Type != "variable" ||
+		user.Parent != "users" ||
+		user.Pattern != "^[a-z]+$" ||
+		user.Kind != "slug" ||
+		len(user.Methods) != 0 {
+		t.Errorf("Unexpected variable route %+v", user)
+	}
+	if files.Type != "wildcard" || files.Identifier != "files" {
+		t.Errorf("Unexpected wildcard route %+v", files)
+	}
+
+	var buffer bytes.Buffer
+	err := PrintRoutes(&buffer, routes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buffer.String()), "\n")
+	if len(lines) != len(routes) + 1 ||
+		!strings.HasPrefix(lines[0], "PATH") ||
+		!strings.HasPrefix(lines[4], "/users ") {
+		t.Errorf("Unexpected route table:\n%s", buffer.String())
+	}
+}
+
+func TestRoutesResourceRequiresPermission(t *testing.T) {
+	service := newTestService(t, ServiceConfig {
+		Resources: map[string] Resource {
+			"admin": RoutesResource("root", "admin"),
+		},
+	})
+	userId, token := testUser(t, service, "user")
+	expectStatus(t, testRequest(service, "GET", "/admin", ""), http.StatusForbidden)
+	expectStatus(t, testRequest(service, "GET", "/admin", token), http.StatusForbidden)
+
+	resourceId, _ := service.GetResourceIdentifier("admin", nil)
+	err := service.AssignPermissions(resourceId, userId, Permissions {
+		Read: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorder := testRequest(service, "GET", "/admin", token)
+	expectStatus(t, recorder, http.StatusOK)
+	routes, _ := responseData(t, recorder)["routes"].([]interface{})
+	if len(routes) != 3 {
+		t.Errorf("Expected three routes, got %v", routes)
+	}
+}
@@ -0,0 +1,244 @@
+package apperix
+
+import (
+	"fmt"
+	"strings"
+)
+
+/*
+	copyResourceTree returns a shallow copy of the given resource tree.
+	Resources which children are modified must be cloned
+	before modification.
+*/
+func copyResourceTree(
+	resources map[string] resourceObject,
+) map[string] resourceObject {
+	copied := make(map[string] resourceObject, len(resources))
+	for identifier, resourceObj := range resources {
+		copied[identifier] = resourceObj
+	}
+	return copied
+}
+
+/*
+	RegisterResource adds the given resource to the running service.
+	The resource tree is replaced atomically, requests in process
+	keep using the tree they started with.
+	An error will be returned in either of the cases:
+	1) the identifier is reserved or already registered.
+	2) the parent resource is not registered.
+	3) the name overlaps with a sibling (ignoring case if configured),
+	the authentication or ACL path,
+	a mounted service or an API version prefix.
+	4) the resource declares custom permissions unknown to the service.
+	5) the pattern of a variable resource can't be compiled.
+	6) the pattern overlaps with a variable sibling of equal priority.
+	7) the resource handles a method or API version unknown to the service.
+*/
+func (service *Service) RegisterResource(
+	identifier string,
+	resource Resource,
+) (
+	err error,
+) {
+	service.treeLock.Lock()
+	defer service.treeLock.Unlock()
+	current := service.resources()
+
+	if resource.Parent == "" {
+		resource.Parent = "root"
+	}
+	switch identifier {
+	case "root", "auth", "openapi":
+		return fmt.Errorf("Resource identifier '%s' reserved", identifier)
+	}
+	if _, exists := current[identifier]; exists {
+		return fmt.Errorf("Resource '%s' already registered", identifier)
+	}
+	parent, exists := current[resource.Parent]
+	if !exists {
+		return fmt.Errorf(
+			"Resource '%s' referenced unregistered parent resource ('%s')",
+			identifier,
+			resource.Parent,
+		)
+	}
+	if variableObj, isVariable := parent.(*variableResource); isVariable && variableObj.Wildcard() {
+		return fmt.Errorf(
+			"Resource '%s' can't be a child of wildcard resource '%s'",
+			identifier,
+			resource.Parent,
+		)
+	}
+	if resource.Type == WILDCARD && resource.Kind != ANY {
+		return fmt.Errorf("Wildcard resource '%s' can't have a variable kind", identifier)
+	}
+	if resource.Type == VARIABLE || resource.Type == WILDCARD {
+		for index := 0; index < parent.NumberOfVariableChildren(); index++ {
+			siblingId, err := parent.VariableChildIdentifier(index)
+			if err != nil {
+				continue
+			}
+			sibling := current[siblingId].(*variableResource)
+			siblingType := VARIABLE
+			if sibling.Wildcard() {
+				siblingType = WILDCARD
+			}
+			err = variableConflict(siblingId, Resource {
+				Type: siblingType,
+				Pattern: sibling.Pattern(),
+				Kind: sibling.Kind(),
+				Priority: sibling.Priority(),
+			}, identifier, resource)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	if resource.Type == STATIC {
+		if parent.HasStaticChild(resource.Name) {
+			return fmt.Errorf(
+				"Duplicate static resource name ('%s') in parent '%s'",
+				resource.Name,
+				resource.Parent,
+			)
+		}
+		if service.Config.AclPath() != "" && resource.Name == service.Config.AclPath() {
+			return fmt.Errorf("Resource ('%s') overlaps with ACL path", identifier)
+		}
+		if _, exists := service.mounted()[resource.Name]; exists && resource.Parent == "root" {
+			return fmt.Errorf("Resource ('%s') overlaps with a mounted service", identifier)
+		}
+		if service.versions.prefixed(resource.Name) && resource.Parent == "root" {
+			return fmt.Errorf("Resource ('%s') overlaps with API version prefix", identifier)
+		}
+		if service.Config.normalizationConfig.CaseInsensitive {
+			for siblingId, sibling := range current {
+				_, isStatic := sibling.(*staticResource)
+				if isStatic &&
+					siblingId != "root" &&
+					sibling.Parent() == resource.Parent &&
+					strings.EqualFold(sibling.Name(), resource.Name) {
+					return fmt.Errorf(
+						"Static resource names of '%s' and '%s' only differ in case",
+						siblingId,
+						identifier,
+					)
+				}
+			}
+		}
+	}
+	for method := range resource.Handlers {
+		if _, known := service.methods.verbs[method]; !known {
+			return fmt.Errorf(
+				"Resource '%s' handles unregistered method (%d)",
+				identifier,
+				int(method),
+			)
+		}
+	}
+	for version, handlers := range resource.VersionHandlers {
+		if _, known := service.versions.indices[version]; !known {
+			return fmt.Errorf(
+				"Resource '%s' handles unknown API version '%s'",
+				identifier,
+				version,
+			)
+		}
+		for method := range handlers {
+			if _, known := service.methods.verbs[method]; !known {
+				return fmt.Errorf(
+					"Resource '%s' handles unregistered method (%d)",
+					identifier,
+					int(method),
+				)
+			}
+		}
+	}
+	for _, name := range resource.CustomPermissions {
+		if !service.customPermissions.declares(name) {
+			return fmt.Errorf(
+				"Custom permission '%s' of resource '%s' unknown to the service",
+				name,
+				identifier,
+			)
+		}
+	}
+	if resource.OnCreate != nil {
+		for _, grant := range resource.OnCreate.Grants {
+			switch grant.User.(type) {
+			case Identifier, *Identifier, TargetUser:
+			default:
+				return fmt.Errorf(
+					"Invalid target user type in creation template of '%s'",
+					identifier,
+				)
+			}
+		}
+	}
+	resourceObj, err := newResourceObject(identifier, resource, service.versions.names)
+	if err != nil {
+		return err
+	}
+
+	//swap tree
+	updated := copyResourceTree(current)
+	parent = parent.clone()
+	switch resource.Type {
+	case STATIC:
+		parent.DefineStaticChild(identifier, resource.Name)
+	case VARIABLE, WILDCARD:
+		parent.DefineVariableChild(identifier)
+	}
+	updated[resource.Parent] = parent
+	updated[identifier] = resourceObj
+	orderVariableChildren(parent, updated)
+	return service.storeTree(updated)
+}
+
+/*
+	UnregisterResource removes the given resource
+	and all its descendants from the running service.
+	Stored ownership and permissions are kept, use ForgetResource
+	to remove them.
+	An error will be returned in case the resource is reserved
+	or not registered.
+*/
+func (service *Service) UnregisterResource(
+	identifier string,
+) (
+	err error,
+) {
+	service.treeLock.Lock()
+	defer service.treeLock.Unlock()
+	current := service.resources()
+
+	switch identifier {
+	case "root", "auth", "openapi":
+		return fmt.Errorf("Resource identifier '%s' reserved", identifier)
+	}
+	resourceObj, exists := current[identifier]
+	if !exists {
+		return NotFoundError {
+			message: fmt.Sprintf("Resource '%s' not registered", identifier),
+		}
+	}
+
+	//swap tree
+	updated := copyResourceTree(current)
+	for descendant := range current {
+		if isDescendantOf(current, descendant, identifier) {
+			delete(updated, descendant)
+		}
+	}
+	delete(updated, identifier)
+	parent := current[resourceObj.Parent()].clone()
+	switch resourceObj.(type) {
+	case *staticResource:
+		parent.RemoveStaticChild(resourceObj.Name())
+	case *variableResource:
+		parent.RemoveVariableChild(identifier)
+	}
+	updated[resourceObj.Parent()] = parent
+	return service.storeTree(updated)
+}
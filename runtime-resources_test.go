@@ -0,0 +1,242 @@
+package apperix
+
+import (
+	"testing"
+	"net/http"
+)
+
+/*
+	runtimeTestService returns a service serving access control lists below "_acl"
+	with the resource "items" and its variable child "item".
+*/
+func runtimeTestService(t *testing.T) *Service {
+	t.Helper()
+	return newTestService(t, ServiceConfig {
+		Security: SecurityConfig {
+			AclPath: "_acl",
+		},
+		Resources: map[string] Resource {
+			"items": Resource {
+				Type: STATIC,
+				Name: "items",
+			},
+			"item": Resource {
+				Type: VARIABLE,
+				Parent: "items",
+				Pattern: "^[0-9]+$",
+			},
+		},
+	})
+}
+
+/*
+	guestReadable returns handlers answering READ
+	along with default permissions allowing guests to read.
+*/
+func guestReadable(resource Resource) Resource {
+	resource.Handlers = map[Method] Handler {
+		READ: okHandler,
+	}
+	resource.Permissions = DefaultResourcePermissions {
+		GuestPermissions: Permissions {
+			Read: true,
+		},
+	}
+	return resource
+}
+
+func TestRegisterAndUnregisterResources(t *testing.T) {
+	service := runtimeTestService(t)
+	expectStatus(t, testRequest(service, "GET", "/reports", ""), http.StatusNotFound)
+
+	err := service.RegisterResource("reports", guestReadable(Resource {
+		Type: STATIC,
+		Name: "reports",
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = service.RegisterResource("report", guestReadable(Resource {
+		Type: VARIABLE,
+		Parent: "reports",
+		Pattern: "^[a-z]+$",
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectStatus(t, testRequest(service, "GET", "/reports", ""), http.StatusOK)
+	expectStatus(t, testRequest(service, "GET", "/reports/monthly", ""), http.StatusOK)
+	if _, err := service.GetResourceIdentifier("report", map[string] string {
+		"report": "monthly",
+	}); err != nil {
+		t.Errorf("Expected registered resource to be identifiable: %s", err)
+	}
+
+	err = service.UnregisterResource("reports")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectStatus(t, testRequest(service, "GET", "/reports", ""), http.StatusNotFound)
+	expectStatus(t, testRequest(service, "GET", "/reports/monthly", ""), http.StatusNotFound)
+	if _, exists := service.resources()["report"]; exists {
+		t.Error("Expected descendants to be unregistered too")
+	}
+
+	//the name can be registered again
+	err = service.RegisterResource("reports", guestReadable(Resource {
+		Type: STATIC,
+		Name: "reports",
+	}))
+	if err != nil {
+		t.Errorf("Expected name of unregistered resource to be available: %s", err)
+	}
+}
+
+func TestRegisterResourceErrors(t *testing.T) {
+	service := runtimeTestService(t)
+	err := service.RegisterResource("files", Resource {
+		Type: WILDCARD,
+		Name: "files",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		description string
+		identifier string
+		resource Resource
+	} {
+		{
+			"reserved identifier",
+			"auth",
+			Resource {
+				Type: STATIC,
+				Name: "login",
+			},
+		},
+		{
+			"registered identifier",
+			"items",
+			Resource {
+				Type: STATIC,
+				Name: "other",
+			},
+		},
+		{
+			"unregistered parent",
+			"orphan",
+			Resource {
+				Type: STATIC,
+				Name: "orphan",
+				Parent: "missing",
+			},
+		},
+		{
+			"child of a wildcard",
+			"details",
+			Resource {
+				Type: STATIC,
+				Name: "details",
+				Parent: "files",
+			},
+		},
+		{
+			"duplicate static name",
+			"duplicate",
+			Resource {
+				Type: STATIC,
+				Name: "items",
+			},
+		},
+		{
+			"authentication path",
+			"login",
+			Resource {
+				Type: STATIC,
+				Name: "auth",
+			},
+		},
+		{
+			"ACL path",
+			"acl",
+			Resource {
+				Type: STATIC,
+				Name: "_acl",
+				Parent: "items",
+			},
+		},
+		{
+			"unknown custom permission",
+			"approvals",
+			Resource {
+				Type: STATIC,
+				Name: "approvals",
+				CustomPermissions: []string {"approve"},
+			},
+		},
+		{
+			"invalid pattern",
+			"broken",
+			Resource {
+				Type: VARIABLE,
+				Parent: "items",
+				Pattern: "(",
+			},
+		},
+		{
+			"overlapping variable sibling",
+			"overlapping",
+			Resource {
+				Type: VARIABLE,
+				Parent: "items",
+				Pattern: "^[0-9]+$",
+			},
+		},
+		{
+			"unregistered method",
+			"unhandled",
+			Resource {
+				Type: STATIC,
+				Name: "unhandled",
+				Handlers: map[Method] Handler {
+					Method(1000): okHandler,
+				},
+			},
+		},
+		{
+			"unknown API version",
+			"unversioned",
+			Resource {
+				Type: STATIC,
+				Name: "unversioned",
+				VersionHandlers: map[string] map[Method] Handler {
+					"v9": map[Method] Handler {
+						READ: okHandler,
+					},
+				},
+			},
+		},
+	} {
+		before := len(service.resources())
+		err := service.RegisterResource(test.identifier, test.resource)
+		if err == nil {
+			t.Errorf("%s: expected registration to fail", test.description)
+		}
+		if len(service.resources()) != before {
+			t.Errorf("%s: expected resource tree to stay unchanged", test.description)
+		}
+	}
+}
+
+func TestUnregisterResourceErrors(t *testing.T) {
+	service := runtimeTestService(t)
+	for _, identifier := range []string {"root", "auth", "openapi"} {
+		if err := service.UnregisterResource(identifier); err == nil {
+			t.Errorf("Expected reserved resource '%s' to stay registered", identifier)
+		}
+	}
+	err := service.UnregisterResource("missing")
+	if _, isNotFound := err.(NotFoundError); !isNotFound {
+		t.Errorf("Expected unregistered resource to be reported, got %v", err)
+	}
+}
@@ -4,7 +4,9 @@ import (
 	"fmt"
 	"time"
 	"sync"
+	"sync/atomic"
 	"net/http"
+	"crypto/tls"
 	"database/sql"
 	"github.com/golang/crypto/bcrypt"
 )
@@ -31,9 +33,30 @@ type configuration struct {
 	certificate []byte
 	privateKey []byte
 
-	authConfig AuthenticationConfig
 	networkConfig NetworkConfig
+	normalizationConfig NormalizationConfig
+
+	//current *reloadableConfiguration, replaced on reload
+	reloadable atomic.Value
+}
+
+/*
+	reloadableConfiguration bundles configuration data
+	which can be replaced while the service is running.
+*/
+type reloadableConfiguration struct {
+	aclPath string
+	authConfig AuthenticationConfig
 	defaultsConfig DefaultsConfig
+	documentationConfig DocumentationConfig
+	certificate *tls.Certificate
+}
+
+/*
+	current returns the current reloadable configuration.
+*/
+func (config *configuration) current() *reloadableConfiguration {
+	return config.reloadable.Load().(*reloadableConfiguration)
 }
 
 /*
@@ -48,14 +71,30 @@ func (config *configuration) Name() string {
 	generated by the service is valid for.
 */
 func (config *configuration) AccessTokenLiveTime() time.Duration {
-	return config.authConfig.TokenExpiry
+	return config.current().authConfig.TokenExpiry
 }
 
 /*
 	?
 */
 func (config *configuration) JwtSignatureSecret() []byte {
-	return []byte(config.authConfig.SignatureSecret)
+	return []byte(config.current().authConfig.SignatureSecret)
+}
+
+/*
+	BaseUrl returns the external base URL of the service,
+	an empty string is returned if not configured.
+*/
+func (config *configuration) BaseUrl() string {
+	return config.networkConfig.BaseUrl
+}
+
+/*
+	AclPath returns the name of the ACL sub-resource,
+	an empty string is returned if the ACL resource is disabled.
+*/
+func (config *configuration) AclPath() string {
+	return config.current().aclPath
 }
 
 /*
@@ -83,6 +122,17 @@ func verifyTargetUser(user interface{}) (userId string) {
 	return userId
 }
 
+/*
+	The OwnershipTransfer type represents an entry
+	in the ownership history of a resource.
+	From is nil in case the resource had no owner before.
+*/
+type OwnershipTransfer struct {
+	From *Identifier
+	To Identifier
+	Time time.Time
+}
+
 /*
 	The Service type represents an actual apperix service.
 */
@@ -97,7 +147,34 @@ type Service struct {
 	userProvider userProvider
 	permissionProvider permissionProvider
 	ownerProvider ownerProvider
-	resources map[string] resourceObject
+	//current *resourceTree, replaced as a whole on changes
+	tree atomic.Value
+	treeLock sync.Mutex
+	customPermissions customPermissionSet
+	methods methodRegistry
+	versions versioning
+	//service managing user accounts and access tokens, nil if managed itself
+	users *Service
+	//current map[string] *Service of mounted services, replaced as a whole on changes
+	mounts atomic.Value
+	//current *mountPoint in case the service is mounted
+	mountPoint atomic.Value
+	auditor func(AuditEntry)
+	//configuration the service was created or last reloaded with
+	loadedConfig ServiceConfig
+	reloadLock sync.Mutex
+	//1 while a batch transaction is open, set while holding batchLock
+	batching int32
+	//held exclusively by batches, shared by request handling waiting for them
+	batchLock sync.RWMutex
+}
+
+/*
+	resources returns the current resource tree mapped by identifier.
+	The returned map must not be modified.
+*/
+func (service *Service) resources() map[string] resourceObject {
+	return service.tree.Load().(*resourceTree).resources
 }
 
 /*
@@ -159,6 +236,13 @@ func (service *Service) CreateUser(
 	assignedId Identifier,
 	err error,
 ) {
+	if accounts := service.accounts(); accounts != service {
+		return accounts.CreateUser(username, password)
+	}
+	err = service.rejectDuringBatch()
+	if err != nil {
+		return assignedId, err
+	}
 	txn := service.createTransaction()
 	txn.Begin()
 	defer func() {
@@ -242,7 +326,7 @@ func (service *Service) FindUserById(
 	account UserAccount,
 	err error,
 ) {
-	return service.userProvider.FindUserById(identifier)
+	return service.accounts().userProvider.FindUserById(identifier)
 }
 
 /*
@@ -255,7 +339,7 @@ func (service *Service) FindUserByUsername(
 	account UserAccount,
 	err error,
 ) {
-	return service.userProvider.FindUserByUsername(username)
+	return service.accounts().userProvider.FindUserByUsername(username)
 }
 
 /*
@@ -273,7 +357,22 @@ func (service *Service) GetResourceIdentifier(
 	resourceId ResourceIdentifier,
 	err error,
 ) {
-	resourceObj, exists := service.resources[identifier]
+	return resourceIdentifierIn(service.resources(), identifier, variables)
+}
+
+/*
+	resourceIdentifierIn works like GetResourceIdentifier,
+	but resolves the resource within the given resource tree.
+*/
+func resourceIdentifierIn(
+	resources map[string] resourceObject,
+	identifier string,
+	variables map[string] string,
+) (
+	resourceId ResourceIdentifier,
+	err error,
+) {
+	resourceObj, exists := resources[identifier]
 	if !exists {
 		return resourceId, fmt.Errorf(
 			"Resource identified by '%s' not found",
@@ -292,6 +391,10 @@ func (service *Service) GetResourceIdentifier(
 			segment.typ = STATIC
 		case *variableResource:
 			segment.typ = VARIABLE
+			if resourceObj.(*variableResource).Wildcard() {
+				segment.typ = WILDCARD
+			}
+			segment.kind = resourceObj.(*variableResource).Kind()
 			segment.value = variables[segment.identifier]
 			if len(variables[segment.identifier]) < 1 {
 				//value for variable resource missing
@@ -300,8 +403,9 @@ func (service *Service) GetResourceIdentifier(
 					segment.identifier,
 				)
 			}
-			if !resourceObj.(*variableResource).MatchPattern(segment.value) {
-				//given doesnt match enforced pattern
+			segment.parsed, err = resourceObj.(*variableResource).Parse(segment.value)
+			if err != nil {
+				//given doesnt match enforced pattern or kind
 				return resourceId, fmt.Errorf(
 					"Wrong value for variable segment '%s'",
 					segment.identifier,
@@ -313,14 +417,76 @@ func (service *Service) GetResourceIdentifier(
 			resourceId.path...
 		)
 		parentId := resourceObj.Parent()
-		resourceObj = service.resources[parentId]
 		if parentId == "root" || parentId == "" {
 			hasParent = false
+			continue
+		}
+		resourceObj, exists = resources[parentId]
+		if !exists {
+			return resourceId, fmt.Errorf(
+				"Parent resource '%s' of '%s' not found",
+				parentId,
+				segment.identifier,
+			)
 		}
 	}
 	return resourceId, nil
 }
 
+/*
+	UrlFor returns the URL of the resource identified by the given identifier
+	and variable values. Variable values are verified against the patterns
+	of their resources and percent-encoded.
+	The URL is absolute in case an external base URL is configured,
+	URLs of mounted services are prefixed by the URL of their mount point.
+	In case API versions are selected by URL prefix,
+	the URL addresses the default version.
+	An error will be returned in the same cases as by GetResourceIdentifier.
+*/
+func (service *Service) UrlFor(
+	identifier string,
+	variables map[string] string,
+) (
+	url string,
+	err error,
+) {
+	return service.UrlForVersion("", identifier, variables)
+}
+
+/*
+	UrlForVersion works like UrlFor, but addresses the given API version
+	in case versions are selected by URL prefix, like the version
+	returned by Request.Version. An empty version addresses the default version.
+	An error will be returned in case the version is unknown
+	or in the same cases as by GetResourceIdentifier.
+*/
+func (service *Service) UrlForVersion(
+	version string,
+	identifier string,
+	variables map[string] string,
+) (
+	url string,
+	err error,
+) {
+	index := service.versions.defaultVersion
+	if version != "" {
+		var exists bool
+		index, exists = service.versions.indices[version]
+		if !exists {
+			return url, fmt.Errorf("Unknown API version '%s'", version)
+		}
+	}
+	resourceId, err := service.GetResourceIdentifier(identifier, variables)
+	if err != nil {
+		return url, err
+	}
+	return ConcatStrings(
+		service.urlPrefix(),
+		service.versions.urlPrefix(index),
+		resourceId.Url(),
+	), nil
+}
+
 /*
 	AssignOwner transfers ownership of the given resource
 	to the given user. The new owner will be defined in case
@@ -332,6 +498,10 @@ func (service *Service) AssignOwner(
 ) (
 	err error,
 ) {
+	err = service.rejectDuringBatch()
+	if err != nil {
+		return err
+	}
 	userIdStr := userId.String()
 	resourceIdStr := resourceId.Serialize()
 	txn := service.createTransaction()
@@ -346,7 +516,7 @@ func (service *Service) AssignOwner(
 
 	//verify resource entry exists
 	verifyExistance, err := service.database.Prepare(`
-		SELECT str_id FROM resources WHERE str_id = ?
+		SELECT owner_id FROM resources WHERE str_id = ?
 	`)
 	defer verifyExistance.Close()
 	if err != nil {
@@ -363,8 +533,16 @@ func (service *Service) AssignOwner(
 			err,
 		)
 	}
+	var previousOwner *string
 	rowCount := 0
 	for rows.Next() {
+		err = rows.Scan(&previousOwner)
+		if err != nil {
+			return fmt.Errorf(
+				"Failed registering owner in database: %s",
+				err,
+			)
+		}
 		rowCount++
 	}
 
@@ -415,9 +593,353 @@ func (service *Service) AssignOwner(
 			)
 		}
 	}
+
+	//record ownership change
+	if previousOwner == nil || *previousOwner != userIdStr {
+		err = recordOwnershipTransfer(
+			service.database,
+			resourceIdStr,
+			previousOwner,
+			userIdStr,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	service.ownerProvider.Invalidate(resourceId)
+	return nil
+}
+
+/*
+	recordOwnershipTransfer appends an entry to the ownership history
+	of the given resource. The previous owner is nil
+	in case the resource had no owner before.
+*/
+func recordOwnershipTransfer(
+	executor sqlExecutor,
+	resourceIdStr string,
+	previousOwner *string,
+	newOwner string,
+) (
+	err error,
+) {
+	insertHistory, err := executor.Prepare(`
+		INSERT INTO ownership_history
+		(resource_id, from_id, to_id, transferred_at)
+		VALUES (
+			(SELECT id FROM resources WHERE str_id = ?),
+			?,?,?
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf(
+			"Failed recording ownership transfer in database: %s",
+			err,
+		)
+	}
+	defer insertHistory.Close()
+	_, err = insertHistory.Exec(
+		resourceIdStr,
+		previousOwner,
+		newOwner,
+		time.Now().UTC().Unix(),
+	)
+	if err != nil {
+		return fmt.Errorf(
+			"Failed recording ownership transfer in database: %s",
+			err,
+		)
+	}
 	return nil
 }
 
+/*
+	TransferOwnership transfers ownership of the given resource
+	from its current owner to the given new owner and records
+	the transfer in the ownership history.
+	An error will be returned in case the given current owner
+	is not the directly assigned owner of the resource.
+	Co-owners are not affected except for the new owner
+	who is no longer registered as co-owner.
+*/
+func (service *Service) TransferOwnership(
+	resourceId ResourceIdentifier,
+	currentOwner Identifier,
+	newOwner Identifier,
+) (
+	err error,
+) {
+	err = service.rejectDuringBatch()
+	if err != nil {
+		return err
+	}
+	currentOwnerStr := currentOwner.String()
+	newOwnerStr := newOwner.String()
+	resourceIdStr := resourceId.Serialize()
+	txn := service.createTransaction()
+	defer func() {
+		if err != nil {
+			txn.Rollback()
+		} else {
+			txn.Commit()
+		}
+	}()
+	txn.Begin()
+
+	//verify current owner
+	selectOwner, err := service.database.Prepare(`
+		SELECT owner_id FROM resources WHERE str_id = ?
+	`)
+	if err != nil {
+		return fmt.Errorf(
+			"Failed transferring ownership in database: %s",
+			err,
+		)
+	}
+	defer selectOwner.Close()
+	var actualOwner *string
+	err = selectOwner.QueryRow(resourceIdStr).Scan(&actualOwner)
+	if err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf(
+			"Failed transferring ownership in database: %s",
+			err,
+		)
+	}
+	if actualOwner == nil || *actualOwner != currentOwnerStr {
+		return fmt.Errorf(
+			"User '%s' does not own resource '%s'",
+			currentOwnerStr,
+			resourceId.String(),
+		)
+	}
+
+	//update owner
+	updateOwner, err := service.database.Prepare(`
+		UPDATE resources SET owner_id = ?
+		WHERE str_id = ?;
+	`)
+	if err != nil {
+		return fmt.Errorf(
+			"Failed transferring ownership in database: %s",
+			err,
+		)
+	}
+	defer updateOwner.Close()
+	_, err = updateOwner.Exec(newOwnerStr, resourceIdStr)
+	if err != nil {
+		return fmt.Errorf(
+			"Failed transferring ownership in database: %s",
+			err,
+		)
+	}
+
+	//new owner is no longer a co-owner
+	deleteCoOwner, err := service.database.Prepare(`
+		DELETE FROM resource_owners
+		WHERE resource_id = (SELECT id FROM resources WHERE str_id = ?)
+		AND owner_id = ?
+	`)
+	if err != nil {
+		return fmt.Errorf(
+			"Failed transferring ownership in database: %s",
+			err,
+		)
+	}
+	defer deleteCoOwner.Close()
+	_, err = deleteCoOwner.Exec(resourceIdStr, newOwnerStr)
+	if err != nil {
+		return fmt.Errorf(
+			"Failed transferring ownership in database: %s",
+			err,
+		)
+	}
+
+	err = recordOwnershipTransfer(
+		service.database,
+		resourceIdStr,
+		actualOwner,
+		newOwnerStr,
+	)
+	if err != nil {
+		return err
+	}
+	service.ownerProvider.Invalidate(resourceId)
+	return nil
+}
+
+/*
+	AddCoOwner registers the given user as co-owner of the given resource.
+	Co-owners are treated as owners when resolving permissions.
+*/
+func (service *Service) AddCoOwner(
+	resourceId ResourceIdentifier,
+	userId Identifier,
+) (
+	err error,
+) {
+	err = service.rejectDuringBatch()
+	if err != nil {
+		return err
+	}
+	resourceIdStr := resourceId.Serialize()
+	txn := service.createTransaction()
+	defer func() {
+		if err != nil {
+			txn.Rollback()
+		} else {
+			txn.Commit()
+		}
+	}()
+	txn.Begin()
+
+	//insert resource entry if missing
+	insertResEntry, err := service.database.Prepare(`
+		INSERT OR IGNORE INTO resources (str_id) VALUES (?)
+	`)
+	if err != nil {
+		return fmt.Errorf(
+			"Failed registering co-owner in database: %s",
+			err,
+		)
+	}
+	defer insertResEntry.Close()
+	_, err = insertResEntry.Exec(resourceIdStr)
+	if err != nil {
+		return fmt.Errorf(
+			"Failed registering co-owner in database: %s",
+			err,
+		)
+	}
+
+	//insert co-owner entry
+	insertCoOwner, err := service.database.Prepare(`
+		INSERT OR IGNORE INTO resource_owners (resource_id, owner_id)
+		VALUES (
+			(SELECT id FROM resources WHERE str_id = ?),
+			?
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf(
+			"Failed registering co-owner in database: %s",
+			err,
+		)
+	}
+	defer insertCoOwner.Close()
+	_, err = insertCoOwner.Exec(resourceIdStr, userId.String())
+	if err != nil {
+		return fmt.Errorf(
+			"Failed registering co-owner in database: %s",
+			err,
+		)
+	}
+	service.ownerProvider.Invalidate(resourceId)
+	return nil
+}
+
+/*
+	RemoveCoOwner removes the given user from the co-owners
+	of the given resource.
+*/
+func (service *Service) RemoveCoOwner(
+	resourceId ResourceIdentifier,
+	userId Identifier,
+) (
+	err error,
+) {
+	err = service.rejectDuringBatch()
+	if err != nil {
+		return err
+	}
+	deleteCoOwner, err := service.database.Prepare(`
+		DELETE FROM resource_owners
+		WHERE resource_id = (SELECT id FROM resources WHERE str_id = ?)
+		AND owner_id = ?
+	`)
+	if err != nil {
+		return fmt.Errorf(
+			"Failed to delete co-owner entry in database: %s",
+			err,
+		)
+	}
+	defer deleteCoOwner.Close()
+	_, err = deleteCoOwner.Exec(resourceId.Serialize(), userId.String())
+	if err != nil {
+		return fmt.Errorf(
+			"Failed to delete co-owner entry in database: %s",
+			err,
+		)
+	}
+	service.ownerProvider.Invalidate(resourceId)
+	return nil
+}
+
+/*
+	GetOwnersOf returns the directly assigned owner
+	followed by all co-owners of the given resource.
+	Owners inherited from parent resources are not taken into account.
+*/
+func (service *Service) GetOwnersOf(
+	resourceId ResourceIdentifier,
+) (
+	owners []Identifier,
+	err error,
+) {
+	return service.ownerProvider.GetOwnersOf(resourceId)
+}
+
+/*
+	GetOwnershipHistory returns all recorded ownership transfers
+	of the given resource in chronological order.
+*/
+func (service *Service) GetOwnershipHistory(
+	resourceId ResourceIdentifier,
+) (
+	history []OwnershipTransfer,
+	err error,
+) {
+	history = make([]OwnershipTransfer, 0)
+	statement, err := service.database.Prepare(`
+		SELECT from_id, to_id, transferred_at FROM ownership_history
+		WHERE resource_id = (SELECT id FROM resources WHERE str_id = ?)
+		ORDER BY id ASC
+	`)
+	if err != nil {
+		return history, DatabaseFailureError {
+			message: fmt.Sprintf("Coult not prepare statement: %s", err),
+		}
+	}
+	defer statement.Close()
+	rows, err := statement.Query(resourceId.Serialize())
+	if err != nil {
+		return history, DatabaseFailureError {
+			message: fmt.Sprintf("Coult not query database: %s", err),
+		}
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var from *string
+		var to string
+		var transferredAt int64
+		err = rows.Scan(&from, &to, &transferredAt)
+		if err != nil {
+			return history, DatabaseFailureError {
+				message: fmt.Sprintf("Coult not scan row: %s", err),
+			}
+		}
+		entry := OwnershipTransfer {
+			Time: time.Unix(transferredAt, 0).UTC(),
+		}
+		if from != nil {
+			entry.From = &Identifier {}
+			entry.From.FromString(*from)
+		}
+		entry.To.FromString(to)
+		history = append(history, entry)
+	}
+	return history, nil
+}
+
 /*
 	AssignPermissions assigns provided permissions
 	for the given user on the given resource.
@@ -435,6 +957,10 @@ func (service *Service) AssignPermissions(
 ) (
 	err error,
 ) {
+	err = service.rejectDuringBatch()
+	if err != nil {
+		return err
+	}
 	userId := verifyTargetUser(user)
 	resourceIdStr := resourceId.Serialize()
 
@@ -512,7 +1038,7 @@ func (service *Service) AssignPermissions(
 	_, err = insertPermissions.Exec(
 		resourceIdStr,
 		userId,
-		permissions.Serialize(),
+		service.customPermissions.serialize(permissions),
 	)
 	if err != nil {
 		return fmt.Errorf(
@@ -520,6 +1046,7 @@ func (service *Service) AssignPermissions(
 			err,
 		)
 	}
+	service.permissionProvider.Invalidate(resourceId, userId)
 
 	return nil
 }
@@ -539,6 +1066,10 @@ func (service *Service) RevokePermissions(
 ) (
 	err error,
 ) {
+	err = service.rejectDuringBatch()
+	if err != nil {
+		return err
+	}
 	userId := verifyTargetUser(user)
 	deleteEntry, err := service.database.Prepare(`
 		DELETE FROM resource_permissions
@@ -565,6 +1096,7 @@ func (service *Service) RevokePermissions(
 	for rows.Next() {
 		rowCount++
 	}
+	service.permissionProvider.Invalidate(resourceId, userId)
 
 	return nil
 }
@@ -604,6 +1136,39 @@ func (service *Service) ResolvePermissionsFor(
 	permissions Permissions,
 	err error,
 ) {
+	return service.resolvePermissionsIn(service.resources(), resourceId, user)
+}
+
+/*
+	resolvePermissionsIn works like ResolvePermissionsFor,
+	but reads the default permissions of the resource and its ancestors
+	from the given resource tree.
+	An error will be returned in case the tree lacks one of them.
+*/
+func (service *Service) resolvePermissionsIn(
+	resources map[string] resourceObject,
+	resourceId ResourceIdentifier,
+	user interface{},
+) (
+	isOwner bool,
+	permissions Permissions,
+	err error,
+) {
+	defaultsOf := func(identifier string) (
+		defaults DefaultResourcePermissions,
+		err error,
+	) {
+		resourceObj, exists := resources[identifier]
+		if !exists {
+			return defaults, fmt.Errorf("Resource '%s' not found", identifier)
+		}
+		return resourceObj.DefaultPermissions(), nil
+	}
+	defaults, err := defaultsOf(resourceId.Identifier())
+	if err != nil {
+		return isOwner, permissions, err
+	}
+
 	if user == nil {
 		user = GUESTS
 	}
@@ -624,9 +1189,10 @@ func (service *Service) ResolvePermissionsFor(
 				switch err.(type) {
 				case NotFoundError:
 					//inherits permissions?
-					defaultPermissions := service.
-						resources[currentResource.Identifier()].
-						DefaultPermissions()
+					defaultPermissions, err := defaultsOf(currentResource.Identifier())
+					if err != nil {
+						return permissions, err
+					}
 					switch user {
 					case "o":
 						if !defaultPermissions.Inheritance.OtherUserPermissions {
@@ -674,19 +1240,21 @@ func (service *Service) ResolvePermissionsFor(
 	resolveOwnership := func (
 		currentResource ResourceIdentifier,
 	) (
-		owner Identifier,
+		owners []Identifier,
 		err error,
 	) {
 		for {
-			owner, err = service.ownerProvider.GetOwnerOf(currentResource)
+			owners, err = service.ownerProvider.GetOwnersOf(currentResource)
 			if err != nil {
 				switch err.(type) {
 				case NotFoundError:
 					//inherits owner?
-					if !service.resources[currentResource.Identifier()].
-						DefaultPermissions().
-						Inheritance.Owner {
-						return owner, NotFoundError {
+					defaultPermissions, err := defaultsOf(currentResource.Identifier())
+					if err != nil {
+						return owners, err
+					}
+					if !defaultPermissions.Inheritance.Owner {
+						return owners, NotFoundError {
 							message: "Owner not found",
 						}
 					}
@@ -694,13 +1262,13 @@ func (service *Service) ResolvePermissionsFor(
 					currentResource, err = currentResource.Parent()
 					if err != nil {
 						//no further parent
-						return owner, NotFoundError {
+						return owners, NotFoundError {
 							message: "Owner not found",
 						}
 					}
 					continue
 				default:
-					return owner, fmt.Errorf(
+					return owners, fmt.Errorf(
 						"Could not get owner for '%s': %s",
 						currentResource.String(),
 						err,
@@ -710,7 +1278,7 @@ func (service *Service) ResolvePermissionsFor(
 			//found owner
 			break
 		}
-		return owner, nil
+		return owners, nil
 	}
 
 	switch user.(type) {
@@ -721,10 +1289,7 @@ func (service *Service) ResolvePermissionsFor(
 			if err != nil {
 				switch err.(type) {
 				case NotFoundError:
-					permissions = service.
-						resources[resourceId.Identifier()].
-						DefaultPermissions().
-						UserPermissions
+					permissions = defaults.UserPermissions
 					return isOwner, permissions, nil
 				default:
 					return isOwner, permissions, fmt.Errorf(
@@ -739,10 +1304,7 @@ func (service *Service) ResolvePermissionsFor(
 			if err != nil {
 				switch err.(type) {
 				case NotFoundError:
-					permissions = service.
-						resources[resourceId.Identifier()].
-						DefaultPermissions().
-						GuestPermissions
+					permissions = defaults.GuestPermissions
 					return isOwner, permissions, nil
 				default:
 					return isOwner, permissions, fmt.Errorf(
@@ -755,7 +1317,7 @@ func (service *Service) ResolvePermissionsFor(
 		}
 	case *Identifier:
 		//is owner?
-		actualOwner, err := resolveOwnership(resourceId)
+		actualOwners, err := resolveOwnership(resourceId)
 		if err != nil {
 			switch err.(type) {
 			case NotFoundError:
@@ -768,8 +1330,11 @@ func (service *Service) ResolvePermissionsFor(
 			}
 		}
 
-		if actualOwner.String() == userId {
-			isOwner = true
+		for _, actualOwner := range actualOwners {
+			if actualOwner.String() == userId {
+				isOwner = true
+				break
+			}
 		}
 		//is mentioned?
 		permissions, err = resolvePermissions(resourceId, userId)
@@ -778,7 +1343,7 @@ func (service *Service) ResolvePermissionsFor(
 			case NotFoundError:
 				//not mentioned
 				if isOwner {
-					permissions.AllowAll()
+					service.customPermissions.allowAll(&permissions)
 					return isOwner, permissions, nil
 				}
 				permissions, err = resolvePermissions(resourceId, "o")
@@ -786,10 +1351,7 @@ func (service *Service) ResolvePermissionsFor(
 					switch err.(type) {
 					case NotFoundError:
 						//permissions for other users not defined
-						permissions = service.
-							resources[resourceId.Identifier()].
-							DefaultPermissions().
-							UserPermissions
+						permissions = defaults.UserPermissions
 						return isOwner, permissions, nil
 					default:
 						return isOwner, permissions, fmt.Errorf(
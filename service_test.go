@@ -0,0 +1,272 @@
+package apperix
+
+import (
+	"time"
+	"testing"
+	"net/http"
+	"encoding/json"
+	"net/http/httptest"
+)
+
+/*
+	newTestService returns a service using a temporary database
+	built from the given configuration.
+	Name, authentication and database location are filled in if missing.
+*/
+func newTestService(t *testing.T, conf ServiceConfig) *Service {
+	t.Helper()
+	if conf.Name == "" {
+		conf.Name = "test"
+	}
+	if conf.Authentication.Path == "" {
+		conf.Authentication.Path = "auth"
+	}
+	if conf.Authentication.SignatureSecret == "" {
+		conf.Authentication.SignatureSecret = "secret"
+	}
+	if conf.Authentication.TokenExpiry == 0 {
+		//parseAuth expects tokens of 197 characters,
+		//which tokens expiring after five digit seconds are
+		conf.Authentication.TokenExpiry = 10 * time.Hour
+	}
+	if conf.Database.Location == "" {
+		conf.Database.Location = t.TempDir()
+	}
+	service, err := NewService(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		service.database.Close()
+	})
+	return service
+}
+
+/*
+	testRequest serves a request of the given HTTP method and target
+	and returns the recorded response.
+	The access token is sent unless empty.
+*/
+func testRequest(
+	service *Service,
+	method string,
+	target string,
+	token string,
+) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(method, target, nil)
+	if token != "" {
+		request.Header.Set("Authorization", token)
+	}
+	recorder := httptest.NewRecorder()
+	service.Handler().ServeHTTP(recorder, request)
+	return recorder
+}
+
+/*
+	responseData decodes the data of the given JSON response.
+*/
+func responseData(t *testing.T, recorder *httptest.ResponseRecorder) map[string] interface{} {
+	t.Helper()
+	var body struct {
+		Data map[string] interface{} `json:"data"`
+	}
+	err := json.Unmarshal(recorder.Body.Bytes(), &body)
+	if err != nil {
+		t.Fatalf("Could not decode response '%s': %s", recorder.Body.String(), err)
+	}
+	return body.Data
+}
+
+/*
+	expectStatus fails the test in case the response has another status.
+*/
+func expectStatus(t *testing.T, recorder *httptest.ResponseRecorder, status int) {
+	t.Helper()
+	if recorder.Code != status {
+		t.Fatalf("Expected status %d, got %d: %s", status, recorder.Code, recorder.Body.String())
+	}
+}
+
+/*
+	testUser creates a user with the given username
+	and returns its identifier and an access token issued for it.
+*/
+func testUser(t *testing.T, service *Service, username string) (Identifier, string) {
+	t.Helper()
+	identifier, err := service.CreateUser(username, "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorder := testRequest(
+		service,
+		"GET",
+		ConcatStrings("/auth?username=", username, "&password=password"),
+		"",
+	)
+	expectStatus(t, recorder, http.StatusOK)
+	token, _ := responseData(t, recorder)["access-token"].(string)
+	return identifier, token
+}
+
+/*
+	okHandler replies an empty successful response.
+*/
+func okHandler(client *Client, request *Request, service *Service) Response {
+	return &ResponseJson {}
+}
+
+func TestCreateUserAndAuthenticate(t *testing.T) {
+	service := newTestService(t, ServiceConfig {})
+	_, token := testUser(t, service, "alice")
+	if token == "" {
+		t.Fatal("Missing access token")
+	}
+	if _, err := service.CreateUser("alice", "password"); err == nil {
+		t.Error("Expected duplicate username to be rejected")
+	}
+	recorder := testRequest(service, "GET", "/auth?username=alice&password=wrong", "")
+	expectStatus(t, recorder, http.StatusForbidden)
+}
+
+func TestOwnershipTransferAndCoOwners(t *testing.T) {
+	service := newTestService(t, ServiceConfig {
+		Resources: map[string] Resource {
+			"items": Resource {
+				Type: STATIC,
+				Name: "items",
+			},
+		},
+	})
+	resourceId, err := service.GetResourceIdentifier("items", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	aliceId, _ := testUser(t, service, "alice")
+	bobId, _ := testUser(t, service, "bob")
+	carolId, _ := testUser(t, service, "carol")
+
+	ownerNames := func() []string {
+		owners, err := service.GetOwnersOf(resourceId)
+		if err != nil {
+			t.Fatal(err)
+		}
+		names := make([]string, 0, len(owners))
+		for _, owner := range owners {
+			names = append(names, owner.String())
+		}
+		return names
+	}
+
+	err = service.AssignOwner(resourceId, aliceId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = service.AddCoOwner(resourceId, bobId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if owners := ownerNames(); len(owners) != 2 ||
+		owners[0] != aliceId.String() ||
+		owners[1] != bobId.String() {
+		t.Errorf("Expected owner alice and co-owner bob, got %v", owners)
+	}
+	isOwner, _, err := service.ResolvePermissionsFor(resourceId, &bobId)
+	if err != nil || !isOwner {
+		t.Errorf("Expected co-owner to be resolved as owner (%v)", err)
+	}
+
+	err = service.TransferOwnership(resourceId, carolId, bobId)
+	if err == nil {
+		t.Error("Expected transfer by non-owner to fail")
+	}
+	err = service.TransferOwnership(resourceId, aliceId, bobId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if owners := ownerNames(); len(owners) != 1 || owners[0] != bobId.String() {
+		t.Errorf("Expected bob as only owner, got %v", owners)
+	}
+	isOwner, _, err = service.ResolvePermissionsFor(resourceId, &aliceId)
+	if err != nil || isOwner {
+		t.Errorf("Expected previous owner to lose ownership (%v)", err)
+	}
+
+	history, err := service.GetOwnershipHistory(resourceId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 2 ||
+		history[0].From != nil ||
+		history[0].To.String() != aliceId.String() ||
+		history[1].From == nil ||
+		history[1].From.String() != aliceId.String() ||
+		history[1].To.String() != bobId.String() {
+		t.Errorf("Unexpected ownership history %v", history)
+	}
+
+	err = service.AddCoOwner(resourceId, carolId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = service.RemoveCoOwner(resourceId, carolId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if owners := ownerNames(); len(owners) != 1 {
+		t.Errorf("Expected removed co-owner to be gone, got %v", owners)
+	}
+}
+
+func TestUrlFor(t *testing.T) {
+	service := newTestService(t, ServiceConfig {
+		Authentication: AuthenticationConfig {
+			Path: "login",
+		},
+		Network: NetworkConfig {
+			BaseUrl: "https://example.com/api/",
+		},
+		Resources: map[string] Resource {
+			"items": guestReadable(Resource {
+				Type: STATIC,
+				Name: "items",
+			}),
+			"item": guestReadable(Resource {
+				Type: VARIABLE,
+				Parent: "items",
+				Pattern: "^[a-z ]+$",
+			}),
+		},
+	})
+	for _, test := range []struct {
+		identifier string
+		variables map[string] string
+		expected string
+	} {
+		{"root", nil, "https://example.com/api/"},
+		{"auth", nil, "https://example.com/api/auth"},
+		{"items", nil, "https://example.com/api/items"},
+		{"item", map[string] string {"item": "a b"}, "https://example.com/api/items/a%20b"},
+	} {
+		url, err := service.UrlFor(test.identifier, test.variables)
+		if err != nil || url != test.expected {
+			t.Errorf("%s: expected '%s', got '%s' (%v)", test.identifier, test.expected, url, err)
+		}
+	}
+	//the authentication resource is served at its built URL
+	expectStatus(t, testRequest(service, "GET", "/auth?username=nobody&password=wrong", ""), http.StatusForbidden)
+	expectStatus(t, testRequest(service, "GET", "/login", ""), http.StatusNotFound)
+
+	for _, test := range []struct {
+		description string
+		identifier string
+		variables map[string] string
+	} {
+		{"unknown identifier", "missing", nil},
+		{"missing variable", "item", nil},
+		{"mismatching variable", "item", map[string] string {"item": "42"}},
+	} {
+		if _, err := service.UrlFor(test.identifier, test.variables); err == nil {
+			t.Errorf("%s: expected an error", test.description)
+		}
+	}
+}
@@ -8,6 +8,16 @@ import (
 	"github.com/satori/go.uuid"
 )
 
+/*
+	sqlExecutor is implemented by both database connections
+	and database transactions.
+*/
+type sqlExecutor interface {
+	Prepare(string) (*sql.Stmt, error)
+	Exec(string, ...interface{}) (sql.Result, error)
+	QueryRow(string, ...interface{}) *sql.Row
+}
+
 type transaction struct {
 	database *sql.DB
 	identifier []byte
@@ -2,6 +2,7 @@ package apperix
 
 import (
 	"fmt"
+	"sync/atomic"
 	"database/sql"
 	"github.com/hashicorp/golang-lru"
 )
@@ -18,7 +19,8 @@ type UserAccount struct {
 
 type userProvider struct {
 	db *sql.DB
-	cache *lru.ARCCache
+	//current *lru.ARCCache, replaced on reload
+	cacheRef atomic.Value
 }
 
 /*
@@ -36,10 +38,24 @@ func (provider *userProvider) initialize(
 		return fmt.Errorf("Could not initialize cache: %s", err)
 	}
 	provider.db = db
-	provider.cache = cache
+	provider.cacheRef.Store(cache)
 	return nil
 }
 
+/*
+	cache returns the current cache of the provider.
+*/
+func (provider *userProvider) cache() *lru.ARCCache {
+	return provider.cacheRef.Load().(*lru.ARCCache)
+}
+
+/*
+	replaceCache replaces the cache by the given empty cache.
+*/
+func (provider *userProvider) replaceCache(cache *lru.ARCCache) {
+	provider.cacheRef.Store(cache)
+}
+
 /*
 	FindUserById returns a user account identified by the given identifier.
 	An error will be returned in case no user was found.
@@ -52,7 +68,7 @@ func (provider *userProvider) FindUserById(
 	err error,
 ) {
 	//cache lookup
-	fromCache, exists := provider.cache.Get(identifier)
+	fromCache, exists := provider.cache().Get(identifier)
 	if exists {
 		return fromCache.(UserAccount), nil
 	}
@@ -101,7 +117,7 @@ func (provider *userProvider) FindUserById(
 	}
 
 	//update cache
-	provider.cache.Add(identifier, account)
+	provider.cache().Add(identifier, account)
 
 	return account, nil
 }
@@ -118,7 +134,7 @@ func (provider *userProvider) FindUserByUsername(
 	err error,
 ) {
 	//cache lookup
-	fromCache, exists := provider.cache.Get(username)
+	fromCache, exists := provider.cache().Get(username)
 	if exists {
 		return fromCache.(UserAccount), nil
 	}
@@ -159,7 +175,7 @@ func (provider *userProvider) FindUserByUsername(
 	}
 
 	//update cache
-	provider.cache.Add(username, account)
+	provider.cache().Add(username, account)
 
 	return account, nil
 }
\ No newline at end of file
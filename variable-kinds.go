@@ -0,0 +1,221 @@
+package apperix
+
+import (
+	"fmt"
+	"time"
+	"regexp"
+	"strconv"
+)
+
+type VariableKind int
+const (
+	//any value matching the pattern
+	ANY VariableKind = iota
+	//signed 64 bit integer in decimal notation, like "-42"
+	INT64
+	//unique identifier in the notation of Identifier.String
+	UUID
+	//lower case alphanumeric words separated by dashes, like "hello-world"
+	SLUG
+	//calendar date in ISO 8601 notation, like "2017-03-21"
+	DATE
+)
+
+/*
+	variableKindPatterns holds the patterns enforced by the variable kinds.
+*/
+var variableKindPatterns = map[VariableKind] *regexp.Regexp {
+	INT64: regexp.MustCompile(`^-?[0-9]{1,19}$`),
+	UUID: regexp.MustCompile(`^[0-9a-f]{32}$`),
+	SLUG: regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`),
+	DATE: regexp.MustCompile(`^[0-9]{4}-[0-9]{2}-[0-9]{2}$`),
+}
+
+var variableKindNames = []string {
+	"any",
+	"int64",
+	"uuid",
+	"slug",
+	"date",
+}
+
+func (kind VariableKind) String() string {
+	if int(kind) < 0 || int(kind) >= len(variableKindNames) {
+		return fmt.Sprintf("unknown(%d)", int(kind))
+	}
+	return variableKindNames[kind]
+}
+
+/*
+	variableKindByName returns the variable kind identified by the given name.
+*/
+func variableKindByName(name string) (VariableKind, bool) {
+	if name == "" {
+		return ANY, true
+	}
+	for index, kindName := range variableKindNames {
+		if kindName == name {
+			return VariableKind(index), true
+		}
+	}
+	return ANY, false
+}
+
+/*
+	parseVariable converts the given variable value to the given kind.
+	Values of kind ANY and SLUG are returned as strings,
+	INT64 as int64, UUID as Identifier and DATE as time.Time.
+	An error will be returned in case the value is malformed.
+*/
+func parseVariable(kind VariableKind, value string) (
+	parsed interface{},
+	err error,
+) {
+	if pattern, exists := variableKindPatterns[kind]; exists {
+		if !pattern.MatchString(value) {
+			return nil, fmt.Errorf("Malformed %s value '%s'", kind, value)
+		}
+	}
+	switch kind {
+	case ANY, SLUG:
+		return value, nil
+	case INT64:
+		number, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("Malformed %s value '%s'", kind, value)
+		}
+		return number, nil
+	case UUID:
+		identifier := Identifier {}
+		identifier.FromString(value)
+		return identifier, nil
+	case DATE:
+		date, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			return nil, fmt.Errorf("Malformed %s value '%s'", kind, value)
+		}
+		return date, nil
+	}
+	return nil, fmt.Errorf("Unknown variable kind (%d)", int(kind))
+}
+
+/*
+	variable returns the parsed value of the given variable segment.
+	Values not parsed yet are parsed from their raw value.
+	An error will be returned in case the resource identifier
+	doesn't contain the variable segment, it is of another kind
+	or its value is malformed.
+*/
+func (resId *ResourceIdentifier) variable(
+	identifier string,
+	kind VariableKind,
+) (
+	parsed interface{},
+	err error,
+) {
+	for _, segment := range resId.path {
+		if !segment.isVariable() || segment.identifier != identifier {
+			continue
+		}
+		if segment.kind != kind {
+			return nil, fmt.Errorf(
+				"Variable segment '%s' is of kind %s, not %s",
+				identifier,
+				segment.kind,
+				kind,
+			)
+		}
+		if segment.parsed == nil {
+			//identifiers not built from a resource tree carry raw values only
+			return parseVariable(kind, segment.value)
+		}
+		return segment.parsed, nil
+	}
+	return nil, NotFoundError {
+		message: fmt.Sprintf("Variable segment '%s' not found", identifier),
+	}
+}
+
+/*
+	Int64 returns the value of the given INT64 variable segment.
+*/
+func (resId *ResourceIdentifier) Int64(identifier string) (int64, error) {
+	parsed, err := resId.variable(identifier, INT64)
+	if err != nil {
+		return 0, err
+	}
+	return parsed.(int64), nil
+}
+
+/*
+	Uuid returns the value of the given UUID variable segment.
+*/
+func (resId *ResourceIdentifier) Uuid(identifier string) (Identifier, error) {
+	parsed, err := resId.variable(identifier, UUID)
+	if err != nil {
+		return Identifier {}, err
+	}
+	return parsed.(Identifier), nil
+}
+
+/*
+	Slug returns the value of the given SLUG variable segment.
+*/
+func (resId *ResourceIdentifier) Slug(identifier string) (string, error) {
+	parsed, err := resId.variable(identifier, SLUG)
+	if err != nil {
+		return "", err
+	}
+	return parsed.(string), nil
+}
+
+/*
+	Date returns the value of the given DATE variable segment
+	as UTC midnight.
+*/
+func (resId *ResourceIdentifier) Date(identifier string) (time.Time, error) {
+	parsed, err := resId.variable(identifier, DATE)
+	if err != nil {
+		return time.Time {}, err
+	}
+	return parsed.(time.Time), nil
+}
+
+/*
+	ResourceIdentifier returns the identifier of the requested resource.
+*/
+func (req *Request) ResourceIdentifier() ResourceIdentifier {
+	return req.resourceId
+}
+
+/*
+	Int64 returns the value of the given INT64 variable segment
+	of the requested resource.
+*/
+func (req *Request) Int64(identifier string) (int64, error) {
+	return req.resourceId.Int64(identifier)
+}
+
+/*
+	Uuid returns the value of the given UUID variable segment
+	of the requested resource.
+*/
+func (req *Request) Uuid(identifier string) (Identifier, error) {
+	return req.resourceId.Uuid(identifier)
+}
+
+/*
+	Slug returns the value of the given SLUG variable segment
+	of the requested resource.
+*/
+func (req *Request) Slug(identifier string) (string, error) {
+	return req.resourceId.Slug(identifier)
+}
+
+/*
+	Date returns the value of the given DATE variable segment
+	of the requested resource.
+*/
+func (req *Request) Date(identifier string) (time.Time, error) {
+	return req.resourceId.Date(identifier)
+}
@@ -0,0 +1,136 @@
+package apperix
+
+import (
+	"time"
+	"testing"
+	"net/http"
+)
+
+/*
+	kindTestService returns a service with an INT64 item
+	and a DATE day resource readable by guests,
+	the handlers reply the parsed values.
+*/
+func kindTestService(t *testing.T) *Service {
+	t.Helper()
+	return newTestService(t, ServiceConfig {
+		Resources: map[string] Resource {
+			"items": Resource {
+				Type: STATIC,
+				Name: "items",
+			},
+			"item": Resource {
+				Type: VARIABLE,
+				Parent: "items",
+				Kind: INT64,
+				Permissions: DefaultResourcePermissions {
+					GuestPermissions: Permissions {
+						Read: true,
+					},
+				},
+				Handlers: map[Method] Handler {
+					READ: func(client *Client, request *Request, service *Service) Response {
+						response := &ResponseJson {}
+						number, err := request.Int64("item")
+						if err != nil {
+							response.ReplyServerError("VARIABLE", err.Error())
+							return response
+						}
+						response.Data("item", number)
+						return response
+					},
+				},
+			},
+			"days": Resource {
+				Type: STATIC,
+				Name: "days",
+			},
+			"day": guestReadable(Resource {
+				Type: VARIABLE,
+				Parent: "days",
+				Kind: DATE,
+			}),
+		},
+	})
+}
+
+func TestTypedVariablesAreParsed(t *testing.T) {
+	service := kindTestService(t)
+	recorder := testRequest(service, "GET", "/items/-42", "")
+	expectStatus(t, recorder, http.StatusOK)
+	if number, _ := responseData(t, recorder)["item"].(float64); number != -42 {
+		t.Errorf("Expected parsed value -42, got %v", responseData(t, recorder)["item"])
+	}
+	expectStatus(t, testRequest(service, "GET", "/days/2017-03-21", ""), http.StatusOK)
+
+	resourceId, err := service.GetResourceIdentifier("day", map[string] string {
+		"day": "2017-03-21",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	date, err := resourceId.Date("day")
+	if err != nil || !date.Equal(time.Date(2017, 3, 21, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected 2017-03-21, got %v (%v)", date, err)
+	}
+}
+
+func TestTypedVariablesRejectMalformedValues(t *testing.T) {
+	service := kindTestService(t)
+	for _, target := range []string {
+		"/items/abc",
+		"/items/4.2",
+		"/items/99999999999999999999",
+		"/days/21.03.2017",
+		"/days/2017-02-30",
+	} {
+		expectStatus(t, testRequest(service, "GET", target, ""), http.StatusNotFound)
+	}
+	if _, err := service.GetResourceIdentifier("item", map[string] string {
+		"item": "abc",
+	}); err == nil {
+		t.Error("Expected malformed value to be rejected")
+	}
+
+	resourceId, err := service.GetResourceIdentifier("item", map[string] string {
+		"item": "42",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := resourceId.Slug("item"); err == nil {
+		t.Error("Expected variable of another kind to be rejected")
+	}
+	if _, err := resourceId.Int64("day"); err == nil {
+		t.Error("Expected missing variable to be rejected")
+	} else if _, isNotFound := err.(NotFoundError); !isNotFound {
+		t.Errorf("Expected NotFoundError, got %v", err)
+	}
+}
+
+func TestParseVariable(t *testing.T) {
+	for _, test := range []struct {
+		kind VariableKind
+		value string
+		valid bool
+	} {
+		{ANY, "any value", true},
+		{SLUG, "hello-world", true},
+		{SLUG, "Hello-World", false},
+		{SLUG, "hello--world", false},
+		{UUID, "0123456789abcdef0123456789abcdef", true},
+		{UUID, "0123456789ABCDEF0123456789ABCDEF", false},
+		{INT64, "9223372036854775807", true},
+		{INT64, "9223372036854775808", false},
+		{DATE, "2017-02-29", false},
+		{VariableKind(42), "42", false},
+	} {
+		_, err := parseVariable(test.kind, test.value)
+		if (err == nil) != test.valid {
+			t.Errorf("%s '%s': expected valid %v, got %v", test.kind, test.value, test.valid, err)
+		}
+	}
+	if _, known := variableKindByName("float"); known {
+		t.Error("Expected unknown kind name to be rejected")
+	}
+}
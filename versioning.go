@@ -0,0 +1,244 @@
+package apperix
+
+import (
+	"fmt"
+	"mime"
+	"sort"
+	"strings"
+	"net/http"
+)
+
+/*
+	versioning selects the API version of requests.
+	Versions are identified by their index in ascending order,
+	services without versions serve a single unnamed version 0.
+*/
+type versioning struct {
+	names []string
+	indices map[string] int
+	defaultVersion int
+	prefix bool
+	header string
+	acceptParameter string
+}
+
+/*
+	newVersioning returns the versioning of the given configuration.
+	An error will be returned in case a version name is malformed
+	or duplicated, the default version is unknown or versions
+	are configured without a way to select them.
+*/
+func newVersioning(conf VersioningConfig) (
+	versions versioning,
+	err error,
+) {
+	versions = versioning {
+		names: conf.Versions,
+		indices: make(map[string] int),
+		prefix: conf.Prefix,
+		header: conf.Header,
+		acceptParameter: conf.AcceptParameter,
+	}
+	for index, name := range conf.Versions {
+		if name == "" || strings.ContainsAny(name, "/;,= ") {
+			return versions, fmt.Errorf("Malformed API version '%s'", name)
+		}
+		if _, exists := versions.indices[name]; exists {
+			return versions, fmt.Errorf("Duplicate API version '%s'", name)
+		}
+		versions.indices[name] = index
+	}
+	if len(conf.Versions) < 1 {
+		if conf.Default != "" || conf.Prefix || conf.Header != "" || conf.AcceptParameter != "" {
+			return versions, fmt.Errorf("Missing API versions")
+		}
+		return versions, nil
+	}
+	if !conf.Prefix && conf.Header == "" && conf.AcceptParameter == "" {
+		return versions, fmt.Errorf("Missing API version selection (prefix, header or accept parameter)")
+	}
+	if conf.Default != "" {
+		index, exists := versions.indices[conf.Default]
+		if !exists {
+			return versions, fmt.Errorf("Unknown default API version '%s'", conf.Default)
+		}
+		versions.defaultVersion = index
+	}
+	return versions, nil
+}
+
+/*
+	name returns the name of the given version,
+	an empty string is returned for the unnamed version.
+*/
+func (versions *versioning) name(version int) string {
+	if version < 0 || version >= len(versions.names) {
+		return ""
+	}
+	return versions.names[version]
+}
+
+/*
+	prefixed returns true if the given root level name
+	selects a version by URL prefix.
+*/
+func (versions *versioning) prefixed(name string) bool {
+	_, exists := versions.indices[name]
+	return versions.prefix && exists
+}
+
+/*
+	urlPrefix returns the URL path prefix selecting the given version,
+	which is empty unless versions are selected by URL prefix.
+*/
+func (versions *versioning) urlPrefix(version int) string {
+	if !versions.prefix || version < 0 || version >= len(versions.names) {
+		return ""
+	}
+	return ConcatStrings("/", versions.names[version])
+}
+
+/*
+	selectVersion returns the version of the given request,
+	the version prefix of its URL path and its percent-encoded
+	URL path without version prefix.
+	The URL prefix takes precedence over the header,
+	which takes precedence over the Accept header.
+	Requests not selecting a version are served by the default version.
+	An error will be returned in case the request selects an unknown version.
+*/
+func (versions *versioning) selectVersion(request *http.Request) (
+	version int,
+	prefix string,
+	urlPath string,
+	err error,
+) {
+	urlPath = request.URL.EscapedPath()
+	if len(versions.names) < 1 {
+		return 0, "", urlPath, nil
+	}
+	if versions.prefix {
+		trimmed := strings.TrimLeft(urlPath, "/")
+		name, rest := trimmed, "/"
+		if index := strings.IndexByte(trimmed, '/'); index >= 0 {
+			name, rest = trimmed[:index], trimmed[index:]
+		}
+		if index, exists := versions.indices[name]; exists {
+			return index, ConcatStrings("/", name), rest, nil
+		}
+	}
+	if versions.header != "" {
+		if name := request.Header.Get(versions.header); name != "" {
+			index, exists := versions.indices[name]
+			if !exists {
+				return 0, "", urlPath, fmt.Errorf("Unknown API version '%s'", name)
+			}
+			return index, "", urlPath, nil
+		}
+	}
+	if versions.acceptParameter != "" {
+		for _, mediaRange := range strings.Split(request.Header.Get("Accept"), ",") {
+			_, params, err := mime.ParseMediaType(mediaRange)
+			if err != nil || params[versions.acceptParameter] == "" {
+				continue
+			}
+			name := params[versions.acceptParameter]
+			index, exists := versions.indices[name]
+			if !exists {
+				return 0, "", urlPath, fmt.Errorf("Unknown API version '%s'", name)
+			}
+			return index, "", urlPath, nil
+		}
+	}
+	return versions.defaultVersion, "", urlPath, nil
+}
+
+/*
+	resolveVersionHandlers returns the handlers of the given resource
+	for each of the given versions. Each version inherits the handlers
+	of the previous one, the first version inherits the handlers
+	of the resource. Nil handlers remove inherited handlers.
+*/
+func resolveVersionHandlers(
+	resource Resource,
+	versions []string,
+) []map[Method] Handler {
+	resolved := make([]map[Method] Handler, 0, len(versions) + 1)
+	current := make(map[Method] Handler, len(resource.Handlers))
+	for method, handler := range resource.Handlers {
+		if handler != nil {
+			current[method] = handler
+		}
+	}
+	if len(versions) < 1 {
+		return append(resolved, current)
+	}
+	for _, version := range versions {
+		next := make(map[Method] Handler, len(current))
+		for method, handler := range current {
+			next[method] = handler
+		}
+		for method, handler := range resource.VersionHandlers[version] {
+			if handler == nil {
+				delete(next, method)
+			} else {
+				next[method] = handler
+			}
+		}
+		resolved = append(resolved, next)
+		current = next
+	}
+	return resolved
+}
+
+/*
+	versionHandler returns the handler of the given method
+	in the given version of the given resolved handlers.
+*/
+func versionHandler(
+	handlers []map[Method] Handler,
+	version int,
+	method Method,
+) (Handler, error) {
+	if version >= len(handlers) {
+		version = len(handlers) - 1
+	}
+	if version >= 0 {
+		if handler, exists := handlers[version][method]; exists {
+			return handler, nil
+		}
+	}
+	return nil, fmt.Errorf("No handler found for method %d", int(method))
+}
+
+/*
+	versionMethods returns the methods handled in the given version
+	of the given resolved handlers in ascending order.
+*/
+func versionMethods(
+	handlers []map[Method] Handler,
+	version int,
+) []Method {
+	if version >= len(handlers) {
+		version = len(handlers) - 1
+	}
+	methods := make([]Method, 0)
+	if version < 0 {
+		return methods
+	}
+	for method := range handlers[version] {
+		methods = append(methods, method)
+	}
+	sort.Slice(methods, func(i, j int) bool {
+		return methods[i] < methods[j]
+	})
+	return methods
+}
+
+/*
+	Version returns the name of the API version the request is served by,
+	an empty string is returned in case the service is not versioned.
+*/
+func (req *Request) Version() string {
+	return req.version
+}
@@ -0,0 +1,173 @@
+package apperix
+
+import (
+	"testing"
+	"net/http"
+	"net/http/httptest"
+)
+
+/*
+	versionedHandler returns a handler replying the API version serving the request
+	prefixed by the given label.
+*/
+func versionedHandler(label string) Handler {
+	return func(client *Client, request *Request, service *Service) Response {
+		response := &ResponseJson {}
+		response.Data("served", ConcatStrings(label, ":", request.Version()))
+		return response
+	}
+}
+
+/*
+	versionTestService returns a service serving the versions v1, v2 and v3
+	selected by URL prefix, header and Accept parameter.
+	The resource "items" is readable by users, v2 overrides its READ handler
+	and v3 removes UPDATE.
+*/
+func versionTestService(t *testing.T) *Service {
+	t.Helper()
+	return newTestService(t, ServiceConfig {
+		Versioning: VersioningConfig {
+			Versions: []string {"v1", "v2", "v3"},
+			Default: "v1",
+			Prefix: true,
+			Header: "Api-Version",
+			AcceptParameter: "version",
+		},
+		Resources: map[string] Resource {
+			"items": Resource {
+				Type: STATIC,
+				Name: "items",
+				Handlers: map[Method] Handler {
+					READ: versionedHandler("base"),
+					UPDATE: okHandler,
+				},
+				VersionHandlers: map[string] map[Method] Handler {
+					"v2": {
+						READ: versionedHandler("override"),
+					},
+					"v3": {
+						UPDATE: nil,
+					},
+				},
+			},
+		},
+	})
+}
+
+func TestVersionSelection(t *testing.T) {
+	service := versionTestService(t)
+	userId, token := testUser(t, service, "user")
+	resourceId, _ := service.GetResourceIdentifier("items", nil)
+	//permissions are shared by all versions
+	err := service.AssignPermissions(resourceId, userId, Permissions {
+		Read: true,
+		Update: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		target string
+		header map[string] string
+		served string
+	} {
+		{"/items", nil, "base:v1"},
+		{"/v1/items", nil, "base:v1"},
+		{"/v2/items", nil, "override:v2"},
+		{"/v3/items", nil, "override:v3"},
+		{"/items", map[string] string {"Api-Version": "v2"}, "override:v2"},
+		{"/items", map[string] string {"Accept": "text/plain, application/json; version=v3"}, "override:v3"},
+		{"/v1/items", map[string] string {"Api-Version": "v2"}, "base:v1"},
+		{"/items", map[string] string {"Api-Version": "v1", "Accept": "application/json; version=v2"}, "base:v1"},
+	} {
+		request := httptest.NewRequest("GET", test.target, nil)
+		request.Header.Set("Authorization", token)
+		for key, value := range test.header {
+			request.Header.Set(key, value)
+		}
+		recorder := httptest.NewRecorder()
+		service.Handler().ServeHTTP(recorder, request)
+		expectStatus(t, recorder, http.StatusOK)
+		if served := responseData(t, recorder)["served"]; served != test.served {
+			t.Errorf("%s %v: expected '%s', got %v", test.target, test.header, test.served, served)
+		}
+	}
+	expectStatus(t, testRequest(service, "PUT", "/v2/items", token), http.StatusOK)
+	expectStatus(t, testRequest(service, "PUT", "/v3/items", token), http.StatusMethodNotAllowed)
+	expectStatus(t, testRequest(service, "GET", "/v4/items", token), http.StatusNotFound)
+
+	request := httptest.NewRequest("GET", "/items", nil)
+	request.Header.Set("Api-Version", "v4")
+	recorder := httptest.NewRecorder()
+	service.Handler().ServeHTTP(recorder, request)
+	expectStatus(t, recorder, http.StatusBadRequest)
+
+	routes := service.Routes()
+	if versions := routes[2].Versions; len(versions["v2"]) != 2 || len(versions["v3"]) != 1 {
+		t.Errorf("Expected methods of each version, got %v", versions)
+	}
+}
+
+func TestUrlForVersion(t *testing.T) {
+	service := versionTestService(t)
+	for _, test := range []struct {
+		version string
+		expected string
+	} {
+		{"", "/v1/items"},
+		{"v2", "/v2/items"},
+	} {
+		url, err := service.UrlForVersion(test.version, "items", nil)
+		if err != nil || url != test.expected {
+			t.Errorf("'%s': expected '%s', got '%s' (%v)", test.version, test.expected, url, err)
+		}
+	}
+	if _, err := service.UrlForVersion("v4", "items", nil); err == nil {
+		t.Error("Expected unknown version to be rejected")
+	}
+}
+
+func TestVersioningErrors(t *testing.T) {
+	for _, test := range []struct {
+		description string
+		conf VersioningConfig
+	} {
+		{"malformed name", VersioningConfig {Versions: []string {"v/1"}, Prefix: true}},
+		{"duplicate name", VersioningConfig {Versions: []string {"v1", "v1"}, Prefix: true}},
+		{"missing versions", VersioningConfig {Prefix: true}},
+		{"missing selection", VersioningConfig {Versions: []string {"v1"}}},
+		{"unknown default", VersioningConfig {Versions: []string {"v1"}, Default: "v2", Prefix: true}},
+	} {
+		if _, err := newVersioning(test.conf); err == nil {
+			t.Errorf("%s: expected versioning to be rejected", test.description)
+		}
+	}
+
+	conf := ServiceConfig {
+		Authentication: AuthenticationConfig {
+			Path: "auth",
+		},
+		Versioning: VersioningConfig {
+			Versions: []string {"v1", "auth"},
+			Prefix: true,
+		},
+		Resources: map[string] Resource {
+			"v1": Resource {
+				Type: STATIC,
+				Name: "v1",
+			},
+		},
+	}
+	if problems := ValidateConfig(conf); len(problems) != 2 {
+		t.Errorf("Expected overlaps with resource and authentication path, got %v", problems)
+	}
+
+	service := versionTestService(t)
+	other := newTestService(t, ServiceConfig {
+		Name: "other",
+	})
+	if err := service.Mount("v2", other); err == nil {
+		t.Error("Expected mount name overlapping with version prefix to be rejected")
+	}
+}